@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+)
+
+const bundleUsage = `Usage:
+  k8s-installer bundle create [flags]   Download artifacts and images into an offline bundle
+`
+
+func runBundle(args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return fmt.Errorf("expected a bundle command\n\n%s", bundleUsage)
+	}
+
+	fs := flag.NewFlagSet("bundle create", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file whose versions to bundle")
+		output     = fs.String("output", "k8s-bundle.tar.gz", "Path of the bundle to write")
+		arch       = fs.String("arch", "", "Architecture to bundle binaries and images for (default: this host)")
+	)
+	fs.Parse(args[1:])
+
+	inst, err := newArchInstaller(*configFile, *arch)
+	if err != nil {
+		return err
+	}
+	log.Printf("=> Creating bundle %s...", *output)
+	if err := inst.CreateBundle(*output); err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	log.Printf("✓ Bundle written to %s; install with --bundle=%s", *output, *output)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+const cacheUsage = `Usage:
+  k8s-installer cache list [flags]    List cached downloads
+  k8s-installer cache prune [flags]   Remove downloads the configured versions do not use
+`
+
+func runCache(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing cache command\n\n%s", cacheUsage)
+	}
+	switch args[0] {
+	case "list":
+		return runCacheList(args[1:])
+	case "prune":
+		return runCachePrune(args[1:])
+	default:
+		return fmt.Errorf("unknown cache command %q\n\n%s", args[0], cacheUsage)
+	}
+}
+
+func runCacheList(args []string) error {
+	fs := flag.NewFlagSet("cache list", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to cluster config file whose cache to list")
+	fs.Parse(args)
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+	c := inst.DownloadCache()
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SHA256\tSIZE\tLAST USED\tURL")
+	var total int64
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.SHA256[:12], utils.FormatBytes(e.Size), e.LastUsed.Local().Format("2006-01-02 15:04"), e.URL)
+		total += e.Size
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d downloads, %s in %s\n", len(entries), utils.FormatBytes(total), c.Dir)
+	return nil
+}
+
+func runCachePrune(args []string) error {
+	fs := flag.NewFlagSet("cache prune", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file whose versions to keep")
+		all        = fs.Bool("all", false, "Remove every cached download")
+	)
+	fs.Parse(args)
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+	removed, err := inst.PruneCache(*all)
+	var freed int64
+	for _, e := range removed {
+		log.Printf("  Removed %s", e.URL)
+		freed += e.Size
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Removed %d downloads, %s freed", len(removed), utils.FormatBytes(freed))
+	return nil
+}
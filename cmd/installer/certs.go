@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/installer"
+)
+
+const certsUsage = `Usage:
+  k8s-installer certs check-expiration [flags]    Show when every certificate in the PKI dir expires
+  k8s-installer certs renew [flags] <name|all>... Re-sign leaf certificates with their CA
+  k8s-installer certs add-san [flags] <ip|dns>... Add SANs to the API server certificate
+`
+
+func runCerts(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing certs command\n\n%s", certsUsage)
+	}
+	switch args[0] {
+	case "check-expiration":
+		return runCertsCheckExpiration(args[1:])
+	case "renew":
+		return runCertsRenew(args[1:])
+	case "add-san":
+		return runCertsAddSAN(args[1:])
+	default:
+		return fmt.Errorf("unknown certs command %q\n\n%s", args[0], certsUsage)
+	}
+}
+
+func runCertsCheckExpiration(args []string) error {
+	fs := flag.NewFlagSet("certs check-expiration", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to cluster config file used for the installation")
+	fs.Parse(args)
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+	certs, err := inst.Certificates()
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		fmt.Println("No certificates found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CERTIFICATE\tEXPIRES\tDAYS LEFT\tCA\tSUBJECT")
+	for _, c := range certs {
+		left := "expired"
+		if d := time.Until(c.NotAfter); d > 0 {
+			left = fmt.Sprintf("%d", int(d.Hours()/24))
+		}
+		ca := "no"
+		if c.CA {
+			ca = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Name, c.NotAfter.Format(time.DateOnly), left, ca, c.Subject)
+	}
+	return w.Flush()
+}
+
+func runCertsRenew(args []string) error {
+	fs := flag.NewFlagSet("certs renew", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file used for the installation")
+		noRestart  = fs.Bool("no-restart", false, "Do not restart the components using the renewed certificates")
+	)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("certs renew needs certificate names or all\n\n%s", certsUsage)
+	}
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+
+	log.Println("=> Renewing certificates...")
+	report, err := inst.RenewCertificates(fs.Args())
+	if report != nil {
+		for _, path := range report.Kubeconfigs {
+			log.Printf("  Updated %s", path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return restartComponents(inst, report.Restart, *noRestart)
+}
+
+func runCertsAddSAN(args []string) error {
+	fs := flag.NewFlagSet("certs add-san", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file used for the installation")
+		noRestart  = fs.Bool("no-restart", false, "Do not restart the API server")
+	)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("certs add-san needs IP addresses or DNS names\n\n%s", certsUsage)
+	}
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+
+	log.Println("=> Reissuing the API server certificate...")
+	report, err := inst.AddAPIServerSANs(fs.Args())
+	if err != nil {
+		return err
+	}
+	return restartComponents(inst, report.Restart, *noRestart)
+}
+
+// restartComponents restarts the components that use changed certificates,
+// or only names them with --no-restart.
+func restartComponents(inst *installer.Installer, names []string, noRestart bool) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if noRestart {
+		log.Printf("Restart to use the new certificates: %v", names)
+		return nil
+	}
+	log.Println("=> Restarting components...")
+	return inst.RestartComponents(names)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"os"
+)
+
+// runChecksums prints checksums.txt lines for the configured versions.
+func runChecksums(args []string) error {
+	fs := flag.NewFlagSet("checksums", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to cluster config file whose versions to hash")
+	arch := fs.String("arch", "", "Architecture whose artifacts to hash (default: this host)")
+	fs.Parse(args)
+
+	inst, err := newArchInstaller(*configFile, *arch)
+	if err != nil {
+		return err
+	}
+	return inst.Checksums(os.Stdout)
+}
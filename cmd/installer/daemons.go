@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/services"
+)
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to cluster config file used for the installation")
+	fs.Parse(args)
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+	statuses, err := inst.Services().Status()
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		fmt.Println("No components have been started")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tSTATE\tPID\tRESTARTS\tUPTIME")
+	for _, st := range statuses {
+		state, uptime := "dead", "-"
+		switch {
+		case st.Running:
+			state = "running"
+			uptime = time.Since(st.StartedAt).Round(time.Second).String()
+		case st.Stopped:
+			state = "stopped"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", st.Name, state, st.PID, st.Restarts, uptime)
+	}
+	return w.Flush()
+}
+
+func runStop(args []string) error {
+	return forEachComponent("stop", false, args, (*services.Manager).Stop)
+}
+
+func runRestart(args []string) error {
+	return forEachComponent("restart", true, args, (*services.Manager).Restart)
+}
+
+// forEachComponent applies action to the named components, or to all
+// recorded components when none are named. Components are handled in stop
+// order, or in start order (etcd first) when start is set.
+func forEachComponent(cmd string, start bool, args []string, action func(*services.Manager, string) error) error {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to cluster config file used for the installation")
+	fs.Parse(args)
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+	mgr := inst.Services()
+
+	names := fs.Args()
+	if len(names) == 0 || (len(names) == 1 && names[0] == "all") {
+		if names, err = mgr.Components(); err != nil {
+			return err
+		}
+	}
+	services.SortComponents(names, start)
+
+	for _, name := range names {
+		log.Printf("=> %s %s...", cmd, name)
+		if err := action(mgr, name); err != nil {
+			return fmt.Errorf("failed to %s %s: %w", cmd, name, err)
+		}
+	}
+	return nil
+}
+
+func runSupervise(args []string) error {
+	fs := flag.NewFlagSet("supervise", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to cluster config file used for the installation")
+	fs.Parse(args)
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("Supervising components (Ctrl-C to exit, components keep running)...")
+	return inst.Services().Supervise(ctx)
+}
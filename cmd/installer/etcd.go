@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const etcdUsage = `Usage:
+  k8s-installer etcd backup [flags] [file]   Save a snapshot to file, or to --dir
+  k8s-installer etcd restore [flags] <file>  Restore local etcd members from a snapshot
+`
+
+// defaultBackupDir lives outside baseDir so that "reset --all" keeps backups.
+const defaultBackupDir = "/var/backups/k8s-installer/etcd"
+
+func runEtcd(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing etcd command\n\n%s", etcdUsage)
+	}
+	switch args[0] {
+	case "backup":
+		return runEtcdBackup(args[1:])
+	case "restore":
+		return runEtcdRestore(args[1:])
+	default:
+		return fmt.Errorf("unknown etcd command %q\n\n%s", args[0], etcdUsage)
+	}
+}
+
+func runEtcdBackup(args []string) error {
+	fs := flag.NewFlagSet("etcd backup", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file used for the installation")
+		dir        = fs.String("dir", defaultBackupDir, "Directory for timestamped snapshots when no file is given")
+		keep       = fs.Int("keep", 7, "Number of timestamped snapshots to keep in --dir (0 keeps all)")
+		interval   = fs.Duration("interval", 0, "Take a snapshot every interval until interrupted")
+	)
+	fs.Parse(args)
+	if fs.NArg() > 1 || (fs.NArg() == 1 && *interval > 0) {
+		return fmt.Errorf("etcd backup takes at most one file, and none with --interval")
+	}
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+	mgr := inst.Services()
+
+	if fs.NArg() == 1 {
+		path := fs.Arg(0)
+		log.Printf("=> Saving etcd snapshot to %s...", path)
+		sum, err := mgr.SnapshotEtcd(path)
+		if err != nil {
+			return err
+		}
+		log.Printf("  ✓ Snapshot saved (sha256 %s)", sum)
+		return nil
+	}
+
+	log.Printf("=> Saving etcd snapshot to %s...", *dir)
+	if _, err := mgr.BackupEtcd(*dir, *keep); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	log.Printf("=> Next snapshot in %s (Ctrl-C to stop)", *interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// A failed attempt does not stop the schedule.
+			if _, err := mgr.BackupEtcd(*dir, *keep); err != nil {
+				log.Printf("  ⚠ etcd backup failed: %v", err)
+			}
+		}
+	}
+}
+
+func runEtcdRestore(args []string) error {
+	fs := flag.NewFlagSet("etcd restore", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file used for the installation")
+		member     = fs.String("member", "", "Restore only this etcd member (default: all members on this host)")
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("etcd restore needs exactly one snapshot file\n\n%s", etcdUsage)
+	}
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("=> Restoring etcd from %s...", fs.Arg(0))
+	if err := inst.Services().RestoreEtcd(fs.Arg(0), *member); err != nil {
+		return fmt.Errorf("etcd restore failed: %w", err)
+	}
+	log.Println("✓ etcd restored")
+	return nil
+}
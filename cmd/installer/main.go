@@ -2,43 +2,188 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 
+	"github.com/dereban25/k8s-installer/internal/config"
 	"github.com/dereban25/k8s-installer/internal/installer"
+	"github.com/dereban25/k8s-installer/internal/utils"
 )
 
+const usage = `Usage: k8s-installer [command] [flags]
+
+Commands:
+  install   Install the cluster (default)
+  upgrade   Upgrade the cluster in place to another Kubernetes version
+  reset     Stop all components and remove the installation
+  status    Show the state of supervised components
+  stop      Stop components (all when none given)
+  restart   Restart components (all when none given)
+  supervise Keep components running, restarting them when they exit
+  etcd      Back up etcd to a snapshot or restore it (etcd backup|restore)
+  certs     Check, renew or add SANs to certificates (certs check-expiration|renew|add-san)
+  checksums Print pinned sha256 lines of the downloads for checksums.txt
+  bundle    Create an offline install bundle (bundle create)
+  cache     List or prune cached downloads (cache list|prune)
+
+Run "k8s-installer <command> -h" for command flags.
+`
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	cmd, args := "install", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "install":
+		err = runInstall(args)
+	case "upgrade":
+		err = runUpgrade(args)
+	case "reset":
+		err = runReset(args)
+	case "status":
+		err = runStatus(args)
+	case "stop":
+		err = runStop(args)
+	case "restart":
+		err = runRestart(args)
+	case "supervise":
+		err = runSupervise(args)
+	case "etcd":
+		err = runEtcd(args)
+	case "certs":
+		err = runCerts(args)
+	case "checksums":
+		err = runChecksums(args)
+	case "bundle":
+		err = runBundle(args)
+	case "cache":
+		err = runCache(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runInstall(args []string) error {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
 	var (
-		k8sVersion       = flag.String("k8s-version", "v1.30.0", "Kubernetes version")
-		skipDownload     = flag.Bool("skip-download", false, "Skip downloading binaries")
-		skipVerify       = flag.Bool("skip-verify", false, "Skip verification")
-		skipAPIWait      = flag.Bool("skip-api-wait", false, "Skip waiting for API server (faster but less safe)")
-		continueOnError  = flag.Bool("continue-on-error", false, "Continue installation even if non-critical steps fail")
-		verbose          = flag.Bool("verbose", false, "Verbose output")
+		configFile      = fs.String("config", "", "Path to cluster config file (YAML)")
+		k8sVersion      = fs.String("k8s-version", "", "Kubernetes version or latest-<minor> (overrides config, default "+config.DefaultK8sVersion+")")
+		skipDownload    = fs.Bool("skip-download", false, "Use the binaries already installed instead of downloading them")
+		skipVerify      = fs.Bool("skip-verify", false, "Skip verification")
+		skipAPIWait     = fs.Bool("skip-api-wait", false, "Skip waiting for API server (faster but less safe)")
+		continueOnError = fs.Bool("continue-on-error", false, "Continue installation even if non-critical steps fail")
+		verbose         = fs.Bool("verbose", false, "Verbose output")
+		fromStep        = fs.String("from-step", "", "Re-execute the named step and all steps after it")
+		onlyStep        = fs.String("only-step", "", "Execute only the named step")
+		fresh           = fs.Bool("fresh", false, "Ignore progress recorded by a previous run")
+		parallel        = fs.Int("parallel", 4, "Maximum number of independent steps to run at once")
+		plan            = fs.Bool("plan", false, "Print the step dependency graph and exit")
+		initSystem      = fs.String("init", "", "How to run components: process or systemd (overrides config, default "+config.InitProcess+")")
+		controlPlane    = fs.String("control-plane", "", "How to run the control plane: host or static-pod (overrides config, default "+config.ControlPlaneHost+")")
+		securityProfile = fs.String("security-profile", "", "Authentication and authorization setup: default or hardened (overrides config, default "+config.SecurityDefault+")")
+		bundlePath      = fs.String("bundle", "", "Install from an offline bundle created by \"k8s-installer bundle create\"")
+		progress        = fs.String("progress", utils.ProgressAuto, "Download progress: auto, tty, plain, json or none")
+		arch            = fs.String("arch", "", "Architecture to install binaries for: "+strings.Join(config.SupportedArches, " or ")+" (overrides config, default: this host)")
 	)
-	flag.Parse()
+	fs.Parse(args)
 
 	if *verbose {
 		log.SetFlags(log.LstdFlags | log.Lshortfile | log.Lmicroseconds)
 	}
 
+	cluster, err := loadCluster(*configFile)
+	if err != nil {
+		return err
+	}
+
 	inst, err := installer.New(&installer.Config{
+		Cluster:         cluster,
 		K8sVersion:      *k8sVersion,
+		Init:            *initSystem,
+		ControlPlane:    *controlPlane,
+		SecurityProfile: *securityProfile,
+		Arch:            *arch,
 		SkipDownload:    *skipDownload,
 		SkipVerify:      *skipVerify,
 		SkipAPIWait:     *skipAPIWait,
 		ContinueOnError: *continueOnError,
 		Verbose:         *verbose,
+		FromStep:        *fromStep,
+		OnlyStep:        *onlyStep,
+		Fresh:           *fresh,
+		Parallel:        *parallel,
+		Bundle:          *bundlePath,
+		Progress:        *progress,
 	})
 	if err != nil {
-		log.Fatalf("Failed to create installer: %v", err)
+		return fmt.Errorf("failed to create installer: %w", err)
+	}
+
+	if *plan {
+		out, err := inst.Plan()
+		if err != nil {
+			return fmt.Errorf("failed to build plan: %w", err)
+		}
+		fmt.Print(out)
+		return nil
 	}
 
 	if err := inst.Run(); err != nil {
-		log.Fatalf("Installation failed: %v", err)
+		return fmt.Errorf("installation failed: %w", err)
 	}
 
 	log.Println("🎉 Kubernetes installation completed successfully!")
-}
\ No newline at end of file
+	return nil
+}
+
+// newInstaller builds an installer for the maintenance commands, which only
+// need the resolved paths of an existing installation. A "latest" version
+// is taken from the state file, so they work without dl.k8s.io.
+func newInstaller(configFile string) (*installer.Installer, error) {
+	cluster, err := loadCluster(configFile)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := installer.New(&installer.Config{Cluster: cluster, Installed: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installer: %w", err)
+	}
+	return inst, nil
+}
+
+// newArchInstaller builds an installer for commands that fetch artifacts
+// for the --arch they are given; "latest" versions are resolved.
+func newArchInstaller(configFile, arch string) (*installer.Installer, error) {
+	cluster, err := loadCluster(configFile)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := installer.New(&installer.Config{Cluster: cluster, Arch: arch})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installer: %w", err)
+	}
+	return inst, nil
+}
+
+// loadCluster resolves the cluster spec: defaults -> --config -> K8S_* env.
+// Command flags are applied on top by the caller.
+func loadCluster(configFile string) (*config.ClusterConfig, error) {
+	cluster, err := config.Resolve(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cluster config: %w", err)
+	}
+	return cluster, nil
+}
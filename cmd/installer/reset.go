@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+func runReset(args []string) error {
+	fs := flag.NewFlagSet("reset", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file used for the installation")
+		dryRun     = fs.Bool("dry-run", false, "Only report what would be removed")
+		all        = fs.Bool("all", false, "Also remove downloaded binaries (the whole base dir) and logs")
+	)
+	fs.Parse(args)
+
+	inst, err := newInstaller(*configFile)
+	if err != nil {
+		return err
+	}
+
+	report, resetErr := inst.Reset(*dryRun, *all)
+
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+	}
+	printList("Stopped", report.Stopped)
+	printList("Unmounted", report.Unmounted)
+	printList(verb, report.Removed)
+	if len(report.Stopped)+len(report.Unmounted)+len(report.Removed) == 0 {
+		fmt.Println("Nothing to reset")
+	}
+
+	return resetErr
+}
+
+func printList(title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, item := range items {
+		fmt.Printf("  %s\n", item)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/installer"
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+// defaultUpgradeTimeout leaves room for a static-pod control plane, whose
+// new images are pulled by the kubelet first.
+const defaultUpgradeTimeout = 5 * time.Minute
+
+func runUpgrade(args []string) error {
+	fs := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	var (
+		configFile = fs.String("config", "", "Path to cluster config file used for the installation")
+		to         = fs.String("to", "", "Kubernetes version or latest-<minor> to upgrade to (required)")
+		backupDir  = fs.String("backup-dir", defaultBackupDir, "Directory for the etcd snapshot taken before upgrading")
+		timeout    = fs.Duration("timeout", defaultUpgradeTimeout, "How long each component may take to become healthy before rolling back")
+		bundlePath = fs.String("bundle", "", "Take the new binaries and images from an offline bundle")
+		progress   = fs.String("progress", utils.ProgressAuto, "Download progress: auto, tty, plain, json or none")
+	)
+	fs.Parse(args)
+	if *to == "" {
+		return fmt.Errorf("upgrade needs --to")
+	}
+
+	cluster, err := loadCluster(*configFile)
+	if err != nil {
+		return err
+	}
+	inst, err := installer.New(&installer.Config{
+		Cluster:    cluster,
+		K8sVersion: *to,
+		Bundle:     *bundlePath,
+		Progress:   *progress,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create installer: %w", err)
+	}
+
+	if err := inst.Upgrade(installer.UpgradeOptions{BackupDir: *backupDir, Timeout: *timeout}); err != nil {
+		return err
+	}
+	log.Println("🎉 Kubernetes upgrade completed successfully!")
+	return nil
+}
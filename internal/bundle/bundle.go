@@ -0,0 +1,242 @@
+// Package bundle builds and reads offline install bundles: a tar.gz with
+// every downloaded artifact, the container images the cluster needs and a
+// bundle.yaml manifest listing them with their SHA256.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+const (
+	APIVersion = "installer.k8s.io/v1alpha1"
+	Kind       = "Bundle"
+
+	// ManifestFile is the manifest at the root of a bundle.
+	ManifestFile = "bundle.yaml"
+)
+
+// Manifest describes the content of a bundle. Paths are relative to the
+// bundle root.
+type Manifest struct {
+	APIVersion string    `yaml:"apiVersion"`
+	Kind       string    `yaml:"kind"`
+	Created    time.Time `yaml:"created"`
+	// Arch is the GOARCH the artifacts and images were fetched for.
+	Arch      string     `yaml:"arch,omitempty"`
+	Artifacts []Artifact `yaml:"artifacts"`
+	Images    []Image    `yaml:"images"`
+}
+
+// Artifact is a downloaded file, keyed by the URL it was downloaded from.
+type Artifact struct {
+	URL    string `yaml:"url"`
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// Image is an OCI image layout tarball for "ctr images import".
+type Image struct {
+	Ref    string `yaml:"ref"`
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// Artifact returns the artifact downloaded from url.
+func (m *Manifest) Artifact(url string) (Artifact, bool) {
+	for _, a := range m.Artifacts {
+		if a.URL == url {
+			return a, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// PullImage pulls ref into dir and returns its manifest entry.
+func PullImage(p *Puller, ref, dir string) (Image, error) {
+	rel := ImageFileName(ref)
+	dest := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return Image{}, err
+	}
+	if err := p.pullToFile(ref, dest); err != nil {
+		return Image{}, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	sum, err := utils.SHA256File(dest)
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{Ref: ref, Path: rel, SHA256: sum}, nil
+}
+
+// Write stores m as dir/bundle.yaml and packs dir into a tar.gz at output.
+func Write(dir string, m *Manifest, output string) error {
+	m.APIVersion, m.Kind = APIVersion, Kind
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644); err != nil {
+		return err
+	}
+
+	tmp := output + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = pack(dir, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return os.Rename(tmp, output)
+}
+
+func pack(dir string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Extract unpacks the bundle at archive into dir, replacing its content,
+// and verifies every listed file.
+func Extract(archive, dir string) (*Manifest, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a bundle: %w", archive, err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", archive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		dest, err := within(dir, hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(out, tr)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+		}
+	}
+	return Open(dir)
+}
+
+// Open reads the manifest of an extracted bundle and verifies the SHA256 of
+// every file it lists.
+func Open(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("not an extracted bundle: %w", err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	if m.APIVersion != APIVersion || m.Kind != Kind {
+		return nil, fmt.Errorf("%s: expected %s %s, got %s %s", ManifestFile, APIVersion, Kind, m.APIVersion, m.Kind)
+	}
+
+	files := map[string]string{}
+	for _, a := range m.Artifacts {
+		files[a.Path] = a.SHA256
+	}
+	for _, img := range m.Images {
+		files[img.Path] = img.SHA256
+	}
+	for rel, want := range files {
+		p, err := within(dir, rel)
+		if err != nil {
+			return nil, err
+		}
+		got, err := utils.SHA256File(p)
+		if err != nil {
+			return nil, fmt.Errorf("bundle is missing %s: %w", rel, err)
+		}
+		if got != want {
+			return nil, fmt.Errorf("bundle file %s is corrupted: expected sha256 %s, got %s", rel, want, got)
+		}
+	}
+	return &m, nil
+}
+
+// within resolves a slash-separated bundle path under dir, rejecting paths
+// that would escape it.
+func within(dir, name string) (string, error) {
+	clean := path.Clean("/" + name)
+	if clean == "/" || clean != "/"+strings.TrimPrefix(name, "./") {
+		return "", fmt.Errorf("invalid path %q in bundle", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
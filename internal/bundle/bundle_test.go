@@ -0,0 +1,95 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+func writeBundle(t *testing.T, files map[string]string) (string, *Manifest) {
+	t.Helper()
+	staging := t.TempDir()
+	m := &Manifest{}
+	for rel, content := range files {
+		p := filepath.Join(staging, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := utils.SHA256File(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(rel, "images/") {
+			m.Images = append(m.Images, Image{Ref: "registry.k8s.io/pause:3.10", Path: rel, SHA256: sum})
+		} else {
+			m.Artifacts = append(m.Artifacts, Artifact{URL: "https://dl.k8s.io/" + rel, Path: rel, SHA256: sum})
+		}
+	}
+	output := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := Write(staging, m, output); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	return output, m
+}
+
+func TestWriteAndExtract(t *testing.T) {
+	archive, _ := writeBundle(t, map[string]string{
+		"artifacts/kubelet":                "kubelet binary",
+		"images/registry.k8s.io_pause.tar": "pause image",
+	})
+
+	dir := filepath.Join(t.TempDir(), "bundle")
+	m, err := Extract(archive, dir)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	a, ok := m.Artifact("https://dl.k8s.io/artifacts/kubelet")
+	if !ok {
+		t.Fatalf("Expected kubelet in manifest, got %+v", m.Artifacts)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, a.Path)); err != nil || string(data) != "kubelet binary" {
+		t.Errorf("Unexpected kubelet content %q: %v", data, err)
+	}
+	if len(m.Images) != 1 || m.APIVersion != APIVersion {
+		t.Errorf("Unexpected manifest %+v", m)
+	}
+
+	// Open verifies the content again, e.g. before importing images.
+	if err := os.WriteFile(filepath.Join(dir, a.Path), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("Expected a corrupted file to be detected, got %v", err)
+	}
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "../escaped", Mode: 0644, Size: 4, Typeflag: tar.TypeReg})
+	tw.Write([]byte("evil"))
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	root := t.TempDir()
+	if _, err := Extract(archive, filepath.Join(root, "bundle")); err == nil {
+		t.Fatal("Expected a path outside the bundle to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); !os.IsNotExist(err) {
+		t.Error("File was written outside the bundle dir")
+	}
+}
@@ -0,0 +1,348 @@
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Media types of the manifests a registry may return for a tag.
+const (
+	mediaTypeOCIIndex        = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest     = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest  = "application/vnd.docker.distribution.manifest.v2+json"
+	manifestAcceptHeader     = mediaTypeOCIIndex + ", " + mediaTypeDockerList + ", " + mediaTypeOCIManifest + ", " + mediaTypeDockerManifest
+	containerdImageNameLabel = "io.containerd.image.name"
+	ociRefNameAnnotation     = "org.opencontainers.image.ref.name"
+)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// Reference is a parsed image reference such as registry.k8s.io/pause:3.10.
+type Reference struct {
+	Registry   string
+	Repository string
+	// Tag or digest (sha256:...).
+	Reference string
+}
+
+// ParseReference resolves short Docker Hub names the way container runtimes
+// do: nginx:1.27 is docker.io/library/nginx:1.27.
+func ParseReference(ref string) (Reference, error) {
+	name, tag := ref, "latest"
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, tag = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	if name == "" || tag == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", ref)
+	}
+
+	r := Reference{Registry: "docker.io", Repository: name, Reference: tag}
+	if i := strings.Index(name, "/"); i >= 0 {
+		if host := name[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			r.Registry, r.Repository = host, name[i+1:]
+		}
+	}
+	if r.Registry == "docker.io" && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+	return r, nil
+}
+
+// String returns the fully qualified reference.
+func (r Reference) String() string {
+	if strings.HasPrefix(r.Reference, "sha256:") {
+		return r.Registry + "/" + r.Repository + "@" + r.Reference
+	}
+	return r.Registry + "/" + r.Repository + ":" + r.Reference
+}
+
+// Puller downloads images from an OCI distribution registry.
+type Puller struct {
+	Client *http.Client
+	// Arch is the platform architecture picked from multi-arch images.
+	Arch string
+
+	token string
+}
+
+// Pull downloads ref for linux/<Arch> and writes it to w as an OCI image
+// layout tarball that "ctr images import" understands. Every blob is
+// checked against its digest.
+func (p *Puller) Pull(ref string, w io.Writer) error {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return err
+	}
+
+	data, mediaType, err := p.fetchManifest(r, r.Reference)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(r.Reference, "sha256:") {
+		if err := checkDigest(data, r.Reference); err != nil {
+			return err
+		}
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("invalid manifest for %s: %w", ref, err)
+	}
+	if mediaType == mediaTypeOCIIndex || mediaType == mediaTypeDockerList || len(m.Manifests) > 0 {
+		desc, err := p.pickPlatform(m.Manifests)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref, err)
+		}
+		if data, mediaType, err = p.fetchManifest(r, desc.Digest); err != nil {
+			return err
+		}
+		if err := checkDigest(data, desc.Digest); err != nil {
+			return err
+		}
+		m = manifest{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("invalid manifest for %s: %w", ref, err)
+		}
+	}
+	if mediaType == "" {
+		mediaType = m.MediaType
+	}
+
+	tw := tar.NewWriter(w)
+	manifestDigest := digestOf(data)
+	if err := writeTarFile(tw, "blobs/sha256/"+strings.TrimPrefix(manifestDigest, "sha256:"), data); err != nil {
+		return err
+	}
+	for _, blob := range append([]descriptor{m.Config}, m.Layers...) {
+		if err := p.copyBlob(tw, r, blob); err != nil {
+			return fmt.Errorf("%s: %w", ref, err)
+		}
+	}
+
+	index, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"manifests": []descriptor{{
+			MediaType: mediaType,
+			Digest:    manifestDigest,
+			Size:      int64(len(data)),
+			Annotations: map[string]string{
+				containerdImageNameLabel: r.String(),
+				ociRefNameAnnotation:     r.Reference,
+			},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "index.json", index); err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func (p *Puller) pickPlatform(manifests []descriptor) (descriptor, error) {
+	for _, d := range manifests {
+		if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == p.Arch {
+			return d, nil
+		}
+	}
+	return descriptor{}, fmt.Errorf("no image for linux/%s", p.Arch)
+}
+
+func (p *Puller) fetchManifest(r Reference, reference string) ([]byte, string, error) {
+	resp, err := p.get(r, "/manifests/"+reference, manifestAcceptHeader)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0]), nil
+}
+
+func (p *Puller) copyBlob(tw *tar.Writer, r Reference, blob descriptor) error {
+	resp, err := p.get(r, "/blobs/"+blob.Digest, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name: "blobs/sha256/" + strings.TrimPrefix(blob.Digest, "sha256:"),
+		Mode: 0644,
+		Size: blob.Size,
+	}); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(tw, h), resp.Body, blob.Size); err != nil {
+		return fmt.Errorf("failed to download %s: %w", blob.Digest, err)
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != blob.Digest {
+		return fmt.Errorf("blob %s has digest %s", blob.Digest, got)
+	}
+	return nil
+}
+
+// get requests /v2/<repository><suffix>, fetching an anonymous bearer token
+// when the registry asks for one.
+func (p *Puller) get(r Reference, suffix, accept string) (*http.Response, error) {
+	host := r.Registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	u := "https://" + host + "/v2/" + r.Repository + suffix
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if p.token != "" {
+			req.Header.Set("Authorization", "Bearer "+p.token)
+		}
+		resp, err := p.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if p.token, err = p.fetchToken(challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+// fetchToken answers a "Bearer realm=...,service=...,scope=..." challenge.
+func (p *Puller) fetchToken(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry authentication %q", challenge)
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			params[k] = strings.Trim(v, `"`)
+		}
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm in %q", challenge)
+	}
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	resp, err := p.Client.Get(realm.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %s: %s", realm.Host, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func checkDigest(data []byte, digest string) error {
+	if got := digestOf(data); got != digest {
+		return fmt.Errorf("manifest %s has digest %s", digest, got)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// ImageFileName is the file an image is stored under in a bundle.
+func ImageFileName(ref string) string {
+	r, err := ParseReference(ref)
+	if err == nil {
+		ref = r.String()
+	}
+	return path.Join("images", strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(ref)+".tar")
+}
+
+// pullToFile is Pull into a new file at dest.
+func (p *Puller) pullToFile(ref, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	err = p.Pull(ref, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dest)
+	}
+	return err
+}
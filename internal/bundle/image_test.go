@@ -0,0 +1,144 @@
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := map[string]string{
+		"nginx:1.27":                       "docker.io/library/nginx:1.27",
+		"nginx":                            "docker.io/library/nginx:latest",
+		"bitnami/redis:7":                  "docker.io/bitnami/redis:7",
+		"registry.k8s.io/pause:3.10":       "registry.k8s.io/pause:3.10",
+		"localhost:5000/etcd:3.5.12-0":     "localhost:5000/etcd:3.5.12-0",
+		"registry.k8s.io/pause@sha256:abc": "registry.k8s.io/pause@sha256:abc",
+	}
+	for in, want := range tests {
+		r, err := ParseReference(in)
+		if err != nil || r.String() != want {
+			t.Errorf("ParseReference(%q) = %s, %v; want %s", in, r, err, want)
+		}
+	}
+}
+
+// fakeRegistry serves a multi-arch image behind anonymous bearer auth.
+func fakeRegistry(t *testing.T) *httptest.Server {
+	layer := []byte("layer contents")
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	image, _ := json.Marshal(manifest{
+		MediaType: mediaTypeOCIManifest,
+		Config:    descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digestOf(config), Size: int64(len(config))},
+		Layers:    []descriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digestOf(layer), Size: int64(len(layer))}},
+	})
+	index, _ := json.Marshal(manifest{
+		MediaType: mediaTypeOCIIndex,
+		Manifests: []descriptor{
+			{MediaType: mediaTypeOCIManifest, Digest: "sha256:" + strings.Repeat("0", 64), Size: 1, Platform: &platform{Architecture: "arm64", OS: "linux"}},
+			{MediaType: mediaTypeOCIManifest, Digest: digestOf(image), Size: int64(len(image)), Platform: &platform{Architecture: "amd64", OS: "linux"}},
+		},
+	})
+	blobs := map[string][]byte{digestOf(config): config, digestOf(layer): layer}
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:pause:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"token":"secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test",scope="repository:pause:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/pause/manifests/3.10":
+			w.Header().Set("Content-Type", mediaTypeOCIIndex)
+			w.Write(index)
+		case r.URL.Path == "/v2/pause/manifests/"+digestOf(image):
+			w.Header().Set("Content-Type", mediaTypeOCIManifest)
+			w.Write(image)
+		case strings.HasPrefix(r.URL.Path, "/v2/pause/blobs/"):
+			blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/pause/blobs/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(blob)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return srv
+}
+
+func TestPullWritesOCILayout(t *testing.T) {
+	srv := fakeRegistry(t)
+	defer srv.Close()
+	ref := strings.TrimPrefix(srv.URL, "https://") + "/pause:3.10"
+
+	dir := t.TempDir()
+	img, err := PullImage(&Puller{Client: srv.Client(), Arch: "amd64"}, ref, dir)
+	if err != nil {
+		t.Fatalf("PullImage failed: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, img.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	files := map[string][]byte{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name], _ = io.ReadAll(tr)
+	}
+
+	var index manifest
+	if err := json.Unmarshal(files["index.json"], &index); err != nil {
+		t.Fatalf("Invalid index.json: %v", err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Annotations[containerdImageNameLabel] != ref {
+		t.Fatalf("Unexpected index %+v", index)
+	}
+	if _, ok := files["oci-layout"]; !ok {
+		t.Error("Expected oci-layout")
+	}
+	// The amd64 manifest, its config and layer are all in the layout.
+	if len(files) != 5 {
+		var names []string
+		for name := range files {
+			names = append(names, name)
+		}
+		t.Errorf("Expected manifest, config and layer blobs, got %v", names)
+	}
+}
+
+func TestPullRejectsMissingPlatform(t *testing.T) {
+	srv := fakeRegistry(t)
+	defer srv.Close()
+	ref := strings.TrimPrefix(srv.URL, "https://") + "/pause:3.10"
+
+	err := (&Puller{Client: srv.Client(), Arch: "s390x"}).Pull(ref, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "linux/s390x") {
+		t.Errorf("Expected a missing platform error, got %v", err)
+	}
+}
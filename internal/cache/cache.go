@@ -0,0 +1,194 @@
+// Package cache keeps downloaded artifacts between installs. Files are
+// stored by content under <dir>/sha256/<digest>; index.json maps every URL
+// to the digest it was verified against when it was downloaded.
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+const indexFile = "index.json"
+
+// Entry is a cached download.
+type Entry struct {
+	URL      string    `json:"url"`
+	SHA256   string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	Added    time.Time `json:"added"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// Cache is a download cache rooted at Dir. It is safe for concurrent use
+// within one process.
+type Cache struct {
+	Dir string
+	// Downloader fetches missing files; nil means utils.NewDownloader().
+	Downloader *utils.Downloader
+
+	mu sync.Mutex
+}
+
+// New returns the cache at dir; it is created on the first download.
+func New(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// Path returns the file holding the content of e.
+func (c *Cache) Path(e Entry) string {
+	return filepath.Join(c.Dir, "sha256", e.SHA256)
+}
+
+// Lookup returns the entry cached for url. When sha256Hex is set the entry
+// must have that digest. The file is hashed again, a damaged one is dropped
+// and reported as a miss.
+func (c *Cache) Lookup(url, sha256Hex string) (Entry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, err := c.load()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	e, ok := index[url]
+	if !ok || (sha256Hex != "" && e.SHA256 != sha256Hex) {
+		return Entry{}, false, nil
+	}
+	if got, err := utils.SHA256File(c.Path(e)); err != nil || got != e.SHA256 {
+		delete(index, url)
+		os.Remove(c.Path(e))
+		return Entry{}, false, c.save(index)
+	}
+
+	e.LastUsed = time.Now().UTC()
+	index[url] = e
+	return e, true, c.save(index)
+}
+
+// Fetch downloads url into the cache, verifying it against sha256Hex, and
+// records it. Content already cached under another URL is not downloaded
+// again.
+func (c *Cache) Fetch(url, sha256Hex string) (Entry, error) {
+	if err := os.MkdirAll(filepath.Join(c.Dir, "sha256"), 0755); err != nil {
+		return Entry{}, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	now := time.Now().UTC()
+	e := Entry{URL: url, SHA256: sha256Hex, Added: now, LastUsed: now}
+	if got, err := utils.SHA256File(c.Path(e)); err != nil || got != sha256Hex {
+		d := c.Downloader
+		if d == nil {
+			d = utils.NewDownloader()
+		}
+		if err := d.Download(url, c.Path(e), sha256Hex); err != nil {
+			return Entry{}, err
+		}
+	}
+	info, err := os.Stat(c.Path(e))
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Size = info.Size()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, err := c.load()
+	if err != nil {
+		return Entry{}, err
+	}
+	index[url] = e
+	return e, c.save(index)
+}
+
+// List returns the cached entries sorted by URL.
+func (c *Cache) List() ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(index))
+	for _, e := range index {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].URL < entries[b].URL })
+	return entries, nil
+}
+
+// Prune removes the entries keep returns false for, together with content
+// no remaining entry refers to. It returns the removed entries.
+func (c *Cache) Prune(keep func(Entry) bool) ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	var removed []Entry
+	for url, e := range index {
+		if !keep(e) {
+			removed = append(removed, e)
+			delete(index, url)
+		}
+	}
+	sort.Slice(removed, func(a, b int) bool { return removed[a].URL < removed[b].URL })
+	if err := c.save(index); err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, e := range index {
+		used[e.SHA256] = true
+	}
+	files, err := filepath.Glob(filepath.Join(c.Dir, "sha256", "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if !used[filepath.Base(f)] {
+			if err := os.Remove(f); err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+func (c *Cache) load() (map[string]Entry, error) {
+	index := map[string]Entry{}
+	data, err := os.ReadFile(filepath.Join(c.Dir, indexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("cache index %s is corrupted (remove it to start over): %w", filepath.Join(c.Dir, indexFile), err)
+	}
+	return index, nil
+}
+
+// save writes the index through a temporary file so that an interrupted
+// run never leaves a truncated one behind.
+func (c *Cache) save(index map[string]Entry) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(c.Dir, indexFile+".part")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(c.Dir, indexFile))
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func sha(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// newServer serves its path as content and counts the requests.
+func newServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(r.URL.Path))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestFetchAndLookup(t *testing.T) {
+	srv, requests := newServer(t)
+	c := New(t.TempDir())
+	url := srv.URL + "/v1/kubelet"
+
+	if _, ok, err := c.Lookup(url, ""); ok || err != nil {
+		t.Fatalf("Expected a miss in an empty cache, got %v, %v", ok, err)
+	}
+	e, err := c.Fetch(url, sha("/v1/kubelet"))
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if e.Size != int64(len("/v1/kubelet")) {
+		t.Errorf("Unexpected size %d", e.Size)
+	}
+
+	got, ok, err := c.Lookup(url, "")
+	if !ok || err != nil || got.SHA256 != e.SHA256 {
+		t.Fatalf("Expected a hit, got %+v, %v, %v", got, ok, err)
+	}
+	if _, ok, _ := c.Lookup(url, sha("other")); ok {
+		t.Errorf("Expected a different pinned digest to miss")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single download, got %d", n)
+	}
+}
+
+func TestFetchRejectsWrongDigest(t *testing.T) {
+	srv, _ := newServer(t)
+	c := New(t.TempDir())
+
+	if _, err := c.Fetch(srv.URL+"/kubelet", sha("tampered")); err == nil {
+		t.Fatal("Expected a checksum mismatch")
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Errorf("Expected nothing to be cached, got %v", entries)
+	}
+}
+
+func TestLookupDropsDamagedFile(t *testing.T) {
+	srv, _ := newServer(t)
+	c := New(t.TempDir())
+	e, err := c.Fetch(srv.URL+"/kubelet", sha("/kubelet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.Path(e), []byte("damaged"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := c.Lookup(e.URL, ""); ok || err != nil {
+		t.Fatalf("Expected a damaged file to miss, got %v, %v", ok, err)
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Errorf("Expected the damaged entry to be dropped, got %v", entries)
+	}
+}
+
+func TestPruneKeepsSharedContent(t *testing.T) {
+	srv, _ := newServer(t)
+	c := New(t.TempDir())
+	// Two URLs, e.g. a mirror and upstream, with the same content.
+	a, err := c.Fetch(srv.URL+"/same", sha("/same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Fetch(srv.URL+"/same?mirror=1", sha("/same")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Fetch(srv.URL+"/other", sha("/other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := c.Prune(func(e Entry) bool { return e.URL != a.URL && e.URL != b.URL })
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected two entries to be removed, got %v", removed)
+	}
+	if _, err := os.Stat(c.Path(a)); err != nil {
+		t.Errorf("Expected content still listed under another URL to be kept: %v", err)
+	}
+	if _, err := os.Stat(c.Path(b)); !os.IsNotExist(err) {
+		t.Errorf("Expected unreferenced content to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// APIServer tunes the API server.
+type APIServer struct {
+	// CertSANs are extra IP addresses and DNS names for the serving
+	// certificate, e.g. a public IP or the name of a load balancer.
+	CertSANs []string `yaml:"certSANs,omitempty"`
+	// InterfaceSANs adds the addresses of every host interface.
+	InterfaceSANs bool `yaml:"interfaceSANs,omitempty"`
+}
+
+var sanDNSName = regexp.MustCompile(`^(\*\.)?([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)*[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// SplitSANs sorts certificate SANs into DNS names, lower-cased, and IP
+// addresses.
+func SplitSANs(sans []string) ([]string, []net.IP, error) {
+	var dnsNames []string
+	var ips []net.IP
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			ips = append(ips, ip)
+			continue
+		}
+		name := strings.ToLower(san)
+		if !sanDNSName.MatchString(name) {
+			return nil, nil, fmt.Errorf("%q is neither an IP address nor a DNS name", san)
+		}
+		dnsNames = append(dnsNames, name)
+	}
+	return dnsNames, ips, nil
+}
+
+// ServiceIP is the first address of the service CIDR, which the
+// "kubernetes" Service in the default namespace gets.
+func (c *ClusterConfig) ServiceIP() (net.IP, error) {
+	_, serviceNet, err := net.ParseCIDR(c.Network.ServiceCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network.serviceCIDR %q: %w", c.Network.ServiceCIDR, err)
+	}
+	ip := slices.Clone(serviceNet.IP)
+	for n := len(ip) - 1; n >= 0; n-- {
+		if ip[n]++; ip[n] != 0 {
+			break
+		}
+	}
+	return ip, nil
+}
+
+func (c *ClusterConfig) validateAPIServer(add func(string, ...any)) {
+	for n, san := range c.APIServer.CertSANs {
+		if _, _, err := SplitSANs([]string{san}); err != nil {
+			add("apiServer.certSANs[%d]: %v", n, err)
+		}
+	}
+}
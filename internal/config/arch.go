@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// HostArch returns the GOARCH name of the host CPU as reported by uname,
+// or the architecture the installer was built for when uname fails.
+func HostArch() string {
+	out, err := exec.Command("uname", "-m").Output()
+	if err != nil {
+		return runtime.GOARCH
+	}
+	return unameArch(strings.TrimSpace(string(out)))
+}
+
+// unameArch maps a "uname -m" machine name to its GOARCH name.
+func unameArch(machine string) string {
+	switch machine {
+	case "x86_64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "armv6l", "armv7l", "armv8l":
+		// 32-bit userland, also on a 64-bit Raspberry Pi CPU.
+		return "arm"
+	case "i386", "i686":
+		return "386"
+	}
+	return machine
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Release lists the component versions the installer pairs with one
+// Kubernetes minor. Complete takes every version the spec leaves empty
+// from it.
+type Release struct {
+	// Minor is the Kubernetes minor, e.g. "1.31".
+	Minor string
+	// Kubebuilder is the kubebuilder-tools build kube-apiserver, etcd and
+	// kubectl are taken from.
+	Kubebuilder string
+	Crictl      string
+	// Etcd is the etcd release etcdutl comes from, EtcdImageTag the
+	// registry.k8s.io/etcd tag for static-pod control planes.
+	Etcd         string
+	EtcdImageTag string
+	PauseTag     string
+	// MinContainerd is the oldest containerd release supporting the minor.
+	MinContainerd string
+	// KubeletConfigAPI is the KubeletConfiguration apiVersion.
+	KubeletConfigAPI string
+}
+
+// Releases is the compatibility matrix, oldest minor first.
+var Releases = []Release{
+	{
+		Minor:            "1.30",
+		Kubebuilder:      "1.30.0",
+		Crictl:           "v1.30.0",
+		Etcd:             "v3.5.12",
+		EtcdImageTag:     "3.5.12-0",
+		PauseTag:         "3.10",
+		MinContainerd:    "1.7.0",
+		KubeletConfigAPI: "kubelet.config.k8s.io/v1beta1",
+	},
+	{
+		Minor:            "1.31",
+		Kubebuilder:      "1.31.0",
+		Crictl:           "v1.31.1",
+		Etcd:             "v3.5.15",
+		EtcdImageTag:     "3.5.15-0",
+		PauseTag:         "3.10",
+		MinContainerd:    "1.7.0",
+		KubeletConfigAPI: "kubelet.config.k8s.io/v1beta1",
+	},
+}
+
+// LatestPrefix asks for the newest patch release of a minor, as in
+// "latest-1.31"; a bare "latest" means the newest supported minor.
+const LatestPrefix = "latest"
+
+// SupportedMinors returns the Kubernetes minors in the matrix.
+func SupportedMinors() []string {
+	minors := make([]string, len(Releases))
+	for n, r := range Releases {
+		minors[n] = r.Minor
+	}
+	return minors
+}
+
+// LookupRelease returns the matrix row for the minor of version, which may
+// be a full version such as v1.31.2 or just the minor.
+func LookupRelease(version string) (Release, bool) {
+	v, ok := parseVersion(version)
+	if !ok {
+		return Release{}, false
+	}
+	for _, r := range Releases {
+		if r.Minor == v.minorString() {
+			return r, true
+		}
+	}
+	return Release{}, false
+}
+
+// LatestMinor parses "latest" or "latest-<minor>" and returns the minor
+// asked for. ok is false for any other version.
+func LatestMinor(version string) (minor string, ok bool, err error) {
+	if version == LatestPrefix {
+		return Releases[len(Releases)-1].Minor, true, nil
+	}
+	minor, found := strings.CutPrefix(version, LatestPrefix+"-")
+	if !found {
+		return "", false, nil
+	}
+	minor = strings.TrimPrefix(minor, "v")
+	if _, known := LookupRelease(minor); !known {
+		return "", true, fmt.Errorf("Kubernetes %s is not supported (supported: %s)", minor, strings.Join(SupportedMinors(), ", "))
+	}
+	return minor, true, nil
+}
+
+// Release returns the matrix row for the spec's Kubernetes version. It is
+// always found once Validate has passed.
+func (c *ClusterConfig) Release() Release {
+	r, _ := LookupRelease(c.Versions.Kubernetes)
+	return r
+}
+
+// completeVersions fills the versions left empty from the matrix.
+func (c *ClusterConfig) completeVersions() {
+	r, ok := LookupRelease(c.Versions.Kubernetes)
+	if !ok {
+		// Validate reports the unsupported version.
+		return
+	}
+	v := &c.Versions
+	for _, f := range []struct {
+		value *string
+		def   string
+	}{
+		{&v.Kubebuilder, r.Kubebuilder},
+		{&v.Crictl, r.Crictl},
+		{&v.Etcd, r.Etcd},
+		{&v.PauseImage, v.ImageRepository + "/pause:" + r.PauseTag},
+		{&v.EtcdImage, v.ImageRepository + "/etcd:" + r.EtcdImageTag},
+	} {
+		if *f.value == "" {
+			*f.value = f.def
+		}
+	}
+}
+
+// validateVersions rejects Kubernetes versions outside the matrix and
+// component versions the Kubernetes version skew policy does not allow.
+func (c *ClusterConfig) validateVersions(add func(string, ...any)) {
+	v := c.Versions
+	if strings.HasPrefix(v.Kubernetes, LatestPrefix) {
+		add("versions.kubernetes %q has to be resolved to a release first", v.Kubernetes)
+		return
+	}
+	k8s, ok := parseVersion(v.Kubernetes)
+	if !ok || !strings.HasPrefix(v.Kubernetes, "v") {
+		add("versions.kubernetes %q must look like v1.30.0", v.Kubernetes)
+		return
+	}
+	r, ok := LookupRelease(v.Kubernetes)
+	if !ok {
+		add("versions.kubernetes %s is not supported (supported minors: %s)", v.Kubernetes, strings.Join(SupportedMinors(), ", "))
+		return
+	}
+
+	// kube-apiserver comes from kubebuilder-tools; controller-manager and
+	// scheduler may be one minor older than it, the kubelet must not be
+	// newer.
+	if kb, ok := parseVersion(v.Kubebuilder); !ok {
+		add("versions.kubebuilder %q must look like %s", v.Kubebuilder, r.Kubebuilder)
+	} else if d := kb.minorsAfter(k8s); d < 0 || d > 1 {
+		add("versions.kubebuilder %s: kube-apiserver must be the minor of versions.kubernetes %s or one newer (leave it empty for %s)",
+			v.Kubebuilder, v.Kubernetes, r.Kubebuilder)
+	}
+	if crictl, ok := parseVersion(v.Crictl); !ok {
+		add("versions.crictl %q must look like %s", v.Crictl, r.Crictl)
+	} else if d := crictl.minorsAfter(k8s); d < -1 || d > 1 {
+		add("versions.crictl %s is more than one minor away from versions.kubernetes %s (leave it empty for %s)",
+			v.Crictl, v.Kubernetes, r.Crictl)
+	}
+	oldest, _ := parseVersion(r.MinContainerd)
+	if ctrd, ok := parseVersion(v.Containerd); !ok {
+		add("versions.containerd %q must look like %s", v.Containerd, DefaultContainerdVersion)
+	} else if ctrd.less(oldest) {
+		add("versions.containerd %s is too old for Kubernetes %s (need %s or newer)", v.Containerd, r.Minor, r.MinContainerd)
+	}
+}
+
+// CheckUpgrade enforces the skew policy for moving a running cluster from
+// one Kubernetes release to another: no downgrades and at most one minor
+// at a time, so that the kubelet is never newer than the API server and
+// at most one minor older while the control plane is upgraded first.
+func CheckUpgrade(from, to string) error {
+	f, ok := parseVersion(from)
+	if !ok {
+		return fmt.Errorf("installed Kubernetes version %q is not a release", from)
+	}
+	t, ok := parseVersion(to)
+	if !ok {
+		return fmt.Errorf("target Kubernetes version %q is not a release", to)
+	}
+	switch d := t.minorsAfter(f); {
+	case t == f:
+		return fmt.Errorf("Kubernetes %s is already installed", from)
+	case t.less(f):
+		return fmt.Errorf("cannot downgrade Kubernetes from %s to %s", from, to)
+	case d > 1:
+		return fmt.Errorf("cannot upgrade Kubernetes from %s to %s: upgrade one minor at a time, to %d.%d first",
+			from, to, f.major, f.minor+1)
+	}
+	return nil
+}
+
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?(?:-[0-9A-Za-z.-]+)?$`)
+
+type version struct {
+	major, minor, patch int
+}
+
+// parseVersion accepts 1.31, 1.31.2, v1.31.2 and pre-releases such as
+// v1.31.0-rc.1.
+func parseVersion(s string) (version, bool) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return version{}, false
+	}
+	var v version
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.patch, _ = strconv.Atoi(m[3])
+	}
+	return v, true
+}
+
+func (v version) minorString() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+// minorsAfter is how many minors v is newer than o; large when the majors
+// differ.
+func (v version) minorsAfter(o version) int {
+	if v.major != o.major {
+		return (v.major - o.major) * 1000
+	}
+	return v.minor - o.minor
+}
+
+func (v version) less(o version) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	return v.patch < o.patch
+}
@@ -0,0 +1,596 @@
+// Package config describes the declarative cluster specification consumed by
+// the installer and the services it starts.
+//
+// Values are resolved in the following order, later sources overriding
+// earlier ones:
+//
+//  1. built-in defaults (Default)
+//  2. the YAML file passed with --config (Load)
+//  3. K8S_* environment variables (ApplyEnv)
+//  4. command line flags (applied by the caller)
+//
+// After all layers are applied, Complete fills in paths derived from BaseDir
+// and Validate rejects inconsistent specs before anything touches the host.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	APIVersion = "installer.k8s.io/v1alpha1"
+	Kind       = "ClusterConfig"
+)
+
+const (
+	DefaultBaseDir    = "/var/lib/kubernetes"
+	DefaultKubeletDir = "/var/lib/kubelet"
+	DefaultHostIP     = "127.0.0.1"
+
+	// Versions depending on the Kubernetes minor default from Releases.
+	DefaultK8sVersion        = "v1.30.0"
+	DefaultContainerdVersion = "2.0.5"
+	DefaultRuncVersion       = "v1.2.6"
+	DefaultCNIPluginsVersion = "v1.6.2"
+	DefaultImageRepository   = "registry.k8s.io"
+	DefaultTestImage         = "docker.io/library/nginx:1.27"
+)
+
+// Init systems the components can be run under.
+const (
+	// InitProcess runs components as supervised children of the installer.
+	InitProcess = "process"
+	// InitSystemd installs a systemd unit per component so the cluster
+	// survives reboots.
+	InitSystemd = "systemd"
+)
+
+// CPU architectures binaries are installed for, named as in GOARCH.
+const (
+	ArchAMD64 = "amd64"
+	ArchARM64 = "arm64"
+)
+
+// SupportedArches lists the architectures every artifact is published for.
+var SupportedArches = []string{ArchAMD64, ArchARM64}
+
+// Security profiles.
+const (
+	// SecurityDefault keeps the permissive development setup: AlwaysAllow
+	// authorization, anonymous auth and a well-known bootstrap token.
+	SecurityDefault = "default"
+	// SecurityHardened enables Node,RBAC authorization, disables anonymous
+	// auth and generates a random bootstrap token.
+	SecurityHardened = "hardened"
+)
+
+// Ways to run the control plane (etcd, apiserver, controller-manager,
+// scheduler).
+const (
+	// ControlPlaneHost runs them as host daemons under the init system.
+	ControlPlaneHost = "host"
+	// ControlPlaneStaticPod renders them as static Pod manifests that the
+	// kubelet runs, kubeadm-style.
+	ControlPlaneStaticPod = "static-pod"
+)
+
+// ClusterConfig is the root of the cluster spec file.
+type ClusterConfig struct {
+	APIVersion string    `yaml:"apiVersion"`
+	Kind       string    `yaml:"kind"`
+	Paths      Paths     `yaml:"paths"`
+	Network    Network   `yaml:"network"`
+	APIServer  APIServer `yaml:"apiServer,omitempty"`
+	Versions   Versions  `yaml:"versions"`
+	Kubelet    Kubelet   `yaml:"kubelet"`
+	Runtime    Runtime   `yaml:"runtime"`
+	Security   Security  `yaml:"security"`
+	PKI        PKI       `yaml:"pki,omitempty"`
+	Etcd       Etcd      `yaml:"etcd"`
+	Downloads  Downloads `yaml:"downloads,omitempty"`
+}
+
+// Paths lists every location on the host the installer writes to.
+type Paths struct {
+	BaseDir          string `yaml:"baseDir"`
+	KubeletDir       string `yaml:"kubeletDir"`
+	EtcdDataDir      string `yaml:"etcdDataDir,omitempty"`
+	ManifestsDir     string `yaml:"manifestsDir,omitempty"`
+	PKIDir           string `yaml:"pkiDir,omitempty"`
+	LogDir           string `yaml:"logDir"`
+	CNIConfDir       string `yaml:"cniConfDir"`
+	CNIBinDir        string `yaml:"cniBinDir"`
+	CNIStateDir      string `yaml:"cniStateDir"`
+	ContainerdConfig string `yaml:"containerdConfig"`
+	ContainerdSocket string `yaml:"containerdSocket"`
+	SystemdUnitDir   string `yaml:"systemdUnitDir"`
+	// CacheDir keeps downloads between installs; it lives outside BaseDir
+	// so that "reset --all" does not drop it.
+	CacheDir string `yaml:"cacheDir,omitempty"`
+}
+
+// Network holds addressing and port settings shared by all components.
+type Network struct {
+	HostIP         string `yaml:"hostIP"`
+	ServiceCIDR    string `yaml:"serviceCIDR"`
+	PodSubnet      string `yaml:"podSubnet"`
+	ClusterDNS     string `yaml:"clusterDNS"`
+	ClusterDomain  string `yaml:"clusterDomain"`
+	APIServerPort  int    `yaml:"apiServerPort"`
+	EtcdClientPort int    `yaml:"etcdClientPort"`
+	EtcdPeerPort   int    `yaml:"etcdPeerPort"`
+	CNINetworkName string `yaml:"cniNetworkName"`
+	CNIBridge      string `yaml:"cniBridge"`
+}
+
+// Versions pins the upstream artifacts that get installed. Kubebuilder,
+// Crictl, PauseImage, Etcd and EtcdImage left empty are taken from the
+// Releases row of the Kubernetes minor.
+type Versions struct {
+	Kubernetes  string `yaml:"kubernetes"`
+	Containerd  string `yaml:"containerd"`
+	Runc        string `yaml:"runc"`
+	CNIPlugins  string `yaml:"cniPlugins"`
+	Kubebuilder string `yaml:"kubebuilder"`
+	Crictl      string `yaml:"crictl"`
+	PauseImage  string `yaml:"pauseImage"`
+	// Etcd is the etcd release etcdutl (snapshot restore) is taken from.
+	Etcd string `yaml:"etcd"`
+	// TestImage is deployed by the test-deployment step. It is pinned so
+	// that an image imported from a bundle is not pulled again.
+	TestImage string `yaml:"testImage"`
+	// ImageRepository and EtcdImage are only used for static-pod control
+	// planes: kube-* images are <imageRepository>/<component>:<kubernetes>.
+	ImageRepository string `yaml:"imageRepository"`
+	EtcdImage       string `yaml:"etcdImage"`
+}
+
+// Kubelet holds node-level kubelet tuning.
+type Kubelet struct {
+	MaxPods      int    `yaml:"maxPods"`
+	CgroupDriver string `yaml:"cgroupDriver"`
+}
+
+// Runtime controls how the components are run on the host.
+type Runtime struct {
+	// Init is InitProcess or InitSystemd.
+	Init string `yaml:"init"`
+	// ControlPlane is ControlPlaneHost or ControlPlaneStaticPod.
+	ControlPlane string `yaml:"controlPlane"`
+	// Arch is the architecture binaries and images are installed for, one
+	// of SupportedArches. Empty means the architecture of this host.
+	Arch string `yaml:"arch,omitempty"`
+}
+
+// Etcd describes the etcd cluster. Without members a single member named
+// "default" runs on network.hostIP with network.etcdClientPort/etcdPeerPort.
+type Etcd struct {
+	ClusterToken string       `yaml:"clusterToken"`
+	Members      []EtcdMember `yaml:"members,omitempty"`
+}
+
+// EtcdMember is one member of the etcd cluster. Members on network.hostIP
+// or a loopback address are started by the installer; the others are
+// remote and have to be started with the flags the installer writes out.
+type EtcdMember struct {
+	Name       string `yaml:"name"`
+	Host       string `yaml:"host"`
+	ClientPort int    `yaml:"clientPort,omitempty"`
+	PeerPort   int    `yaml:"peerPort,omitempty"`
+}
+
+// ClientURL is the member's client endpoint.
+func (m EtcdMember) ClientURL() string {
+	return fmt.Sprintf("https://%s", net.JoinHostPort(m.Host, strconv.Itoa(m.ClientPort)))
+}
+
+// PeerURL is the member's peer endpoint.
+func (m EtcdMember) PeerURL() string {
+	return fmt.Sprintf("https://%s", net.JoinHostPort(m.Host, strconv.Itoa(m.PeerPort)))
+}
+
+// Security selects how the cluster authenticates and authorizes requests.
+type Security struct {
+	// Profile is SecurityDefault or SecurityHardened.
+	Profile string `yaml:"profile"`
+}
+
+// Default returns the spec used when no config file is given.
+func Default() *ClusterConfig {
+	return &ClusterConfig{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		Paths: Paths{
+			BaseDir:          DefaultBaseDir,
+			KubeletDir:       DefaultKubeletDir,
+			LogDir:           "/var/log/kubernetes",
+			CNIConfDir:       "/etc/cni/net.d",
+			CNIBinDir:        "/opt/cni/bin",
+			CNIStateDir:      "/var/lib/cni",
+			ContainerdConfig: "/etc/containerd/config.toml",
+			ContainerdSocket: "/run/containerd/containerd.sock",
+			SystemdUnitDir:   "/etc/systemd/system",
+		},
+		Network: Network{
+			HostIP:         DefaultHostIP,
+			ServiceCIDR:    "10.0.0.0/24",
+			PodSubnet:      "10.22.0.0/16",
+			ClusterDNS:     "10.0.0.10",
+			ClusterDomain:  "cluster.local",
+			APIServerPort:  6443,
+			EtcdClientPort: 2379,
+			EtcdPeerPort:   2380,
+			CNINetworkName: "mynet",
+			CNIBridge:      "cni0",
+		},
+		Versions: Versions{
+			Kubernetes: DefaultK8sVersion,
+			Containerd: DefaultContainerdVersion,
+			Runc:       DefaultRuncVersion,
+			CNIPlugins: DefaultCNIPluginsVersion,
+			TestImage:  DefaultTestImage,
+
+			ImageRepository: DefaultImageRepository,
+		},
+		Kubelet: Kubelet{
+			MaxPods:      10,
+			CgroupDriver: "cgroupfs",
+		},
+		Runtime: Runtime{
+			Init:         InitProcess,
+			ControlPlane: ControlPlaneHost,
+		},
+		Security: Security{
+			Profile: SecurityDefault,
+		},
+		PKI: PKI{
+			KeyAlgorithm:        DefaultKeyAlgorithm,
+			CAValidity:          DefaultCAValidity,
+			CertificateValidity: DefaultCertificateValidity,
+		},
+		Etcd: Etcd{
+			ClusterToken: "k8s-installer",
+		},
+	}
+}
+
+// Load reads a spec file on top of the defaults. Unknown fields are rejected
+// so that typos do not silently fall back to defaults.
+func Load(path string) (*ClusterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	// The file has to declare its own apiVersion/kind so that specs written
+	// for a future schema are not silently read with this one.
+	cfg := Default()
+	cfg.APIVersion, cfg.Kind = "", ""
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Resolve applies the defaults, the optional config file and the environment,
+// in that order. Flags are left to the caller.
+func Resolve(path string) (*ClusterConfig, error) {
+	cfg := Default()
+	if path != "" {
+		loaded, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+	cfg.ApplyEnv()
+	return cfg, nil
+}
+
+// ApplyEnv overrides the spec with the K8S_* environment variables.
+func (c *ClusterConfig) ApplyEnv() {
+	if v := os.Getenv("K8S_BASE_DIR"); v != "" {
+		c.Paths.BaseDir = v
+	}
+	if v := os.Getenv("K8S_KUBELET_DIR"); v != "" {
+		c.Paths.KubeletDir = v
+	}
+	if v := os.Getenv("K8S_HOST_IP"); v != "" {
+		c.Network.HostIP = v
+	}
+}
+
+// Complete fills in paths that default relative to BaseDir and the
+// versions that default from the Kubernetes minor.
+func (c *ClusterConfig) Complete() {
+	if c.Paths.EtcdDataDir == "" {
+		c.Paths.EtcdDataDir = filepath.Join(c.Paths.BaseDir, "etcd")
+	}
+	if c.Paths.ManifestsDir == "" {
+		c.Paths.ManifestsDir = filepath.Join(c.Paths.BaseDir, "manifests")
+	}
+	if c.Paths.PKIDir == "" {
+		c.Paths.PKIDir = filepath.Join(c.Paths.BaseDir, "pki")
+	}
+	if c.Paths.CacheDir == "" {
+		c.Paths.CacheDir = defaultCacheDir(c.Paths.BaseDir)
+	}
+	if c.Runtime.Arch == "" {
+		c.Runtime.Arch = HostArch()
+	}
+	c.completeVersions()
+	for i := range c.Etcd.Members {
+		if c.Etcd.Members[i].ClientPort == 0 {
+			c.Etcd.Members[i].ClientPort = c.Network.EtcdClientPort
+		}
+		if c.Etcd.Members[i].PeerPort == 0 {
+			c.Etcd.Members[i].PeerPort = c.Network.EtcdPeerPort
+		}
+	}
+}
+
+// defaultCacheDir is ~/.cache/k8s-installer, or a directory next to baseDir
+// when the user has no home directory.
+func defaultCacheDir(baseDir string) string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "k8s-installer")
+	}
+	return filepath.Join(filepath.Dir(baseDir), "k8s-installer-cache")
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+// Validate reports every problem found in the spec at once.
+func (c *ClusterConfig) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.APIVersion != APIVersion {
+		add("apiVersion must be %q, got %q", APIVersion, c.APIVersion)
+	}
+	if c.Kind != Kind {
+		add("kind must be %q, got %q", Kind, c.Kind)
+	}
+
+	for _, f := range []field{
+		{"paths.baseDir", c.Paths.BaseDir},
+		{"paths.kubeletDir", c.Paths.KubeletDir},
+		{"paths.logDir", c.Paths.LogDir},
+		{"paths.cniConfDir", c.Paths.CNIConfDir},
+		{"paths.cniBinDir", c.Paths.CNIBinDir},
+		{"paths.cniStateDir", c.Paths.CNIStateDir},
+		{"paths.containerdConfig", c.Paths.ContainerdConfig},
+		{"paths.containerdSocket", c.Paths.ContainerdSocket},
+		{"paths.systemdUnitDir", c.Paths.SystemdUnitDir},
+	} {
+		if f.value == "" {
+			add("%s must not be empty", f.name)
+		}
+	}
+
+	if net.ParseIP(c.Network.HostIP) == nil {
+		add("network.hostIP %q is not a valid IP address", c.Network.HostIP)
+	}
+	_, serviceNet, err := net.ParseCIDR(c.Network.ServiceCIDR)
+	if err != nil {
+		add("network.serviceCIDR %q is invalid: %v", c.Network.ServiceCIDR, err)
+	}
+	_, podNet, err := net.ParseCIDR(c.Network.PodSubnet)
+	if err != nil {
+		add("network.podSubnet %q is invalid: %v", c.Network.PodSubnet, err)
+	}
+	if serviceNet != nil && podNet != nil &&
+		(serviceNet.Contains(podNet.IP) || podNet.Contains(serviceNet.IP)) {
+		add("network.serviceCIDR %s overlaps network.podSubnet %s", serviceNet, podNet)
+	}
+	if dns := net.ParseIP(c.Network.ClusterDNS); dns == nil {
+		add("network.clusterDNS %q is not a valid IP address", c.Network.ClusterDNS)
+	} else if serviceNet != nil && !serviceNet.Contains(dns) {
+		add("network.clusterDNS %s is outside network.serviceCIDR %s", dns, serviceNet)
+	}
+	if c.Network.ClusterDomain == "" {
+		add("network.clusterDomain must not be empty")
+	}
+	if c.Network.CNINetworkName == "" || c.Network.CNIBridge == "" {
+		add("network.cniNetworkName and network.cniBridge must not be empty")
+	}
+
+	ports := map[int]string{}
+	for _, p := range []struct {
+		name string
+		port int
+	}{
+		{"network.apiServerPort", c.Network.APIServerPort},
+		{"network.etcdClientPort", c.Network.EtcdClientPort},
+		{"network.etcdPeerPort", c.Network.EtcdPeerPort},
+	} {
+		if p.port < 1 || p.port > 65535 {
+			add("%s %d is out of range", p.name, p.port)
+			continue
+		}
+		if other, dup := ports[p.port]; dup {
+			add("%s and %s both use port %d", p.name, other, p.port)
+		}
+		ports[p.port] = p.name
+	}
+
+	c.validateEtcd(add)
+	c.validateDownloads(add)
+	c.validatePKI(add)
+	c.validateAPIServer(add)
+
+	c.validateVersions(add)
+	for _, f := range []field{
+		{"versions.runc", c.Versions.Runc},
+		{"versions.cniPlugins", c.Versions.CNIPlugins},
+		{"versions.pauseImage", c.Versions.PauseImage},
+		{"versions.etcd", c.Versions.Etcd},
+		{"versions.testImage", c.Versions.TestImage},
+		{"versions.imageRepository", c.Versions.ImageRepository},
+		{"versions.etcdImage", c.Versions.EtcdImage},
+	} {
+		if f.value == "" {
+			add("%s must not be empty", f.name)
+		}
+	}
+
+	if c.Kubelet.MaxPods < 1 {
+		add("kubelet.maxPods must be positive, got %d", c.Kubelet.MaxPods)
+	}
+	switch c.Kubelet.CgroupDriver {
+	case "cgroupfs", "systemd":
+	default:
+		add("kubelet.cgroupDriver must be cgroupfs or systemd, got %q", c.Kubelet.CgroupDriver)
+	}
+
+	switch c.Runtime.Init {
+	case InitProcess, InitSystemd:
+	default:
+		add("runtime.init must be %s or %s, got %q", InitProcess, InitSystemd, c.Runtime.Init)
+	}
+	switch c.Runtime.ControlPlane {
+	case ControlPlaneHost, ControlPlaneStaticPod:
+	default:
+		add("runtime.controlPlane must be %s or %s, got %q", ControlPlaneHost, ControlPlaneStaticPod, c.Runtime.ControlPlane)
+	}
+	if !slices.Contains(SupportedArches, c.Runtime.Arch) {
+		hint := ""
+		if c.Runtime.Arch == "arm" {
+			hint = " (containerd, etcd and kubebuilder-tools publish no 32-bit ARM builds; use a 64-bit OS)"
+		}
+		add("runtime.arch must be one of %s, got %q%s", strings.Join(SupportedArches, ", "), c.Runtime.Arch, hint)
+	}
+	switch c.Security.Profile {
+	case SecurityDefault, SecurityHardened:
+	default:
+		add("security.profile must be %s or %s, got %q", SecurityDefault, SecurityHardened, c.Security.Profile)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid cluster config: %w", errors.Join(errs...))
+}
+
+var memberName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func (c *ClusterConfig) validateEtcd(add func(string, ...any)) {
+	if c.Etcd.ClusterToken == "" {
+		add("etcd.clusterToken must not be empty")
+	}
+
+	// Every member endpoint must be unique, and local members must not
+	// collide with the API server.
+	endpoints := map[string]string{
+		net.JoinHostPort(c.Network.HostIP, strconv.Itoa(c.Network.APIServerPort)): "network.apiServerPort",
+	}
+	names := map[string]bool{}
+	for i, m := range c.Etcd.Members {
+		prefix := fmt.Sprintf("etcd.members[%d]", i)
+		if !memberName.MatchString(m.Name) {
+			add("%s.name %q must consist of lower case letters, digits and '-'", prefix, m.Name)
+		} else if names[m.Name] {
+			add("%s.name %q is used twice", prefix, m.Name)
+		}
+		names[m.Name] = true
+
+		if net.ParseIP(m.Host) == nil {
+			add("%s.host %q is not a valid IP address", prefix, m.Host)
+			continue
+		}
+		for _, p := range []struct {
+			name string
+			port int
+		}{
+			{"clientPort", m.ClientPort},
+			{"peerPort", m.PeerPort},
+		} {
+			if p.port < 1 || p.port > 65535 {
+				add("%s.%s %d is out of range", prefix, p.name, p.port)
+				continue
+			}
+			eps := []string{net.JoinHostPort(m.Host, strconv.Itoa(p.port))}
+			// Local members also serve clients on loopback.
+			if p.name == "clientPort" && c.IsLocal(m.Host) && !net.ParseIP(m.Host).IsLoopback() {
+				eps = append(eps, net.JoinHostPort("127.0.0.1", strconv.Itoa(p.port)))
+			}
+			for _, ep := range eps {
+				if other, dup := endpoints[ep]; dup {
+					add("%s.%s: %s is already used by %s", prefix, p.name, ep, other)
+				}
+				endpoints[ep] = prefix + "." + p.name
+			}
+		}
+	}
+}
+
+// EtcdMembers returns the declared members, or the implicit single member.
+func (c *ClusterConfig) EtcdMembers() []EtcdMember {
+	if len(c.Etcd.Members) > 0 {
+		return c.Etcd.Members
+	}
+	return []EtcdMember{{
+		Name:       "default",
+		Host:       c.Network.HostIP,
+		ClientPort: c.Network.EtcdClientPort,
+		PeerPort:   c.Network.EtcdPeerPort,
+	}}
+}
+
+// IsLocal reports whether host is this machine as far as the spec knows:
+// network.hostIP or a loopback address.
+func (c *ClusterConfig) IsLocal(host string) bool {
+	ip := net.ParseIP(host)
+	return host == c.Network.HostIP || (ip != nil && ip.IsLoopback())
+}
+
+// APIServerURL is the URL local clients use to reach the API server.
+func (c *ClusterConfig) APIServerURL() string {
+	return fmt.Sprintf("https://127.0.0.1:%d", c.Network.APIServerPort)
+}
+
+// Hardened reports whether the hardened security profile is selected.
+func (c *ClusterConfig) Hardened() bool {
+	return c.Security.Profile == SecurityHardened
+}
+
+// ComponentKubeconfig is where the kubeconfig of a component other than
+// the kubelet (controller-manager, scheduler, kube-proxy) is written.
+func (c *ClusterConfig) ComponentKubeconfig(name string) string {
+	return filepath.Join(c.Paths.PKIDir, name+".kubeconfig")
+}
+
+// EtcdClientURL returns the etcd client endpoint on the given host.
+func (c *ClusterConfig) EtcdClientURL(host string) string {
+	return fmt.Sprintf("https://%s:%d", host, c.Network.EtcdClientPort)
+}
+
+// EtcdPeerURL returns the etcd peer endpoint on the given host.
+func (c *ClusterConfig) EtcdPeerURL(host string) string {
+	return fmt.Sprintf("https://%s:%d", host, c.Network.EtcdPeerPort)
+}
+
+// EtcdPKIDir holds the etcd CA and the server, peer and health check
+// certificates; etcd has its own CA so that a Kubernetes client
+// certificate never grants access to etcd.
+func (c *ClusterConfig) EtcdPKIDir() string {
+	return filepath.Join(c.Paths.PKIDir, "etcd")
+}
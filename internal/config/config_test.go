@@ -0,0 +1,429 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	cfg.Complete()
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Default config is invalid: %v", err)
+	}
+
+	if cfg.Paths.EtcdDataDir != filepath.Join(DefaultBaseDir, "etcd") {
+		t.Errorf("Expected etcd data dir under base dir, got '%s'", cfg.Paths.EtcdDataDir)
+	}
+	if cfg.Paths.CacheDir == "" || strings.HasPrefix(cfg.Paths.CacheDir, DefaultBaseDir+"/") {
+		t.Errorf("Expected the download cache outside base dir, got '%s'", cfg.Paths.CacheDir)
+	}
+}
+
+func TestLoadOverridesDefaults(t *testing.T) {
+	path := writeConfig(t, `apiVersion: installer.k8s.io/v1alpha1
+kind: ClusterConfig
+paths:
+  baseDir: /opt/k8s
+network:
+  hostIP: 192.168.1.10
+  apiServerPort: 8443
+versions:
+  kubernetes: v1.30.2
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cfg.Complete()
+
+	if cfg.Paths.BaseDir != "/opt/k8s" {
+		t.Errorf("Expected baseDir '/opt/k8s', got '%s'", cfg.Paths.BaseDir)
+	}
+	if cfg.Paths.PKIDir != "/opt/k8s/pki" {
+		t.Errorf("Expected pkiDir '/opt/k8s/pki', got '%s'", cfg.Paths.PKIDir)
+	}
+	if cfg.Paths.KubeletDir != DefaultKubeletDir {
+		t.Errorf("Expected default kubeletDir, got '%s'", cfg.Paths.KubeletDir)
+	}
+	if cfg.APIServerURL() != "https://127.0.0.1:8443" {
+		t.Errorf("Unexpected API server URL: %s", cfg.APIServerURL())
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Loaded config is invalid: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, `apiVersion: installer.k8s.io/v1alpha1
+kind: ClusterConfig
+network:
+  hostIp: 192.168.1.10
+`)
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("Expected error for unknown field")
+	}
+}
+
+func TestLoadRequiresAPIVersion(t *testing.T) {
+	path := writeConfig(t, `paths:
+  baseDir: /opt/k8s
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "apiVersion") {
+		t.Errorf("Expected apiVersion error, got %v", err)
+	}
+}
+
+func TestResolveEnvOverridesFile(t *testing.T) {
+	path := writeConfig(t, `apiVersion: installer.k8s.io/v1alpha1
+kind: ClusterConfig
+network:
+  hostIP: 192.168.1.10
+`)
+	t.Setenv("K8S_HOST_IP", "10.1.2.3")
+
+	cfg, err := Resolve(path)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if cfg.Network.HostIP != "10.1.2.3" {
+		t.Errorf("Expected env to override hostIP, got '%s'", cfg.Network.HostIP)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*ClusterConfig)
+		want   string
+	}{
+		{"bad host IP", func(c *ClusterConfig) { c.Network.HostIP = "nope" }, "network.hostIP"},
+		{"bad service CIDR", func(c *ClusterConfig) { c.Network.ServiceCIDR = "10.0.0.0" }, "network.serviceCIDR"},
+		{"overlapping subnets", func(c *ClusterConfig) { c.Network.PodSubnet = "10.0.0.0/16" }, "overlaps"},
+		{"DNS outside services", func(c *ClusterConfig) { c.Network.ClusterDNS = "10.9.0.10" }, "network.clusterDNS"},
+		{"duplicate ports", func(c *ClusterConfig) { c.Network.EtcdPeerPort = 2379 }, "both use port"},
+		{"bad version", func(c *ClusterConfig) { c.Versions.Kubernetes = "1.30.0" }, "versions.kubernetes"},
+		{"bad cgroup driver", func(c *ClusterConfig) { c.Kubelet.CgroupDriver = "none" }, "kubelet.cgroupDriver"},
+		{"unknown init system", func(c *ClusterConfig) { c.Runtime.Init = "upstart" }, "runtime.init"},
+		{"unknown control plane", func(c *ClusterConfig) { c.Runtime.ControlPlane = "vm" }, "runtime.controlPlane"},
+		{"unknown security profile", func(c *ClusterConfig) { c.Security.Profile = "paranoid" }, "security.profile"},
+		{"bad etcd member name", func(c *ClusterConfig) { c.Etcd.Members = []EtcdMember{{Name: "Etcd_1", Host: "10.0.0.1"}} }, "etcd.members[0].name"},
+		{"duplicate etcd member", func(c *ClusterConfig) {
+			c.Etcd.Members = []EtcdMember{{Name: "a", Host: "10.1.0.1"}, {Name: "a", Host: "10.1.0.2"}}
+		}, "used twice"},
+		{"bad etcd member host", func(c *ClusterConfig) { c.Etcd.Members = []EtcdMember{{Name: "a", Host: "etcd-a"}} }, "etcd.members[0].host"},
+		{"duplicate etcd endpoint", func(c *ClusterConfig) {
+			c.Etcd.Members = []EtcdMember{{Name: "a", Host: "10.1.0.1"}, {Name: "b", Host: "10.1.0.1"}}
+		}, "already used by etcd.members[0].clientPort"},
+		{"etcd on the API server port", func(c *ClusterConfig) {
+			c.Etcd.Members = []EtcdMember{{Name: "a", Host: c.Network.HostIP, ClientPort: c.Network.APIServerPort}}
+		}, "already used by network.apiServerPort"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			cfg.Complete()
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing '%s', got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestEtcdMembers(t *testing.T) {
+	cfg := Default()
+	cfg.Network.HostIP = "10.1.0.1"
+	cfg.Complete()
+
+	implicit := cfg.EtcdMembers()
+	if len(implicit) != 1 || implicit[0].ClientURL() != "https://10.1.0.1:2379" || implicit[0].PeerURL() != "https://10.1.0.1:2380" {
+		t.Errorf("Unexpected implicit member: %+v", implicit)
+	}
+
+	cfg.Etcd.Members = []EtcdMember{
+		{Name: "a", Host: "10.1.0.1"},
+		{Name: "b", Host: "10.1.0.2", ClientPort: 12379},
+		{Name: "c", Host: "10.1.0.3"},
+	}
+	cfg.Complete()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected three members to be valid, got %v", err)
+	}
+	if got := cfg.EtcdMembers()[1]; got.ClientPort != 12379 || got.PeerPort != 2380 {
+		t.Errorf("Expected only missing ports to be defaulted, got %+v", got)
+	}
+	if !cfg.IsLocal("10.1.0.1") || !cfg.IsLocal("127.0.0.1") || cfg.IsLocal("10.1.0.2") {
+		t.Error("IsLocal must match the host IP and loopback only")
+	}
+}
+
+func TestUnameArch(t *testing.T) {
+	for machine, want := range map[string]string{
+		"x86_64":  "amd64",
+		"aarch64": "arm64",
+		"armv7l":  "arm",
+		"s390x":   "s390x",
+	} {
+		if got := unameArch(machine); got != want {
+			t.Errorf("unameArch(%q) = %q, want %q", machine, got, want)
+		}
+	}
+}
+
+func TestValidateRejectsUnsupportedArch(t *testing.T) {
+	cfg := Default()
+	cfg.Runtime.Arch = "arm"
+	cfg.Complete()
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "runtime.arch") || !strings.Contains(err.Error(), "64-bit") {
+		t.Errorf("Expected 32-bit ARM to be rejected with a hint, got %v", err)
+	}
+}
+
+func TestMirrorURL(t *testing.T) {
+	cfg := Default()
+	cfg.Downloads.Mirrors = map[string]string{
+		"https://github.com":                                "https://nexus.corp/repository/github/",
+		"https://github.com/etcd-io":                        "https://nexus.corp/repository/etcd",
+		"https://storage.googleapis.com/kubebuilder-tools/": "https://artifactory.corp/kubebuilder",
+	}
+
+	for in, want := range map[string]string{
+		"https://github.com/opencontainers/runc/releases/download/v1.2.6/runc.amd64":                   "https://nexus.corp/repository/github/opencontainers/runc/releases/download/v1.2.6/runc.amd64",
+		"https://github.com/etcd-io/etcd/releases/download/v3.5.12/SHA256SUMS":                         "https://nexus.corp/repository/etcd/etcd/releases/download/v3.5.12/SHA256SUMS",
+		"https://storage.googleapis.com/kubebuilder-tools/kubebuilder-tools-1.30.0-linux-amd64.tar.gz": "https://artifactory.corp/kubebuilder/kubebuilder-tools-1.30.0-linux-amd64.tar.gz",
+		"https://github.company.com/runc":                                                              "https://github.company.com/runc",
+		"https://dl.k8s.io/v1.30.0/bin/linux/amd64/kubelet":                                            "https://dl.k8s.io/v1.30.0/bin/linux/amd64/kubelet",
+	} {
+		if got := cfg.MirrorURL(in); got != want {
+			t.Errorf("MirrorURL(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestValidateDownloads(t *testing.T) {
+	cfg := Default()
+	cfg.Downloads = Downloads{
+		Mirrors:  map[string]string{"dl.k8s.io": "ftp://mirror"},
+		Proxy:    "proxy:3128",
+		CABundle: "corp-ca.pem",
+	}
+	cfg.Complete()
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected invalid download settings to be rejected")
+	}
+	for _, field := range []string{"upstream", "downloads.mirrors[dl.k8s.io]", "downloads.proxy", "downloads.caBundle"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected an error about %s, got %v", field, err)
+		}
+	}
+}
+
+func TestLoadPKI(t *testing.T) {
+	path := writeConfig(t, `apiVersion: installer.k8s.io/v1alpha1
+kind: ClusterConfig
+pki:
+  keyAlgorithm: ecdsa-p256
+  certificates:
+    apiserver:
+      keyAlgorithm: rsa-4096
+      validity: 2160h
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cfg.Complete()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Loaded config is invalid: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ca   bool
+		want CertificateSpec
+	}{
+		{"apiserver", false, CertificateSpec{"rsa-4096", 2160 * time.Hour}},
+		{"admin", false, CertificateSpec{"ecdsa-p256", DefaultCertificateValidity}},
+		{"ca", true, CertificateSpec{"ecdsa-p256", DefaultCAValidity}},
+	}
+	for _, tt := range tests {
+		if got := cfg.CertificateSpec(tt.name, tt.ca); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestValidatePKI(t *testing.T) {
+	cfg := Default()
+	cfg.PKI.KeyAlgorithm = "ed25519"
+	cfg.PKI.CertificateValidity = time.Minute
+	cfg.PKI.Certificates = map[string]CertificateSpec{"apiserver": {KeyAlgorithm: "dsa"}}
+	cfg.Complete()
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected invalid PKI settings to be rejected")
+	}
+	for _, field := range []string{"pki.certificateValidity", "pki.certificates[apiserver].keyAlgorithm", "service account key"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected an error about %s, got %v", field, err)
+		}
+	}
+
+	cfg.PKI.CertificateValidity = 0
+	cfg.PKI.Certificates = map[string]CertificateSpec{"sa": {KeyAlgorithm: "ecdsa-p256"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected ed25519 with an ECDSA service account key to be valid, got %v", err)
+	}
+}
+
+func TestServiceIP(t *testing.T) {
+	for cidr, want := range map[string]string{
+		"10.0.0.0/24":  "10.0.0.1",
+		"10.96.0.0/12": "10.96.0.1",
+		"fd00::/108":   "fd00::1",
+	} {
+		cfg := Default()
+		cfg.Network.ServiceCIDR = cidr
+		ip, err := cfg.ServiceIP()
+		if err != nil || ip.String() != want {
+			t.Errorf("%s: expected %s, got %v (%v)", cidr, want, ip, err)
+		}
+	}
+}
+
+func TestValidateAPIServerSANs(t *testing.T) {
+	cfg := Default()
+	cfg.APIServer.CertSANs = []string{"203.0.113.5", "K8s.Example.com", "*.apps.example.com", "bad_name"}
+	cfg.Complete()
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "apiServer.certSANs[3]") {
+		t.Fatalf("Expected only the invalid SAN to be rejected, got %v", err)
+	}
+	if strings.Contains(err.Error(), "certSANs[1]") || strings.Contains(err.Error(), "certSANs[2]") {
+		t.Errorf("Expected DNS names and wildcards to be accepted, got %v", err)
+	}
+
+	dnsNames, ips, err := SplitSANs(cfg.APIServer.CertSANs[:3])
+	if err != nil || len(ips) != 1 || strings.Join(dnsNames, ",") != "k8s.example.com,*.apps.example.com" {
+		t.Errorf("Unexpected split: %v %v %v", dnsNames, ips, err)
+	}
+}
+
+func TestCompleteTakesVersionsFromMatrix(t *testing.T) {
+	cfg := Default()
+	cfg.Versions.Kubernetes = "v1.31.2"
+	cfg.Versions.Crictl = "v1.31.0"
+	cfg.Complete()
+
+	if cfg.Versions.Kubebuilder != "1.31.0" || cfg.Versions.Etcd != "v3.5.15" {
+		t.Errorf("Expected 1.31 component versions, got %+v", cfg.Versions)
+	}
+	if cfg.Versions.Crictl != "v1.31.0" {
+		t.Errorf("Expected a pinned crictl to be kept, got %s", cfg.Versions.Crictl)
+	}
+	if cfg.Versions.EtcdImage != "registry.k8s.io/etcd:3.5.15-0" || cfg.Versions.PauseImage != "registry.k8s.io/pause:3.10" {
+		t.Errorf("Unexpected images %s, %s", cfg.Versions.EtcdImage, cfg.Versions.PauseImage)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected 1.31 to be valid: %v", err)
+	}
+}
+
+func TestValidateRejectsVersionSkew(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Versions)
+		want   string
+	}{
+		{"unsupported minor", func(v *Versions) { v.Kubernetes = "v1.29.4" }, "not supported"},
+		{"unresolved latest", func(v *Versions) { v.Kubernetes = "latest-1.31" }, "resolved"},
+		{"apiserver older than kubelet", func(v *Versions) { v.Kubernetes, v.Kubebuilder = "v1.31.0", "1.30.0" }, "versions.kubebuilder"},
+		{"apiserver two minors newer", func(v *Versions) { v.Kubebuilder = "1.32.0" }, "versions.kubebuilder"},
+		{"crictl far away", func(v *Versions) { v.Crictl = "v1.28.0" }, "versions.crictl"},
+		{"old containerd", func(v *Versions) { v.Containerd = "1.6.36" }, "too old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg.Versions)
+			cfg.Complete()
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing '%s', got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLatestMinor(t *testing.T) {
+	if minor, ok, err := LatestMinor("latest-1.31"); minor != "1.31" || !ok || err != nil {
+		t.Errorf("Unexpected latest-1.31: %q, %v, %v", minor, ok, err)
+	}
+	if minor, ok, _ := LatestMinor("latest"); minor != Releases[len(Releases)-1].Minor || !ok {
+		t.Errorf("Expected latest to pick the newest minor, got %q", minor)
+	}
+	if _, ok, err := LatestMinor("latest-1.20"); !ok || err == nil {
+		t.Errorf("Expected an unsupported minor to be rejected, got %v", err)
+	}
+	if _, ok, _ := LatestMinor("v1.31.0"); ok {
+		t.Error("Expected a release to be left alone")
+	}
+}
+
+func TestCheckUpgrade(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"v1.30.0", "v1.30.3", ""},
+		{"v1.30.3", "v1.31.1", ""},
+		{"v1.30.0", "v1.30.0", "already installed"},
+		{"v1.31.1", "v1.30.3", "downgrade"},
+		{"v1.29.0", "v1.31.0", "one minor at a time, to 1.30"},
+		{"", "v1.31.0", "not a release"},
+	}
+	for _, tt := range tests {
+		err := CheckUpgrade(tt.from, tt.to)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("CheckUpgrade(%q, %q): unexpected error %v", tt.from, tt.to, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CheckUpgrade(%q, %q): expected error containing '%s', got %v", tt.from, tt.to, tt.want, err)
+		}
+	}
+}
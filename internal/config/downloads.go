@@ -0,0 +1,64 @@
+package config
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Downloads configures where artifacts are fetched from.
+type Downloads struct {
+	// Mirrors maps upstream URL prefixes (https://dl.k8s.io,
+	// https://github.com, https://storage.googleapis.com/kubebuilder-tools)
+	// to the URL of a mirror serving the same paths, e.g. an Artifactory
+	// or Nexus remote repository. The longest matching prefix wins.
+	Mirrors map[string]string `yaml:"mirrors,omitempty"`
+	// Proxy is an HTTP(S) proxy URL for downloads. When empty the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables apply.
+	Proxy string `yaml:"proxy,omitempty"`
+	// CABundle is a PEM file with CAs to trust in addition to the system
+	// ones, e.g. the CA of a mirror or a TLS-intercepting proxy.
+	CABundle string `yaml:"caBundle,omitempty"`
+}
+
+// MirrorURL returns the URL rawURL is fetched from: rawURL itself, or the
+// mirror of its longest matching upstream prefix.
+func (c *ClusterConfig) MirrorURL(rawURL string) string {
+	best := ""
+	for upstream := range c.Downloads.Mirrors {
+		prefix := strings.TrimSuffix(upstream, "/")
+		if (rawURL == prefix || strings.HasPrefix(rawURL, prefix+"/")) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return rawURL
+	}
+	mirror := c.Downloads.Mirrors[best]
+	if mirror == "" {
+		mirror = c.Downloads.Mirrors[best+"/"]
+	}
+	return strings.TrimSuffix(mirror, "/") + rawURL[len(best):]
+}
+
+func (c *ClusterConfig) validateDownloads(add func(string, ...any)) {
+	for upstream, mirror := range c.Downloads.Mirrors {
+		if !isHTTPURL(upstream) {
+			add("downloads.mirrors: upstream %q must be an http(s) URL", upstream)
+		}
+		if !isHTTPURL(mirror) {
+			add("downloads.mirrors[%s] must be an http(s) URL, got %q", upstream, mirror)
+		}
+	}
+	if c.Downloads.Proxy != "" && !isHTTPURL(c.Downloads.Proxy) {
+		add("downloads.proxy must be an http(s) URL, got %q", c.Downloads.Proxy)
+	}
+	if c.Downloads.CABundle != "" && !filepath.IsAbs(c.Downloads.CABundle) {
+		add("downloads.caBundle must be an absolute path, got %q", c.Downloads.CABundle)
+	}
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
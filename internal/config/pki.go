@@ -0,0 +1,94 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/pki"
+)
+
+// Default key algorithm and validity periods of generated certificates.
+const (
+	DefaultKeyAlgorithm        = pki.RSA2048
+	DefaultCAValidity          = 10 * 365 * 24 * time.Hour
+	DefaultCertificateValidity = 365 * 24 * time.Hour
+)
+
+// PKI controls the keys and certificates the installer generates. Existing
+// keys are kept, so a new key algorithm applies to certificates issued
+// after the change.
+type PKI struct {
+	// KeyAlgorithm is the key type of every generated key, one of
+	// pki.KeyAlgorithms. ECDSA keys are generated much faster than RSA.
+	KeyAlgorithm pki.KeyAlgorithm `yaml:"keyAlgorithm,omitempty"`
+	// CAValidity and CertificateValidity are how long new CA and leaf
+	// certificates are valid, e.g. 87600h.
+	CAValidity          time.Duration `yaml:"caValidity,omitempty"`
+	CertificateValidity time.Duration `yaml:"certificateValidity,omitempty"`
+	// Certificates overrides the settings above for single certificates,
+	// keyed by the name "certs check-expiration" lists, e.g. "apiserver"
+	// or "etcd/server". "sa" is the service account signing key.
+	Certificates map[string]CertificateSpec `yaml:"certificates,omitempty"`
+}
+
+// CertificateSpec is the key algorithm and validity of one certificate.
+type CertificateSpec struct {
+	KeyAlgorithm pki.KeyAlgorithm `yaml:"keyAlgorithm,omitempty"`
+	Validity     time.Duration    `yaml:"validity,omitempty"`
+}
+
+// CertificateSpec returns the settings of the named certificate: its entry
+// in pki.certificates, completed from the defaults for CAs or leaves.
+func (c *ClusterConfig) CertificateSpec(name string, ca bool) CertificateSpec {
+	spec := c.PKI.Certificates[name]
+	if spec.KeyAlgorithm == "" {
+		spec.KeyAlgorithm = c.PKI.KeyAlgorithm
+	}
+	if spec.KeyAlgorithm == "" {
+		spec.KeyAlgorithm = DefaultKeyAlgorithm
+	}
+	if spec.Validity == 0 {
+		spec.Validity = c.PKI.CertificateValidity
+		if ca {
+			spec.Validity = c.PKI.CAValidity
+		}
+	}
+	if spec.Validity == 0 {
+		spec.Validity = DefaultCertificateValidity
+		if ca {
+			spec.Validity = DefaultCAValidity
+		}
+	}
+	return spec
+}
+
+func (c *ClusterConfig) validatePKI(add func(string, ...any)) {
+	algs := make([]string, len(pki.KeyAlgorithms))
+	for n, alg := range pki.KeyAlgorithms {
+		algs[n] = string(alg)
+	}
+	checkAlg := func(field string, alg pki.KeyAlgorithm) {
+		if alg != "" && !slices.Contains(pki.KeyAlgorithms, alg) {
+			add("%s must be one of %s, got %q", field, strings.Join(algs, ", "), alg)
+		}
+	}
+	checkValidity := func(field string, d time.Duration) {
+		if d < 0 || (d > 0 && d < time.Hour) {
+			add("%s must be at least 1h, got %s", field, d)
+		}
+	}
+
+	checkAlg("pki.keyAlgorithm", c.PKI.KeyAlgorithm)
+	checkValidity("pki.caValidity", c.PKI.CAValidity)
+	checkValidity("pki.certificateValidity", c.PKI.CertificateValidity)
+	for name, spec := range c.PKI.Certificates {
+		checkAlg("pki.certificates["+name+"].keyAlgorithm", spec.KeyAlgorithm)
+		checkValidity("pki.certificates["+name+"].validity", spec.Validity)
+	}
+	// The API server only verifies service account tokens signed with RSA
+	// or ECDSA.
+	if c.CertificateSpec("sa", false).KeyAlgorithm == pki.Ed25519 {
+		add("the service account key can not be %s; set pki.certificates[sa].keyAlgorithm", pki.Ed25519)
+	}
+}
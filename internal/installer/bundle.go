@@ -0,0 +1,110 @@
+package installer
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/bundle"
+)
+
+// bundleDir is where an install bundle is extracted.
+func (i *Installer) bundleDir() string {
+	return filepath.Join(i.baseDir, "bundle")
+}
+
+// images lists the container images an offline install needs.
+func (i *Installer) images() []string {
+	return append(i.services.Images(), i.cluster.Versions.TestImage)
+}
+
+// CreateBundle downloads every artifact and image for the configured
+// versions and packs them into an install bundle at output.
+func (i *Installer) CreateBundle(output string) error {
+	pinned, err := pinnedChecksums()
+	if err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp("", "k8s-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	manifest := &bundle.Manifest{Created: time.Now().UTC(), Arch: i.cluster.Runtime.Arch}
+	if err := os.MkdirAll(filepath.Join(staging, "artifacts"), 0755); err != nil {
+		return err
+	}
+	c := i.DownloadCache()
+	for _, dl := range i.downloads() {
+		rel := path.Join("artifacts", path.Base(dl.url))
+		dl.destPath = filepath.Join(staging, filepath.FromSlash(rel))
+		sum, err := i.fetchCached(c, pinned, dl)
+		if err != nil {
+			return err
+		}
+		manifest.Artifacts = append(manifest.Artifacts, bundle.Artifact{URL: dl.url, Path: rel, SHA256: sum})
+	}
+
+	puller := &bundle.Puller{Client: &http.Client{Transport: i.downloader.Client.Transport}, Arch: i.cluster.Runtime.Arch}
+	for _, ref := range i.images() {
+		log.Printf("  Pulling %s...", ref)
+		img, err := bundle.PullImage(puller, ref, staging)
+		if err != nil {
+			return err
+		}
+		manifest.Images = append(manifest.Images, img)
+	}
+
+	log.Printf("  Writing %s...", output)
+	return bundle.Write(staging, manifest, output)
+}
+
+// copyFromBundle puts the bundled copy of an artifact where the download
+// would have gone and returns its digest. Bundle files are verified when the
+// bundle is opened; an artifact pinned in checksums.txt must also match the
+// pinned digest.
+func (i *Installer) copyFromBundle(manifest *bundle.Manifest, pinned map[string]string, dl download) (string, error) {
+	a, ok := manifest.Artifact(dl.url)
+	if !ok {
+		return "", fmt.Errorf("bundle has no %s; was it created for other versions?", dl.url)
+	}
+	if sum, ok := pinned[dl.url]; ok && sum != a.SHA256 {
+		return "", fmt.Errorf("bundled %s does not match the pinned checksum: expected sha256 %s, got %s", path.Base(dl.url), sum, a.SHA256)
+	}
+
+	log.Printf("  Copying %s from bundle...", path.Base(dl.url))
+	if err := copyFile(filepath.Join(i.bundleDir(), filepath.FromSlash(a.Path)), dl.destPath); err != nil {
+		return "", err
+	}
+	return a.SHA256, nil
+}
+
+// ImportImages imports the bundled images into containerd's k8s.io
+// namespace so that the kubelet finds them without pulling.
+func (i *Installer) ImportImages() error {
+	if i.config.Bundle == "" {
+		log.Println("  No bundle given, images are pulled by containerd")
+		return nil
+	}
+	manifest, err := bundle.Open(i.bundleDir())
+	if err != nil {
+		return fmt.Errorf("failed to open extracted bundle (rerun the download step): %w", err)
+	}
+
+	ctr := filepath.Join(i.baseDir, "bin", "ctr")
+	for _, img := range manifest.Images {
+		log.Printf("  Importing %s...", img.Ref)
+		cmd := exec.Command(ctr, "--address", i.cluster.Paths.ContainerdSocket, "--namespace", "k8s.io",
+			"images", "import", filepath.Join(i.bundleDir(), filepath.FromSlash(img.Path)))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to import %s: %w, output: %s", img.Ref, err, output)
+		}
+	}
+	return nil
+}
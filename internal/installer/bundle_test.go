@@ -0,0 +1,207 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/bundle"
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+// fakeArchive is a tar.gz with the members spec extracts, each nested
+// under spec.strip directories.
+func fakeArchive(t *testing.T, spec *extractSpec) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	prefix := strings.Repeat("release/", spec.strip)
+	content := []byte("#!/bin/sh\n")
+	for _, member := range append(spec.members, "unused") {
+		if err := tw.WriteHeader(&tar.Header{Name: prefix + member, Mode: 0755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(content)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// fakeBundle fabricates a bundle with every artifact of inst and one image.
+func fakeBundle(t *testing.T, inst *Installer) string {
+	staging := t.TempDir()
+	m := &bundle.Manifest{Arch: inst.cluster.Runtime.Arch}
+	files := map[string][]byte{}
+	for _, dl := range inst.downloads() {
+		content := []byte("#!/bin/sh\necho " + path.Base(dl.url) + "\n")
+		if dl.extract != nil {
+			content = fakeArchive(t, dl.extract)
+		}
+		rel := "artifacts/" + path.Base(dl.url)
+		files[rel] = content
+		m.Artifacts = append(m.Artifacts, bundle.Artifact{URL: dl.url, Path: rel})
+	}
+	files["images/pause.tar"] = []byte("image")
+	m.Images = append(m.Images, bundle.Image{Ref: inst.cluster.Versions.PauseImage, Path: "images/pause.tar"})
+
+	for rel, content := range files {
+		p := filepath.Join(staging, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range m.Artifacts {
+		m.Artifacts[i].SHA256, _ = utils.SHA256File(filepath.Join(staging, m.Artifacts[i].Path))
+	}
+	m.Images[0].SHA256, _ = utils.SHA256File(filepath.Join(staging, "images/pause.tar"))
+
+	output := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := bundle.Write(staging, m, output); err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func newBundleInstaller(t *testing.T) *Installer {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	inst.cluster.Paths.CNIBinDir = filepath.Join(t.TempDir(), "cni")
+	for _, dir := range []string{filepath.Join(inst.baseDir, "bin"), inst.cluster.Paths.CNIBinDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inst.config.Bundle = fakeBundle(t, inst)
+	return inst
+}
+
+func TestDownloadBinariesFromBundle(t *testing.T) {
+	inst := newBundleInstaller(t)
+	if err := inst.DownloadBinaries(); err != nil {
+		t.Fatalf("DownloadBinaries failed: %v", err)
+	}
+
+	kubelet := filepath.Join(inst.baseDir, "bin", "kubelet")
+	info, err := os.Stat(kubelet)
+	if err != nil {
+		t.Fatalf("Expected kubelet from the bundle: %v", err)
+	}
+	if info.Mode()&0111 == 0 {
+		t.Errorf("Expected kubelet to be executable, got %v", info.Mode())
+	}
+	if data, _ := os.ReadFile(kubelet); !strings.Contains(string(data), "echo kubelet") {
+		t.Errorf("Unexpected kubelet content %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(inst.baseDir, "bin", "etcdutl")); err != nil {
+		t.Errorf("Expected etcdutl to be extracted from the bundled etcd release: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(inst.baseDir, "bin", "unused")); !os.IsNotExist(err) {
+		t.Errorf("Expected only the listed archive members to be extracted, got %v", err)
+	}
+}
+
+func TestDownloadBinariesRejectsBundleForOtherVersions(t *testing.T) {
+	inst := newBundleInstaller(t)
+	inst.cluster.Versions.Kubernetes = "v1.31.0"
+
+	err := inst.DownloadBinaries()
+	if err == nil || !strings.Contains(err.Error(), "bundle has no") {
+		t.Errorf("Expected missing artifacts to fail, got %v", err)
+	}
+}
+
+func TestDownloadBinariesRejectsBundleForOtherArch(t *testing.T) {
+	inst := newBundleInstaller(t)
+	inst.cluster.Runtime.Arch = config.ArchARM64
+	if inst.cluster.Runtime.Arch == config.HostArch() {
+		inst.cluster.Runtime.Arch = config.ArchAMD64
+	}
+
+	err := inst.DownloadBinaries()
+	if err == nil || !strings.Contains(err.Error(), "bundle was created for linux/") {
+		t.Errorf("Expected a bundle for another arch to be rejected, got %v", err)
+	}
+}
+
+func TestImportImages(t *testing.T) {
+	inst := newBundleInstaller(t)
+	if err := inst.DownloadBinaries(); err != nil {
+		t.Fatalf("DownloadBinaries failed: %v", err)
+	}
+
+	// Replace ctr with a script that records its arguments.
+	record := filepath.Join(t.TempDir(), "ctr.args")
+	script := "#!/bin/sh\necho \"$@\" >> " + record + "\n"
+	if err := os.WriteFile(filepath.Join(inst.baseDir, "bin", "ctr"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inst.ImportImages(); err != nil {
+		t.Fatalf("ImportImages failed: %v", err)
+	}
+	args, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--address " + inst.cluster.Paths.ContainerdSocket + " --namespace k8s.io images import " + filepath.Join(inst.bundleDir(), "images", "pause.tar")
+	if strings.TrimSpace(string(args)) != want {
+		t.Errorf("Expected ctr %s, got %s", want, args)
+	}
+}
+
+func TestDownloadBinariesSkipsInstalledArtifacts(t *testing.T) {
+	inst := newBundleInstaller(t)
+	if err := inst.DownloadBinaries(); err != nil {
+		t.Fatalf("DownloadBinaries failed: %v", err)
+	}
+
+	kubelet := filepath.Join(inst.baseDir, "bin", "kubelet")
+	runc := filepath.Join(inst.baseDir, "bin", "runc")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(kubelet, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(runc, []byte("damaged"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inst.DownloadBinaries(); err != nil {
+		t.Fatalf("Second DownloadBinaries failed: %v", err)
+	}
+	if info, err := os.Stat(kubelet); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("Expected the installed kubelet to be kept")
+	}
+	if data, _ := os.ReadFile(runc); !strings.Contains(string(data), "echo runc") {
+		t.Errorf("Expected the damaged runc to be installed again, got %q", data)
+	}
+}
+
+func TestSkipDownloadRequiresInstalledBinaries(t *testing.T) {
+	inst := newBundleInstaller(t)
+	inst.config.SkipDownload = true
+
+	err := inst.DownloadBinaries()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(inst.baseDir, "bin", "kubelet")) {
+		t.Fatalf("Expected missing kubelet to be reported, got %v", err)
+	}
+
+	for _, dl := range inst.downloads() {
+		for _, p := range dl.installedPaths() {
+			os.MkdirAll(filepath.Dir(p), 0755)
+			if err := os.WriteFile(p, nil, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := inst.DownloadBinaries(); err != nil {
+		t.Errorf("Expected installed binaries to be accepted, got %v", err)
+	}
+}
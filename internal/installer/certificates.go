@@ -1,76 +1,66 @@
 package installer
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
+	"bytes"
+	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/pem"
 	"fmt"
 	"log"
-	"math/big"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/pki"
+	"github.com/dereban25/k8s-installer/internal/utils"
 )
 
+// renewBefore is how long before expiry a certificate is reissued when the
+// installation is rerun.
+const renewBefore = 30 * 24 * time.Hour
+
+// GenerateCertificates creates the missing CAs, certificates and keys. A CA
+// already in pkiDir, including an own or intermediate company CA, is used
+// as is, and the certificates it issued are kept while they still fit, so
+// a rerun does not break existing kubeconfigs.
 func (i *Installer) GenerateCertificates() error {
-	pkiDir := filepath.Join(i.baseDir, "pki")
-	
+	pkiDir := i.cluster.Paths.PKIDir
+
 	if _, err := os.Stat(pkiDir); os.IsNotExist(err) {
 		return fmt.Errorf("PKI directory does not exist: %s (run CreateDirectories first)", pkiDir)
 	}
 
-	log.Println("  Generating CA certificate...")
-	caKey, caCert, err := i.generateCA()
+	caKey, caCert, err := i.ensureCA(filepath.Join(pkiDir, "ca"), pkix.Name{
+		CommonName:   "kubernetes-ca",
+		Organization: []string{"Kubernetes"},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to generate CA: %w", err)
-	}
-
-	if err := i.saveCertificate(filepath.Join(pkiDir, "ca.crt"), caCert); err != nil {
-		return err
-	}
-	if err := i.savePrivateKey(filepath.Join(pkiDir, "ca.key"), caKey); err != nil {
 		return err
 	}
 
-	log.Println("  Generating admin certificate...")
-	adminKey, adminCert, err := i.generateClientCert(caKey, caCert, "admin", "system:masters")
-	if err != nil {
-		return fmt.Errorf("failed to generate admin cert: %w", err)
-	}
-
-	if err := i.saveCertificate(filepath.Join(pkiDir, "admin.crt"), adminCert); err != nil {
+	if _, _, err := i.ensureCertificate(filepath.Join(pkiDir, "admin"), caKey, caCert, clientCertTemplate("admin", "system:masters")); err != nil {
 		return err
 	}
-	if err := i.savePrivateKey(filepath.Join(pkiDir, "admin.key"), adminKey); err != nil {
+	if err := i.ensureAPIServerCertificate(caKey, caCert); err != nil {
 		return err
 	}
 
-	log.Println("  Generating API server certificate...")
-	apiKey, apiCert, err := i.generateAPIServerCert(caKey, caCert)
-	if err != nil {
-		return fmt.Errorf("failed to generate API server cert: %w", err)
-	}
-
-	if err := i.saveCertificate(filepath.Join(pkiDir, "apiserver.crt"), apiCert); err != nil {
-		return err
-	}
-	if err := i.savePrivateKey(filepath.Join(pkiDir, "apiserver.key"), apiKey); err != nil {
+	if err := i.generateEtcdCertificates(); err != nil {
 		return err
 	}
 
-	log.Println("  Generating service account keys...")
-	saKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return fmt.Errorf("failed to generate service account key: %w", err)
+	if err := i.ensureServiceAccountKey(); err != nil {
+		return err
 	}
 
-	if err := i.savePrivateKey(filepath.Join(pkiDir, "sa.key"), saKey); err != nil {
+	if err := i.generateComponentCredentials(caKey, caCert); err != nil {
 		return err
 	}
-	if err := i.savePublicKey(filepath.Join(pkiDir, "sa.pub"), &saKey.PublicKey); err != nil {
+	if err := i.generateFrontProxyCertificates(); err != nil {
 		return err
 	}
 
@@ -89,153 +79,362 @@ func (i *Installer) GenerateCertificates() error {
 		log.Printf("Warning: failed to copy CA to kubelet root: %v", err)
 	}
 
-	log.Println("  All certificates generated successfully")
+	log.Println("  All certificates are in place")
 	return nil
 }
 
-func (i *Installer) generateCA() (*rsa.PrivateKey, *x509.Certificate, error) {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	template := &x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			CommonName:   "kubernetes-ca",
-			Organization: []string{"Kubernetes"},
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
+// ensureCA returns the CA in <base>.crt and <base>.key, or creates a
+// self-signed CA for subject when neither exists. <base>.crt may continue
+// with the chain up to a root CA, which the CA must then chain to. That
+// chain is moved to <base>-chain.crt: <base>.crt is the trust anchor for
+// client certificates, and every CA left in it could issue them.
+func (i *Installer) ensureCA(base string, subject pkix.Name) (crypto.Signer, *x509.Certificate, error) {
+	haveCert, haveKey := exists(base+".crt"), exists(base+".key")
+	if haveCert || haveKey {
+		if !haveCert || !haveKey {
+			return nil, nil, fmt.Errorf("found only one of %s.crt and %s.key: provide both to use your own CA, or neither", base, base)
+		}
+		key, certs, err := loadCA(base)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := i.splitCAChain(base, certs); err != nil {
+			return nil, nil, err
+		}
+		log.Printf("  Using existing CA %s.crt (%s)", base, certs[0].Subject)
+		return key, certs[0], nil
+	}
+
+	spec := i.cluster.CertificateSpec(i.certificateName(base), true)
+	log.Printf("  Generating %s certificate (%s)...", subject.CommonName, spec.KeyAlgorithm)
+	key, cert, err := signCertificate(nil, nil, &x509.Certificate{
+		Subject:               subject,
+		NotAfter:              time.Now().Add(spec.Validity),
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		BasicConstraintsValid: true,
 		IsCA:                  true,
+	}, spec.KeyAlgorithm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate %s: %w", subject.CommonName, err)
+	}
+	if err := i.saveKeyPair(base, key, cert); err != nil {
+		return nil, nil, err
 	}
+	return key, cert, nil
+}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+// loadCA reads a CA and checks that the key matches it, that it may sign
+// certificates, is valid and chains to the certificates following it in
+// <base>.crt and <base>-chain.crt. It returns the CA first, then the chain.
+func loadCA(base string) (crypto.Signer, []*x509.Certificate, error) {
+	certs, err := loadCertificates(base + ".crt")
 	if err != nil {
 		return nil, nil, err
 	}
-
-	cert, err := x509.ParseCertificate(certBytes)
+	if exists(base + "-chain.crt") {
+		chain, err := loadCertificates(base + "-chain.crt")
+		if err != nil {
+			return nil, nil, err
+		}
+		certs = append(certs, chain...)
+	}
+	key, err := loadPrivateKey(base + ".key")
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return key, cert, nil
+	cert := certs[0]
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, nil, fmt.Errorf("%s.crt is not a CA certificate allowed to sign certificates", base)
+	}
+	if !pki.KeyMatches(key, cert) {
+		return nil, nil, fmt.Errorf("%s.key does not match %s.crt", base, base)
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, nil, fmt.Errorf("CA %s.crt is not valid now (valid %s to %s)", base,
+			cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly))
+	}
+	if len(certs) > 1 {
+		roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+		for _, c := range certs[1:] {
+			if bytes.Equal(c.RawIssuer, c.RawSubject) {
+				roots.AddCert(c)
+			} else {
+				intermediates.AddCert(c)
+			}
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return nil, nil, fmt.Errorf("CA %s.crt does not chain to the certificates that follow it: %w", base, err)
+		}
+	}
+	return key, certs, nil
 }
 
-func (i *Installer) generateClientCert(caKey *rsa.PrivateKey, caCert *x509.Certificate, cn, org string) (*rsa.PrivateKey, *x509.Certificate, error) {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+// splitCAChain leaves only the CA in <base>.crt and writes the chain
+// following it to <base>-chain.crt. Nothing changes when <base>.crt holds
+// the CA alone.
+func (i *Installer) splitCAChain(base string, certs []*x509.Certificate) error {
+	inline, err := loadCertificates(base + ".crt")
+	if err != nil || len(inline) == 1 {
+		return err
+	}
+	var chain []byte
+	for _, c := range certs[1:] {
+		chain = append(chain, pki.EncodeCertificate(c)...)
+	}
+	// The chain is written first, so a failure never loses it.
+	if err := os.WriteFile(base+"-chain.crt", chain, 0644); err != nil {
+		return err
+	}
+	log.Printf("  Moved the chain of %s.crt to %s-chain.crt", base, base)
+	return i.saveCertificate(base+".crt", certs[0])
+}
+
+// writeServingChain appends the Kubernetes CA and its chain to the
+// certificate in <base>.crt when the CA has a chain, so that clients which
+// only trust the corporate root can verify the server. ca-chain.crt is not
+// used for anything else.
+func (i *Installer) writeServingChain(base string) error {
+	pkiDir := i.cluster.Paths.PKIDir
+	chainPath := filepath.Join(pkiDir, "ca-chain.crt")
+	if !exists(chainPath) {
+		return nil
+	}
+	cert, err := loadCertificate(base + ".crt")
+	if err != nil {
+		return err
+	}
+	data := pki.EncodeCertificate(cert)
+	for _, path := range []string{filepath.Join(pkiDir, "ca.crt"), chainPath} {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		data = append(data, pem...)
+	}
+	return os.WriteFile(base+".crt", data, 0644)
+}
+
+// ensureCertificate returns the <base>.crt/<base>.key pair when this CA
+// issued it for the subject, usage and SANs of template, with a key of the
+// configured algorithm, and it stays valid for at least renewBefore.
+// Otherwise the pair is reissued for the validity in pki.certificates.
+func (i *Installer) ensureCertificate(base string, caKey crypto.Signer, caCert *x509.Certificate, template *x509.Certificate) (crypto.Signer, *x509.Certificate, error) {
+	name := i.certificateName(base)
+	spec := i.cluster.CertificateSpec(name, false)
+	if key, cert, err := loadKeyPair(base); err == nil && reusable(cert, key, caCert, template, spec.KeyAlgorithm) {
+		log.Printf("  ✓ %s certificate is up to date", name)
+		return key, cert, nil
+	}
+
+	log.Printf("  Generating %s certificate (%s)...", name, spec.KeyAlgorithm)
+	template.NotAfter = notAfter(spec.Validity, caCert)
+	key, cert, err := signCertificate(caKey, caCert, template, spec.KeyAlgorithm)
 	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate %s cert: %w", name, err)
+	}
+	if err := i.saveKeyPair(base, key, cert); err != nil {
 		return nil, nil, err
 	}
+	return key, cert, nil
+}
+
+// certificateName is the name of <base> in pki.certificates and the
+// "certs" commands: its path relative to pkiDir, e.g. "etcd/server".
+func (i *Installer) certificateName(base string) string {
+	rel, err := filepath.Rel(i.cluster.Paths.PKIDir, base)
+	if err != nil {
+		return filepath.Base(base)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// notAfter is the end of a new certificate's validity: validity from now,
+// but not after the CA expires.
+func notAfter(validity time.Duration, caCert *x509.Certificate) time.Time {
+	t := time.Now().Add(validity)
+	if t.After(caCert.NotAfter) {
+		return caCert.NotAfter
+	}
+	return t
+}
+
+// reusable reports whether cert and key can be kept instead of what
+// template would issue with a key of alg.
+func reusable(cert *x509.Certificate, key crypto.Signer, caCert *x509.Certificate, template *x509.Certificate, alg pki.KeyAlgorithm) bool {
+	if cert.CheckSignatureFrom(caCert) != nil || !pki.KeyMatches(key, cert) || pki.AlgorithmOf(cert.PublicKey) != alg {
+		return false
+	}
+	if time.Until(cert.NotAfter) < renewBefore {
+		return false
+	}
+	if cert.Subject.CommonName != template.Subject.CommonName ||
+		!slices.Equal(cert.Subject.Organization, template.Subject.Organization) ||
+		!slices.Equal(cert.ExtKeyUsage, template.ExtKeyUsage) {
+		return false
+	}
+	return sameStrings(cert.DNSNames, template.DNSNames) && sameStrings(ipStrings(cert.IPAddresses), ipStrings(template.IPAddresses))
+}
+
+// sameStrings compares two lists ignoring order.
+func sameStrings(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func ipStrings(ips []net.IP) []string {
+	s := make([]string, len(ips))
+	for n, ip := range ips {
+		s[n] = ip.String()
+	}
+	return s
+}
 
+// ensureServiceAccountKey creates the service account token signing key
+// unless it exists: a new key would invalidate every issued token.
+func (i *Installer) ensureServiceAccountKey() error {
+	pkiDir := i.cluster.Paths.PKIDir
+	key, err := loadPrivateKey(filepath.Join(pkiDir, "sa.key"))
+	if err != nil {
+		if exists(filepath.Join(pkiDir, "sa.key")) {
+			return err
+		}
+		alg := i.cluster.CertificateSpec("sa", false).KeyAlgorithm
+		log.Printf("  Generating service account keys (%s)...", alg)
+		if key, err = pki.GenerateKey(alg); err != nil {
+			return fmt.Errorf("failed to generate service account key: %w", err)
+		}
+		if err := i.savePrivateKey(filepath.Join(pkiDir, "sa.key"), key); err != nil {
+			return err
+		}
+	}
+	return i.savePublicKey(filepath.Join(pkiDir, "sa.pub"), key.Public())
+}
+
+func clientCertTemplate(cn, org string) *x509.Certificate {
 	template := &x509.Certificate{
-		SerialNumber: big.NewInt(time.Now().Unix()),
 		Subject: pkix.Name{
-			CommonName:   cn,
-			Organization: []string{org},
+			CommonName: cn,
 		},
-		NotBefore:   time.Now(),
-		NotAfter:    time.Now().AddDate(1, 0, 0),
 		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 	}
-
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
-	if err != nil {
-		return nil, nil, err
+	if org != "" {
+		template.Subject.Organization = []string{org}
 	}
+	return template
+}
 
-	cert, err := x509.ParseCertificate(certBytes)
+// ensureAPIServerCertificate issues the API server certificate. The SANs
+// of the current certificate, added by "certs add-san" or an earlier
+// configuration, are kept so that clients using them keep working.
+func (i *Installer) ensureAPIServerCertificate(caKey crypto.Signer, caCert *x509.Certificate) error {
+	template, err := i.apiServerCertTemplate()
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-
-	return key, cert, nil
+	base := filepath.Join(i.cluster.Paths.PKIDir, "apiserver")
+	if cert, err := loadCertificate(base + ".crt"); err == nil && cert.CheckSignatureFrom(caCert) == nil {
+		template.DNSNames, template.IPAddresses = mergeSANs(template.DNSNames, template.IPAddresses, cert.DNSNames, cert.IPAddresses)
+	}
+	if _, _, err := i.ensureCertificate(base, caKey, caCert, template); err != nil {
+		return err
+	}
+	return i.writeServingChain(base)
 }
 
-func (i *Installer) generateAPIServerCert(caKey *rsa.PrivateKey, caCert *x509.Certificate) (*rsa.PrivateKey, *x509.Certificate, error) {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+// apiServerCertTemplate is the API server certificate with every name it
+// is reached by: loopback, the first service CIDR address (the kubernetes
+// Service), hostIP, the hostname, the interface addresses
+// (apiServer.interfaceSANs) and apiServer.certSANs.
+func (i *Installer) apiServerCertTemplate() (*x509.Certificate, error) {
+	serviceIP, err := i.cluster.ServiceIP()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-
-	hostIP := os.Getenv("K8S_HOST_IP")
-	if hostIP == "" {
-		hostIP = "127.0.0.1"
+	ipAddresses := []net.IP{net.ParseIP("127.0.0.1"), serviceIP}
+	if parsedIP := net.ParseIP(i.cluster.Network.HostIP); parsedIP != nil && !parsedIP.IsLoopback() {
+		ipAddresses = append(ipAddresses, parsedIP)
+	}
+	if i.cluster.APIServer.InterfaceSANs {
+		ips, err := utils.InterfaceIPs()
+		if err != nil {
+			return nil, err
+		}
+		ipAddresses = append(ipAddresses, ips...)
 	}
 
-	ipAddresses := []net.IP{
-		net.ParseIP("127.0.0.1"),
-		net.ParseIP("10.0.0.1"),
+	domain := i.cluster.Network.ClusterDomain
+	dnsNames := []string{
+		"kubernetes",
+		"kubernetes.default",
+		"kubernetes.default.svc",
+		"kubernetes.default.svc." + domain,
+		"localhost",
 	}
-	
-	if parsedIP := net.ParseIP(hostIP); parsedIP != nil && !parsedIP.IsLoopback() {
-		ipAddresses = append(ipAddresses, parsedIP)
+	if hostname, err := os.Hostname(); err == nil {
+		dnsNames = append(dnsNames, strings.ToLower(hostname))
 	}
 
-	template := &x509.Certificate{
-		SerialNumber: big.NewInt(time.Now().Unix()),
+	extraDNS, extraIPs, err := config.SplitSANs(i.cluster.APIServer.CertSANs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiServer.certSANs: %w", err)
+	}
+	dnsNames, ipAddresses = mergeSANs(dnsNames, ipAddresses, extraDNS, extraIPs)
+
+	return &x509.Certificate{
 		Subject: pkix.Name{
 			CommonName: "kube-apiserver",
 		},
-		NotBefore: time.Now(),
-		NotAfter:  time.Now().AddDate(1, 0, 0),
-		KeyUsage:  x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		KeyUsage: x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageServerAuth,
 			x509.ExtKeyUsageClientAuth,
 		},
 		IPAddresses: ipAddresses,
-		DNSNames: []string{
-			"kubernetes",
-			"kubernetes.default",
-			"kubernetes.default.svc",
-			"kubernetes.default.svc.cluster.local",
-			"localhost",
-		},
-	}
+		DNSNames:    dnsNames,
+	}, nil
+}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
-	if err != nil {
-		return nil, nil, err
+// mergeSANs appends the names and addresses missing from dnsNames and ips,
+// keeping their order.
+func mergeSANs(dnsNames []string, ips []net.IP, moreDNS []string, moreIPs []net.IP) ([]string, []net.IP) {
+	for _, name := range moreDNS {
+		if !slices.Contains(dnsNames, name) {
+			dnsNames = append(dnsNames, name)
+		}
 	}
-
-	cert, err := x509.ParseCertificate(certBytes)
-	if err != nil {
-		return nil, nil, err
+	for _, ip := range moreIPs {
+		if !slices.ContainsFunc(ips, ip.Equal) {
+			ips = append(ips, ip)
+		}
 	}
-
-	return key, cert, nil
+	return dnsNames, ips
 }
 
 func (i *Installer) saveCertificate(path string, cert *x509.Certificate) error {
-	certPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: cert.Raw,
-	})
-	return os.WriteFile(path, certPEM, 0644)
+	return os.WriteFile(path, pki.EncodeCertificate(cert), 0644)
 }
 
-func (i *Installer) savePrivateKey(path string, key *rsa.PrivateKey) error {
-	keyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(key),
-	})
-	return os.WriteFile(path, keyPEM, 0600)
+func (i *Installer) savePrivateKey(path string, key crypto.Signer) error {
+	data, err := pki.EncodePrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return os.WriteFile(path, data, 0600)
 }
 
-func (i *Installer) savePublicKey(path string, key *rsa.PublicKey) error {
-	pubBytes, err := x509.MarshalPKIXPublicKey(key)
+func (i *Installer) savePublicKey(path string, key crypto.PublicKey) error {
+	pubPEM, err := pki.EncodePublicKey(key)
 	if err != nil {
 		return err
 	}
-	pubPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: pubBytes,
-	})
 	return os.WriteFile(path, pubPEM, 0644)
 }
 
@@ -245,4 +444,52 @@ func (i *Installer) copyCertificate(src, dst string) error {
 		return err
 	}
 	return os.WriteFile(dst, data, 0644)
-}
\ No newline at end of file
+}
+
+// loadKeyPair reads <base>.crt and <base>.key.
+func loadKeyPair(base string) (crypto.Signer, *x509.Certificate, error) {
+	cert, err := loadCertificate(base + ".crt")
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := loadPrivateKey(base + ".key")
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// loadCertificate reads the first certificate of a PEM file.
+func loadCertificate(path string) (*x509.Certificate, error) {
+	certs, err := loadCertificates(path)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// loadCertificates reads every certificate of a PEM file.
+func loadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := pki.ParseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return certs, nil
+}
+
+// loadPrivateKey reads a PKCS#8, PKCS#1 or SEC 1 key.
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := pki.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,230 @@
+package installer
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/pki"
+)
+
+func TestGenerateCertificatesIsIdempotent(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityHardened)
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+	pkiDir := inst.cluster.Paths.PKIDir
+	names := []string{"ca", "admin", "apiserver", "scheduler", "etcd/ca", "etcd/server"}
+	before := map[string]*x509.Certificate{}
+	for _, name := range names {
+		before[name] = readCertificate(t, filepath.Join(pkiDir, name+".crt"))
+	}
+	saKey, _ := os.ReadFile(filepath.Join(pkiDir, "sa.key"))
+
+	inst.cluster.Network.HostIP = "192.0.2.10"
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("second GenerateCertificates failed: %v", err)
+	}
+	for _, name := range names {
+		after := readCertificate(t, filepath.Join(pkiDir, name+".crt"))
+		// The host IP is a SAN of the apiserver and etcd server certs only.
+		reissued := name == "apiserver" || name == "etcd/server"
+		if kept := after.SerialNumber.Cmp(before[name].SerialNumber) == 0; kept == reissued {
+			t.Errorf("%s: expected reissued=%v", name, reissued)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(pkiDir, "sa.key")); string(data) != string(saKey) {
+		t.Error("Expected the service account key to be kept")
+	}
+}
+
+func TestGenerateCertificatesKeyAlgorithm(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityHardened)
+	inst.cluster.PKI.KeyAlgorithm = pki.ECDSAP256
+	inst.cluster.PKI.Certificates = map[string]config.CertificateSpec{
+		"apiserver": {KeyAlgorithm: pki.ECDSAP384, Validity: 90 * 24 * time.Hour},
+	}
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+
+	pkiDir := inst.cluster.Paths.PKIDir
+	for name, want := range map[string]pki.KeyAlgorithm{"ca": pki.ECDSAP256, "scheduler": pki.ECDSAP256, "etcd/peer": pki.ECDSAP256, "apiserver": pki.ECDSAP384} {
+		if got := pki.AlgorithmOf(readCertificate(t, filepath.Join(pkiDir, name+".crt")).PublicKey); got != want {
+			t.Errorf("%s: expected a %s key, got %s", name, want, got)
+		}
+	}
+	apiserver := readCertificate(t, filepath.Join(pkiDir, "apiserver.crt"))
+	if left := time.Until(apiserver.NotAfter); left > 90*24*time.Hour || left < 89*24*time.Hour {
+		t.Errorf("Expected the apiserver cert to be valid for 90 days, got %s", left)
+	}
+	if _, err := loadPrivateKey(filepath.Join(pkiDir, "sa.key")); err != nil {
+		t.Errorf("Expected a readable service account key: %v", err)
+	}
+
+	// Changing the algorithm reissues the leaves but keeps the CA.
+	inst.cluster.PKI.KeyAlgorithm = pki.RSA2048
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("second GenerateCertificates failed: %v", err)
+	}
+	if got := pki.AlgorithmOf(readCertificate(t, filepath.Join(pkiDir, "scheduler.crt")).PublicKey); got != pki.RSA2048 {
+		t.Errorf("Expected the scheduler cert to be reissued with RSA, got %s", got)
+	}
+	if got := pki.AlgorithmOf(readCertificate(t, filepath.Join(pkiDir, "ca.crt")).PublicKey); got != pki.ECDSAP256 {
+		t.Errorf("Expected the CA to be kept, got a %s key", got)
+	}
+}
+
+func TestAPIServerCertificateSANs(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	inst.cluster.Network.ServiceCIDR = "10.96.0.0/12"
+	inst.cluster.APIServer.CertSANs = []string{"k8s.example.com", "203.0.113.5"}
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+	path := filepath.Join(inst.cluster.Paths.PKIDir, "apiserver.crt")
+	hostname, _ := os.Hostname()
+	cert := readCertificate(t, path)
+	if err := cert.VerifyHostname("10.96.0.1"); err != nil {
+		t.Errorf("Expected the first service IP: %v", err)
+	}
+	for _, name := range []string{"k8s.example.com", "203.0.113.5", "kubernetes.default.svc.cluster.local", strings.ToLower(hostname)} {
+		if err := cert.VerifyHostname(name); err != nil {
+			t.Errorf("Expected %s: %v", name, err)
+		}
+	}
+	if cert.VerifyHostname("10.0.0.1") == nil {
+		t.Error("Expected no hard-coded service IP")
+	}
+
+	if _, err := inst.AddAPIServerSANs([]string{"bad_name"}); err == nil {
+		t.Error("Expected an invalid SAN to be rejected")
+	}
+	report, err := inst.AddAPIServerSANs([]string{"laptop.example", "198.51.100.7"})
+	if err != nil {
+		t.Fatalf("AddAPIServerSANs failed: %v", err)
+	}
+	if strings.Join(report.Restart, ",") != "apiserver" {
+		t.Errorf("Expected the apiserver to be restarted, got %v", report.Restart)
+	}
+	added := readCertificate(t, path)
+	for _, name := range []string{"laptop.example", "198.51.100.7", "k8s.example.com"} {
+		if err := added.VerifyHostname(name); err != nil {
+			t.Errorf("Expected %s after add-san: %v", name, err)
+		}
+	}
+	if key, err := loadPrivateKey(filepath.Join(inst.cluster.Paths.PKIDir, "apiserver.key")); err != nil || !pki.KeyMatches(key, added) {
+		t.Errorf("Expected add-san to keep the key (%v)", err)
+	}
+
+	// A rerun keeps the added names.
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("second GenerateCertificates failed: %v", err)
+	}
+	if readCertificate(t, path).SerialNumber.Cmp(added.SerialNumber) != 0 {
+		t.Error("Expected the certificate with added SANs to be kept")
+	}
+}
+
+func TestGenerateCertificatesWithOwnCA(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	pkiDir := inst.cluster.Paths.PKIDir
+
+	rootKey, root := testCA(t, nil, nil, "corp-root")
+	key, intermediate := testCA(t, rootKey, root, "corp-kubernetes")
+	chain := append(pki.EncodeCertificate(intermediate), pki.EncodeCertificate(root)...)
+	if err := os.WriteFile(filepath.Join(pkiDir, "ca.crt"), chain, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.savePrivateKey(filepath.Join(pkiDir, "ca.key"), key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+	// Only the issuing CA may be trusted for client certificates.
+	caCerts, err := loadCertificates(filepath.Join(pkiDir, "ca.crt"))
+	if err != nil || len(caCerts) != 1 || !caCerts[0].Equal(intermediate) {
+		t.Errorf("Expected ca.crt to hold only the provided CA, got %d certificates (%v)", len(caCerts), err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(pkiDir, "ca-chain.crt")); string(data) != string(pki.EncodeCertificate(root)) {
+		t.Errorf("Expected the chain in ca-chain.crt, got %q", data)
+	}
+	served, err := loadCertificates(filepath.Join(pkiDir, "apiserver.crt"))
+	if err != nil || len(served) != 3 {
+		t.Fatalf("Expected the apiserver to present its chain, got %d certificates (%v)", len(served), err)
+	}
+	if err := served[0].CheckSignatureFrom(intermediate); err != nil {
+		t.Errorf("Expected the apiserver cert to be signed by the provided CA: %v", err)
+	}
+
+	// A rerun validates the CA against the moved chain and keeps the certs.
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("second GenerateCertificates failed: %v", err)
+	}
+	if again := readCertificate(t, filepath.Join(pkiDir, "apiserver.crt")); !again.Equal(served[0]) {
+		t.Error("Expected the apiserver cert to be kept")
+	}
+	if certs, _ := loadCertificates(filepath.Join(pkiDir, "ca.crt")); len(certs) != 1 {
+		t.Errorf("Expected ca.crt to still hold one certificate, got %d", len(certs))
+	}
+}
+
+func TestGenerateCertificatesRejectsBadCA(t *testing.T) {
+	caKey, ca := testCA(t, nil, nil, "kubernetes-ca")
+	otherKey, _ := testCA(t, nil, nil, "other")
+	_, unrelated := testCA(t, nil, nil, "unrelated-root")
+
+	tests := []struct {
+		name string
+		crt  []byte
+		key  crypto.Signer
+		want string
+	}{
+		{"key only", nil, caKey, "provide both"},
+		{"mismatched key", pki.EncodeCertificate(ca), otherKey, "does not match"},
+		{"broken chain", append(pki.EncodeCertificate(ca), pki.EncodeCertificate(unrelated)...), caKey, "does not chain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newTestInstaller(t, config.SecurityDefault)
+			base := filepath.Join(inst.cluster.Paths.PKIDir, "ca")
+			if tt.crt != nil {
+				if err := os.WriteFile(base+".crt", tt.crt, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := inst.savePrivateKey(base+".key", tt.key); err != nil {
+				t.Fatal(err)
+			}
+
+			err := inst.GenerateCertificates()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// testCA creates a CA certificate, self-signed when parent is nil.
+func testCA(t *testing.T, parentKey crypto.Signer, parent *x509.Certificate, cn string) (crypto.Signer, *x509.Certificate) {
+	t.Helper()
+	key, cert, err := signCertificate(parentKey, parent, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: cn},
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, pki.ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
@@ -0,0 +1,311 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/pki"
+)
+
+// CertificateInfo describes a certificate in the PKI dir.
+type CertificateInfo struct {
+	// Name is the path relative to the PKI dir without ".crt", e.g.
+	// "apiserver" or "etcd/server". It is what "certs renew" accepts.
+	Name     string
+	Subject  string
+	NotAfter time.Time
+	CA       bool
+
+	cert *x509.Certificate
+}
+
+// Certificates lists every certificate in the PKI dir, sorted by name.
+func (i *Installer) Certificates() ([]CertificateInfo, error) {
+	pkiDir := i.cluster.Paths.PKIDir
+	var infos []CertificateInfo
+	err := filepath.WalkDir(pkiDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".crt" {
+			return err
+		}
+		cert, err := loadCertificate(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(pkiDir, path)
+		infos = append(infos, CertificateInfo{
+			Name:     filepath.ToSlash(strings.TrimSuffix(rel, ".crt")),
+			Subject:  cert.Subject.String(),
+			NotAfter: cert.NotAfter,
+			CA:       cert.IsCA,
+			cert:     cert,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates in %s: %w", pkiDir, err)
+	}
+	sort.Slice(infos, func(a, b int) bool { return infos[a].Name < infos[b].Name })
+	return infos, nil
+}
+
+// RenewReport describes what RenewCertificates changed.
+type RenewReport struct {
+	Renewed     []string
+	Kubeconfigs []string
+	// Restart lists the components that have to be restarted to pick up
+	// the renewed certificates, in start order.
+	Restart []string
+}
+
+// RenewCertificates re-signs the named leaf certificates, or all of them
+// when names is empty or "all", with the CA that issued them. Keys, subjects
+// and SANs are kept, so only the validity changes; kubeconfigs embedding a
+// renewed certificate are updated. Components are not restarted.
+func (i *Installer) RenewCertificates(names []string) (*RenewReport, error) {
+	infos, err := i.Certificates()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectLeafCertificates(infos, names)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RenewReport{}
+	restart := map[string]bool{}
+	for _, info := range selected {
+		base := filepath.Join(i.cluster.Paths.PKIDir, filepath.FromSlash(info.Name))
+		oldPEM, err := os.ReadFile(base + ".crt")
+		if err != nil {
+			return report, err
+		}
+		cert, err := i.renewCertificate(infos, info, base+".key")
+		if err != nil {
+			return report, fmt.Errorf("failed to renew %s: %w", info.Name, err)
+		}
+		if err := i.saveCertificate(base+".crt", cert); err != nil {
+			return report, err
+		}
+		if info.Name == "apiserver" {
+			if err := i.writeServingChain(base); err != nil {
+				return report, err
+			}
+		}
+		log.Printf("  Renewed %s (valid until %s)", info.Name, cert.NotAfter.Format(time.DateOnly))
+		report.Renewed = append(report.Renewed, info.Name)
+		for _, c := range i.certificateUsers(info.Name) {
+			restart[c] = true
+		}
+
+		for path, users := range i.kubeconfigUsers() {
+			updated, err := replaceEmbeddedCert(path, oldPEM, pki.EncodeCertificate(cert))
+			if err != nil {
+				return report, err
+			}
+			if !updated {
+				continue
+			}
+			report.Kubeconfigs = append(report.Kubeconfigs, path)
+			for _, c := range users {
+				restart[c] = true
+			}
+		}
+	}
+
+	sort.Strings(report.Kubeconfigs)
+	for _, c := range i.startOrder() {
+		if restart[c] {
+			report.Restart = append(report.Restart, c)
+		}
+	}
+	return report, nil
+}
+
+// AddAPIServerSANs reissues the API server certificate with the given IP
+// addresses and DNS names added to its SANs, keeping its key. The names
+// stay in the certificate when a later install reissues it.
+func (i *Installer) AddAPIServerSANs(sans []string) (*RenewReport, error) {
+	dnsNames, ips, err := config.SplitSANs(sans)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := i.Certificates()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectLeafCertificates(infos, []string{"apiserver"})
+	if err != nil {
+		return nil, err
+	}
+
+	info := selected[0]
+	cert := *info.cert
+	cert.DNSNames, cert.IPAddresses = mergeSANs(slices.Clone(cert.DNSNames), slices.Clone(cert.IPAddresses), dnsNames, ips)
+	if len(cert.DNSNames) == len(info.cert.DNSNames) && len(cert.IPAddresses) == len(info.cert.IPAddresses) {
+		log.Println("  The apiserver certificate already has all of these SANs")
+		return &RenewReport{}, nil
+	}
+	info.cert = &cert
+
+	base := filepath.Join(i.cluster.Paths.PKIDir, "apiserver")
+	renewed, err := i.renewCertificate(infos, info, base+".key")
+	if err != nil {
+		return nil, fmt.Errorf("failed to reissue apiserver: %w", err)
+	}
+	if err := i.saveCertificate(base+".crt", renewed); err != nil {
+		return nil, err
+	}
+	if err := i.writeServingChain(base); err != nil {
+		return nil, err
+	}
+	log.Printf("  Reissued apiserver for %s", strings.Join(append(slices.Clone(renewed.DNSNames), ipStrings(renewed.IPAddresses)...), ", "))
+	return &RenewReport{Renewed: []string{"apiserver"}, Restart: i.certificateUsers("apiserver")}, nil
+}
+
+// selectLeafCertificates picks the certificates named, rejecting CAs and
+// unknown names; no names or "all" selects every leaf certificate.
+func selectLeafCertificates(infos []CertificateInfo, names []string) ([]CertificateInfo, error) {
+	all := len(names) == 0 || (len(names) == 1 && names[0] == "all")
+	var leaves []string
+	byName := map[string]CertificateInfo{}
+	for _, info := range infos {
+		byName[info.Name] = info
+		if !info.CA {
+			leaves = append(leaves, info.Name)
+		}
+	}
+	if all {
+		names = leaves
+	}
+
+	var selected []CertificateInfo
+	for _, name := range names {
+		info, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown certificate %q (available: %s)", name, strings.Join(leaves, ", "))
+		}
+		if info.CA {
+			return nil, fmt.Errorf("%s is a CA certificate; only leaf certificates are renewed", name)
+		}
+		selected = append(selected, info)
+	}
+	return selected, nil
+}
+
+// renewCertificate signs a copy of the certificate, valid for its
+// configured validity, with the CA among infos that issued it and for the
+// key in keyPath.
+func (i *Installer) renewCertificate(infos []CertificateInfo, info CertificateInfo, keyPath string) (*x509.Certificate, error) {
+	cert := info.cert
+	key, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	var ca *CertificateInfo
+	for n := range infos {
+		if infos[n].CA && cert.CheckSignatureFrom(infos[n].cert) == nil {
+			ca = &infos[n]
+			break
+		}
+	}
+	if ca == nil {
+		return nil, fmt.Errorf("the CA that issued it (%s) is not in %s", cert.Issuer, i.cluster.Paths.PKIDir)
+	}
+	caKey, err := loadPrivateKey(filepath.Join(i.cluster.Paths.PKIDir, filepath.FromSlash(ca.Name)+".key"))
+	if err != nil {
+		return nil, err
+	}
+
+	return pki.Sign(&x509.Certificate{
+		Subject:     cert.Subject,
+		NotAfter:    notAfter(i.cluster.CertificateSpec(info.Name, false).Validity, ca.cert),
+		KeyUsage:    cert.KeyUsage,
+		ExtKeyUsage: cert.ExtKeyUsage,
+		DNSNames:    cert.DNSNames,
+		IPAddresses: cert.IPAddresses,
+	}, key, ca.cert, caKey)
+}
+
+// certificateUsers lists the components that read the certificate from the
+// PKI dir; kubeconfig users are found through kubeconfigUsers.
+func (i *Installer) certificateUsers(name string) []string {
+	switch name {
+	case "apiserver", "apiserver-etcd-client", "apiserver-kubelet-client", "front-proxy-client":
+		return []string{"apiserver"}
+	case "etcd/server", "etcd/peer":
+		return i.services.LocalEtcdNames()
+	}
+	return nil
+}
+
+// kubeconfigUsers maps every kubeconfig the installer writes to the
+// components that run with it.
+func (i *Installer) kubeconfigUsers() map[string][]string {
+	users := map[string][]string{
+		filepath.Join(i.kubeletDir, "kubeconfig"):           {"kubelet"},
+		i.cluster.ComponentKubeconfig("controller-manager"): {"controller-manager"},
+		i.cluster.ComponentKubeconfig("scheduler"):          {"scheduler"},
+		i.cluster.ComponentKubeconfig("kube-proxy"):         nil,
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		users[filepath.Join(home, ".kube", "config")] = nil
+	}
+	return users
+}
+
+// startOrder lists the components in the order they are started.
+func (i *Installer) startOrder() []string {
+	return append(i.services.LocalEtcdNames(), "apiserver", "controller-manager", "scheduler", "kubelet")
+}
+
+// RestartComponents restarts the named components one after another.
+func (i *Installer) RestartComponents(names []string) error {
+	var errs []error
+	for _, name := range names {
+		log.Printf("  Restarting %s...", name)
+		if err := i.services.Restart(name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restart %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replaceEmbeddedCert swaps the base64 encoded oldPEM in the kubeconfig at
+// path for newPEM. It reports whether the kubeconfig embedded oldPEM; a
+// missing kubeconfig embeds nothing.
+func replaceEmbeddedCert(path string, oldPEM, newPEM []byte) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	old := []byte(base64.StdEncoding.EncodeToString(oldPEM))
+	if !bytes.Contains(data, old) {
+		return false, nil
+	}
+	data = bytes.ReplaceAll(data, old, []byte(base64.StdEncoding.EncodeToString(newPEM)))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, info.Mode().Perm()); err != nil {
+		return false, fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	return true, os.Rename(tmp, path)
+}
@@ -0,0 +1,128 @@
+package installer
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func TestCertificates(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+
+	certs, err := inst.Certificates()
+	if err != nil {
+		t.Fatalf("Certificates failed: %v", err)
+	}
+	found := map[string]bool{}
+	for _, c := range certs {
+		found[c.Name] = c.CA
+	}
+	for name, ca := range map[string]bool{"ca": true, "etcd/ca": true, "apiserver": false, "etcd/server": false} {
+		if isCA, ok := found[name]; !ok || isCA != ca {
+			t.Errorf("Expected %s (CA %v) in %v", name, ca, found)
+		}
+	}
+}
+
+func TestRenewCertificates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	inst := newTestInstaller(t, config.SecurityHardened)
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+	pkiDir := inst.cluster.Paths.PKIDir
+	before := readCertificate(t, filepath.Join(pkiDir, "scheduler.crt"))
+	key, _ := os.ReadFile(filepath.Join(pkiDir, "scheduler.key"))
+
+	report, err := inst.RenewCertificates([]string{"scheduler"})
+	if err != nil {
+		t.Fatalf("RenewCertificates failed: %v", err)
+	}
+	after := readCertificate(t, filepath.Join(pkiDir, "scheduler.crt"))
+	if after.SerialNumber.Cmp(before.SerialNumber) == 0 || after.Subject.String() != before.Subject.String() {
+		t.Errorf("Expected a new certificate for %s, got %s", before.Subject, after.Subject)
+	}
+	ca := readCertificate(t, filepath.Join(pkiDir, "ca.crt"))
+	if err := after.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("Expected the renewed certificate to be signed by the CA: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(pkiDir, "scheduler.key")); string(data) != string(key) {
+		t.Error("Expected the key to be kept")
+	}
+
+	kubeconfig := inst.cluster.ComponentKubeconfig("scheduler")
+	if !reflect.DeepEqual(report.Kubeconfigs, []string{kubeconfig}) {
+		t.Errorf("Expected %s to be updated, got %v", kubeconfig, report.Kubeconfigs)
+	}
+	data, _ := os.ReadFile(kubeconfig)
+	if embedded := kubeconfigClientCert(t, data); embedded.SerialNumber.Cmp(after.SerialNumber) != 0 {
+		t.Error("Expected the kubeconfig to embed the renewed certificate")
+	}
+	if !reflect.DeepEqual(report.Restart, []string{"scheduler"}) {
+		t.Errorf("Expected the scheduler to be restarted, got %v", report.Restart)
+	}
+}
+
+func TestRenewAllCertificates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	inst := newTestInstaller(t, config.SecurityDefault)
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+
+	if _, err := inst.RenewCertificates([]string{"ca"}); err == nil || !strings.Contains(err.Error(), "CA certificate") {
+		t.Errorf("Expected the CA to be refused, got %v", err)
+	}
+	if _, err := inst.RenewCertificates([]string{"nope"}); err == nil || !strings.Contains(err.Error(), "unknown certificate") {
+		t.Errorf("Expected an unknown name to be refused, got %v", err)
+	}
+
+	report, err := inst.RenewCertificates([]string{"all"})
+	if err != nil {
+		t.Fatalf("RenewCertificates failed: %v", err)
+	}
+	for _, name := range []string{"admin", "apiserver", "etcd/server", "etcd/peer"} {
+		if !slices.Contains(report.Renewed, name) {
+			t.Errorf("Expected %s to be renewed, got %v", name, report.Renewed)
+		}
+	}
+	etcdServer := readCertificate(t, filepath.Join(inst.cluster.EtcdPKIDir(), "server.crt"))
+	if err := etcdServer.CheckSignatureFrom(readCertificate(t, filepath.Join(inst.cluster.EtcdPKIDir(), "ca.crt"))); err != nil {
+		t.Errorf("Expected etcd certificates to be signed by the etcd CA: %v", err)
+	}
+	if want := []string{"etcd", "apiserver", "controller-manager", "scheduler", "kubelet"}; !reflect.DeepEqual(report.Restart, want) {
+		t.Errorf("Expected %v to be restarted, got %v", want, report.Restart)
+	}
+}
+
+// kubeconfigClientCert returns the client certificate a kubeconfig embeds.
+func kubeconfigClientCert(t *testing.T, data []byte) *x509.Certificate {
+	t.Helper()
+	var kc struct {
+		Users []struct {
+			User struct {
+				ClientCertificateData string `yaml:"client-certificate-data"`
+			} `yaml:"user"`
+		} `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(data, &kc); err != nil || len(kc.Users) != 1 {
+		t.Fatalf("Invalid kubeconfig (%v):\n%s", err, data)
+	}
+	pemData, err := base64.StdEncoding.DecodeString(kc.Users[0].User.ClientCertificateData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseCertificate(t, pemData)
+}
@@ -0,0 +1,80 @@
+package installer
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+//go:embed checksums.txt
+var checksumManifest string
+
+// pinnedChecksums returns the digests pinned in checksums.txt by URL.
+func pinnedChecksums() (map[string]string, error) {
+	return parseChecksumManifest(checksumManifest)
+}
+
+func parseChecksumManifest(data string) (map[string]string, error) {
+	sums := map[string]string{}
+	for n, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !utils.IsSHA256(fields[0]) {
+			return nil, fmt.Errorf("checksums.txt:%d: expected \"<sha256>  <url>\", got %q", n+1, line)
+		}
+		if _, dup := sums[fields[1]]; dup {
+			return nil, fmt.Errorf("checksums.txt:%d: %s is listed twice", n+1, fields[1])
+		}
+		sums[fields[1]] = strings.ToLower(fields[0])
+	}
+	return sums, nil
+}
+
+// expectedChecksum returns the digest an artifact must have: the one pinned
+// in checksums.txt, or else the one upstream publishes next to it.
+func expectedChecksum(d *utils.Downloader, pinned map[string]string, dl download) (string, error) {
+	if sum, ok := pinned[dl.url]; ok {
+		return sum, nil
+	}
+	if dl.sumURL == "" {
+		return "", fmt.Errorf("no checksum pinned for %s and upstream publishes none; add it to checksums.txt (see k8s-installer checksums)", dl.url)
+	}
+	sum, err := d.FetchChecksum(dl.sumURL, path.Base(dl.url))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum for %s: %w", dl.url, err)
+	}
+	log.Printf("  ⚠ %s is not pinned in checksums.txt, verifying against the published sha256", path.Base(dl.url))
+	return sum, nil
+}
+
+// Checksums writes checksums.txt lines for the artifacts of the configured
+// versions. Every artifact is downloaded and hashed, and the digest is
+// cross-checked with the one upstream publishes where there is one.
+func (i *Installer) Checksums(w io.Writer) error {
+	for _, dl := range i.downloads() {
+		log.Printf("  Hashing %s...", path.Base(dl.url))
+		sum, err := i.downloader.SHA256URL(dl.url)
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", dl.url, err)
+		}
+		if dl.sumURL != "" {
+			published, err := i.downloader.FetchChecksum(dl.sumURL, path.Base(dl.url))
+			if err != nil {
+				return fmt.Errorf("failed to fetch checksum for %s: %w", dl.url, err)
+			}
+			if published != sum {
+				return fmt.Errorf("%s: downloaded sha256 %s does not match the published %s", dl.url, sum, published)
+			}
+		}
+		fmt.Fprintf(w, "%s  %s\n", sum, dl.url)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+func TestEmbeddedChecksumManifestParses(t *testing.T) {
+	if _, err := pinnedChecksums(); err != nil {
+		t.Fatalf("checksums.txt is invalid: %v", err)
+	}
+}
+
+func TestParseChecksumManifest(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+
+	sums, err := parseChecksumManifest("# comment\n\n" + digest + "  https://dl.k8s.io/v1.30.0/bin/linux/amd64/kubelet\n")
+	if err != nil {
+		t.Fatalf("parseChecksumManifest failed: %v", err)
+	}
+	if sums["https://dl.k8s.io/v1.30.0/bin/linux/amd64/kubelet"] != digest {
+		t.Errorf("Unexpected manifest %v", sums)
+	}
+
+	for name, data := range map[string]string{
+		"no url":     digest + "\n",
+		"bad digest": "abc  https://example.com/kubelet\n",
+		"duplicate":  digest + "  https://example.com/kubelet\n" + digest + "  https://example.com/kubelet\n",
+	} {
+		if _, err := parseChecksumManifest(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExpectedChecksum(t *testing.T) {
+	pinnedSum := strings.Repeat("ab", 32)
+	publishedSum := strings.Repeat("cd", 32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(publishedSum + "  crictl.tar.gz\n"))
+	}))
+	defer srv.Close()
+
+	dl := download{url: srv.URL + "/crictl.tar.gz", sumURL: srv.URL + "/crictl.tar.gz.sha256"}
+	d := utils.NewDownloader()
+
+	// The pinned digest wins over the published one.
+	if sum, err := expectedChecksum(d, map[string]string{dl.url: pinnedSum}, dl); err != nil || sum != pinnedSum {
+		t.Errorf("Expected pinned %s, got %s (%v)", pinnedSum, sum, err)
+	}
+	if sum, err := expectedChecksum(d, nil, dl); err != nil || sum != publishedSum {
+		t.Errorf("Expected published %s, got %s (%v)", publishedSum, sum, err)
+	}
+
+	dl.sumURL = ""
+	if _, err := expectedChecksum(d, nil, dl); err == nil {
+		t.Error("Expected an error for an artifact without any checksum")
+	}
+}
@@ -22,22 +22,23 @@ func (i *Installer) CreateConfigurations() error {
 }
 
 func (i *Installer) createCNIConfig() error {
-	cniConfig := `{
+	network := i.cluster.Network
+	cniConfig := fmt.Sprintf(`{
   "cniVersion": "0.3.1",
-  "name": "mynet",
+  "name": %q,
   "type": "bridge",
-  "bridge": "cni0",
+  "bridge": %q,
   "isGateway": true,
   "ipMasq": true,
   "ipam": {
     "type": "host-local",
-    "subnet": "10.22.0.0/16",
+    "subnet": %q,
     "routes": [
       { "dst": "0.0.0.0/0" }
     ]
   }
-}`
-	configPath := filepath.Join(i.cniConfDir, "10-mynet.conf")
+}`, network.CNINetworkName, network.CNIBridge, network.PodSubnet)
+	configPath := filepath.Join(i.cniConfDir, "10-"+network.CNINetworkName+".conf")
 	if err := os.WriteFile(configPath, []byte(cniConfig), 0644); err != nil {
 		return fmt.Errorf("failed to write CNI config: %w", err)
 	}
@@ -45,11 +46,12 @@ func (i *Installer) createCNIConfig() error {
 }
 
 func (i *Installer) createContainerdConfig() error {
+	paths := i.cluster.Paths
 	// ИСПРАВЛЕНО: version 2 с правильной структурой для CRI
-	containerdConfig := `version = 2
+	containerdConfig := fmt.Sprintf(`version = 2
 
 [grpc]
-address = "/run/containerd/containerd.sock"
+address = %q
 uid = 0
 gid = 0
 
@@ -57,7 +59,7 @@ gid = 0
 level = "info"
 
 [plugins."io.containerd.grpc.v1.cri"]
-sandbox_image = "registry.k8s.io/pause:3.10"
+sandbox_image = %q
 
 [plugins."io.containerd.grpc.v1.cri".containerd]
 snapshotter = "overlayfs"
@@ -67,41 +69,49 @@ default_runtime_name = "runc"
 runtime_type = "io.containerd.runc.v2"
 
 [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
-SystemdCgroup = false
+SystemdCgroup = %t
 
 [plugins."io.containerd.grpc.v1.cri".cni]
-bin_dir = "/opt/cni/bin"
-conf_dir = "/etc/cni/net.d"
-`
-	if err := os.WriteFile("/etc/containerd/config.toml", []byte(containerdConfig), 0644); err != nil {
+bin_dir = %q
+conf_dir = %q
+`, paths.ContainerdSocket, i.cluster.Versions.PauseImage,
+		i.cluster.Kubelet.CgroupDriver == "systemd", paths.CNIBinDir, paths.CNIConfDir)
+	if err := os.WriteFile(paths.ContainerdConfig, []byte(containerdConfig), 0644); err != nil {
 		return fmt.Errorf("failed to write containerd config: %w", err)
 	}
 	return nil
 }
 
 func (i *Installer) createKubeletConfig() error {
-	kubeletConfig := `apiVersion: kubelet.config.k8s.io/v1beta1
+	anonymous, authzMode := true, "AlwaysAllow"
+	if i.cluster.Hardened() {
+		// Запросы к kubelet авторизуются через API server (SubjectAccessReview).
+		anonymous, authzMode = false, "Webhook"
+	}
+
+	kubeletConfig := fmt.Sprintf(`apiVersion: %s
 kind: KubeletConfiguration
 authentication:
   anonymous:
-    enabled: true
+    enabled: %t
   webhook:
     enabled: true
   x509:
-    clientCAFile: "/var/lib/kubelet/ca.crt"
+    clientCAFile: %q
 authorization:
-  mode: AlwaysAllow
-clusterDomain: "cluster.local"
+  mode: %s
+clusterDomain: %q
 clusterDNS:
-  - "10.0.0.10"
+  - %q
 resolvConf: "/etc/resolv.conf"
 runtimeRequestTimeout: "15m"
 failSwapOn: false
 seccompDefault: true
 serverTLSBootstrap: false
-containerRuntimeEndpoint: "unix:///run/containerd/containerd.sock"
-staticPodPath: "/etc/kubernetes/manifests"
-`
+containerRuntimeEndpoint: %q
+staticPodPath: %q
+`, i.cluster.Release().KubeletConfigAPI, anonymous, filepath.Join(i.kubeletDir, "ca.crt"), authzMode, i.cluster.Network.ClusterDomain, i.cluster.Network.ClusterDNS,
+		"unix://"+i.cluster.Paths.ContainerdSocket, i.manifestsDir)
 	configPath := filepath.Join(i.kubeletDir, "config.yaml")
 	if err := os.WriteFile(configPath, []byte(kubeletConfig), 0644); err != nil {
 		return fmt.Errorf("failed to write kubelet config: %w", err)
@@ -129,6 +139,18 @@ func exists(p string) bool {
 	return err == nil
 }
 
+// Names of the entries ConfigureKubectl writes to ~/.kube/config. They are
+// specific to the installer, so that reset can remove them without touching
+// entries of other clusters.
+const (
+	kubeconfigCluster = "k8s-installer"
+	kubeconfigUser    = "k8s-installer-admin"
+	kubeconfigContext = "k8s-installer"
+)
+
+// ConfigureKubectl adds the cluster, its admin user and a context to
+// ~/.kube/config and switches to that context. Entries of other clusters
+// are kept.
 func (i *Installer) ConfigureKubectl() error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -146,13 +168,8 @@ func (i *Installer) ConfigureKubectl() error {
 		kubectlPath = "kubectl"
 	}
 
-	if exists(kubeconfigPath) {
-		if err := os.Remove(kubeconfigPath); err != nil {
-			fmt.Printf("Warning: couldn't remove old kubeconfig: %v\n", err)
-		}
-	}
 
-	pkiDir := filepath.Join(i.baseDir, "pki")
+	pkiDir := i.cluster.Paths.PKIDir
 	caCandidates := []string{
 		filepath.Join(pkiDir, "ca.crt"),
 		"/etc/kubernetes/pki/ca.crt",
@@ -178,17 +195,18 @@ func (i *Installer) ConfigureKubectl() error {
 	}
 
 	setClusterArgs := []string{
-		"config", "set-cluster", "local-cluster",
-		"--server=https://127.0.0.1:6443",
+		"config", "set-cluster", kubeconfigCluster,
+		"--server="+i.cluster.APIServerURL(),
 		"--certificate-authority", caPath,
 		"--embed-certs=true",
+		"--kubeconfig="+kubeconfigPath,
 	}
 	
 	if err := runCommand(kubectlPath, setClusterArgs...); err != nil {
 		return fmt.Errorf("failed to configure cluster: %w", err)
 	}
 
-	credArgs := []string{"config", "set-credentials", "admin"}
+	credArgs := []string{"config", "set-credentials", kubeconfigUser, "--kubeconfig=" + kubeconfigPath}
 	if haveAdminCrt && haveAdminKey {
 		credArgs = append(credArgs,
 			"--client-certificate", adminCrt,
@@ -202,27 +220,20 @@ func (i *Installer) ConfigureKubectl() error {
 	}
 
 	if err := runCommand(kubectlPath,
-		"config", "set-context", "local-context",
-		"--cluster=local-cluster",
-		"--user=admin",
+		"config", "set-context", kubeconfigContext,
+		"--cluster="+kubeconfigCluster,
+		"--user="+kubeconfigUser,
+		"--kubeconfig="+kubeconfigPath,
 	); err != nil {
 		return fmt.Errorf("failed to set context: %w", err)
 	}
 
-	if err := runCommand(kubectlPath, "config", "use-context", "local-context"); err != nil {
+	if err := runCommand(kubectlPath, "config", "use-context", kubeconfigContext, "--kubeconfig="+kubeconfigPath); err != nil {
 		return fmt.Errorf("failed to use context: %w", err)
 	}
 
-	kubeconfigData, err := os.ReadFile(kubeconfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to read kubeconfig: %w", err)
-	}
-
-	kubeletKubeconfigPath := filepath.Join(i.kubeletDir, "kubeconfig")
-	if err := os.WriteFile(kubeletKubeconfigPath, kubeconfigData, 0644); err != nil {
-		return fmt.Errorf("failed to write kubelet kubeconfig: %w", err)
-	}
-
+	// У kubelet свой kubeconfig (system:node:<hostname>), его пишет
+	// GenerateCertificates.
 	return nil
 }
 
@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dereban25/k8s-installer/internal/pki"
+)
+
+// clientIdentity is a client certificate of one component and, for
+// components that talk to the API server, the kubeconfig embedding it.
+type clientIdentity struct {
+	name       string // file name of the .crt/.key pair in the PKI dir
+	cn         string
+	org        string
+	kubeconfig string
+}
+
+func (i *Installer) clientIdentities() ([]clientIdentity, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	return []clientIdentity{
+		// Used by the API server for exec/logs/port-forward on the kubelet.
+		{"apiserver-kubelet-client", "kube-apiserver-kubelet-client", "system:masters", ""},
+		{"controller-manager", "system:kube-controller-manager", "", i.cluster.ComponentKubeconfig("controller-manager")},
+		{"scheduler", "system:kube-scheduler", "", i.cluster.ComponentKubeconfig("scheduler")},
+		// Not run by the installer; bound to system:node-proxier by the
+		// default RBAC policy.
+		{"kube-proxy", "system:kube-proxy", "", i.cluster.ComponentKubeconfig("kube-proxy")},
+		// The Node authorizer only grants a kubelet access to its own node,
+		// identified by this exact name (the kubelet lowercases it).
+		{"kubelet", "system:node:" + strings.ToLower(hostname), "system:nodes", filepath.Join(i.kubeletDir, "kubeconfig")},
+	}, nil
+}
+
+// generateComponentCredentials issues a client certificate per component
+// so that RBAC and the Node authorizer can tell them apart, and writes their
+// kubeconfigs with the certificates embedded. The default profile
+// authorizes everyone, but the components still run as themselves.
+func (i *Installer) generateComponentCredentials(caKey crypto.Signer, caCert *x509.Certificate) error {
+	identities, err := i.clientIdentities()
+	if err != nil {
+		return err
+	}
+
+	pkiDir := i.cluster.Paths.PKIDir
+	for _, id := range identities {
+		key, cert, err := i.ensureCertificate(filepath.Join(pkiDir, id.name), caKey, caCert, clientCertTemplate(id.cn, id.org))
+		if err != nil {
+			return err
+		}
+
+		if id.kubeconfig == "" {
+			continue
+		}
+		data, err := renderKubeconfig(i.cluster.APIServerURL(), id.cn, caCert, cert, key)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(id.kubeconfig), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(id.kubeconfig, data, 0600); err != nil {
+			return fmt.Errorf("failed to write %s kubeconfig: %w", id.name, err)
+		}
+	}
+	return nil
+}
+
+// generateFrontProxyCertificates ensures the front proxy CA and the client
+// certificate the API server presents to aggregated API servers. The CA is
+// separate so that a client certificate signed by it authenticates only
+// proxied requests, with the user taken from the request headers.
+func (i *Installer) generateFrontProxyCertificates() error {
+	pkiDir := i.cluster.Paths.PKIDir
+	caKey, caCert, err := i.ensureCA(filepath.Join(pkiDir, "front-proxy-ca"), pkix.Name{CommonName: "front-proxy-ca"})
+	if err != nil {
+		return err
+	}
+	_, _, err = i.ensureCertificate(filepath.Join(pkiDir, "front-proxy-client"), caKey, caCert, clientCertTemplate("front-proxy-client", ""))
+	return err
+}
+
+// renderKubeconfig builds a self-contained kubeconfig for one client.
+func renderKubeconfig(server, user string, caCert, cert *x509.Certificate, key crypto.Signer) ([]byte, error) {
+	keyPEM, err := pki.EncodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	b64 := base64.StdEncoding.EncodeToString
+	return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: kubernetes
+  cluster:
+    certificate-authority-data: %s
+    server: %s
+users:
+- name: %s
+  user:
+    client-certificate-data: %s
+    client-key-data: %s
+contexts:
+- name: default
+  context:
+    cluster: kubernetes
+    user: %s
+current-context: default
+`, b64(pki.EncodeCertificate(caCert)), server, user,
+		b64(pki.EncodeCertificate(cert)), b64(keyPEM), user)), nil
+}
@@ -0,0 +1,180 @@
+package installer
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func newTestInstaller(t *testing.T, profile string) *Installer {
+	root := t.TempDir()
+	cluster := config.Default()
+	cluster.Paths.BaseDir = filepath.Join(root, "k8s")
+	cluster.Paths.KubeletDir = filepath.Join(root, "kubelet")
+	cluster.Paths.CacheDir = filepath.Join(root, "cache")
+	cluster.Security.Profile = profile
+
+	inst, err := New(&Config{Cluster: cluster})
+	if err != nil {
+		t.Fatalf("Failed to create installer: %v", err)
+	}
+	for _, dir := range []string{cluster.Paths.PKIDir, filepath.Join(cluster.Paths.KubeletDir, "pki")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return inst
+}
+
+func TestGenerateCertificatesHardened(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityHardened)
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+
+	ca := readCertificate(t, filepath.Join(inst.cluster.Paths.PKIDir, "ca.crt"))
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	hostname, _ := os.Hostname()
+	tests := []struct {
+		kubeconfig string
+		cn         string
+		org        string
+	}{
+		{inst.cluster.ComponentKubeconfig("controller-manager"), "system:kube-controller-manager", ""},
+		{inst.cluster.ComponentKubeconfig("scheduler"), "system:kube-scheduler", ""},
+		{inst.cluster.ComponentKubeconfig("kube-proxy"), "system:kube-proxy", ""},
+		{filepath.Join(inst.kubeletDir, "kubeconfig"), "system:node:" + strings.ToLower(hostname), "system:nodes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cn, func(t *testing.T) {
+			var kc struct {
+				Clusters []struct {
+					Cluster struct {
+						Server string `yaml:"server"`
+					} `yaml:"cluster"`
+				} `yaml:"clusters"`
+				Users []struct {
+					User struct {
+						ClientCertificateData string `yaml:"client-certificate-data"`
+					} `yaml:"user"`
+				} `yaml:"users"`
+			}
+			data, err := os.ReadFile(tt.kubeconfig)
+			if err != nil {
+				t.Fatalf("Expected kubeconfig: %v", err)
+			}
+			if err := yaml.Unmarshal(data, &kc); err != nil {
+				t.Fatalf("Invalid kubeconfig: %v", err)
+			}
+			if len(kc.Clusters) != 1 || kc.Clusters[0].Cluster.Server != inst.cluster.APIServerURL() {
+				t.Errorf("Unexpected clusters in kubeconfig: %+v", kc.Clusters)
+			}
+			if len(kc.Users) != 1 {
+				t.Fatalf("Expected one user, got %d", len(kc.Users))
+			}
+
+			der, err := base64.StdEncoding.DecodeString(kc.Users[0].User.ClientCertificateData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert := parseCertificate(t, der)
+			if cert.Subject.CommonName != tt.cn {
+				t.Errorf("Expected CN %s, got %s", tt.cn, cert.Subject.CommonName)
+			}
+			if got := strings.Join(cert.Subject.Organization, ","); got != tt.org {
+				t.Errorf("Expected O %q, got %q", tt.org, got)
+			}
+			if _, err := cert.Verify(x509.VerifyOptions{
+				Roots:     roots,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}); err != nil {
+				t.Errorf("Certificate does not chain to the CA: %v", err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(inst.cluster.Paths.PKIDir, "apiserver-kubelet-client.crt")); err != nil {
+		t.Errorf("Expected apiserver-kubelet-client certificate: %v", err)
+	}
+}
+
+func TestGenerateCertificatesDefaultProfile(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	if err := inst.GenerateCertificates(); err != nil {
+		t.Fatalf("GenerateCertificates failed: %v", err)
+	}
+
+	for _, path := range []string{
+		inst.cluster.ComponentKubeconfig("controller-manager"),
+		inst.cluster.ComponentKubeconfig("scheduler"),
+		filepath.Join(inst.kubeletDir, "kubeconfig"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected component kubeconfigs in the default profile: %v", err)
+		}
+	}
+
+	pkiDir := inst.cluster.Paths.PKIDir
+	client := readCertificate(t, filepath.Join(pkiDir, "front-proxy-client.crt"))
+	if client.Subject.CommonName != "front-proxy-client" {
+		t.Errorf("Unexpected front proxy client %s", client.Subject)
+	}
+	if err := client.CheckSignatureFrom(readCertificate(t, filepath.Join(pkiDir, "front-proxy-ca.crt"))); err != nil {
+		t.Errorf("Expected the front proxy client to be signed by the front proxy CA: %v", err)
+	}
+	if client.CheckSignatureFrom(readCertificate(t, filepath.Join(pkiDir, "ca.crt"))) == nil {
+		t.Error("Expected the front proxy CA to be separate from the cluster CA")
+	}
+}
+
+func TestKubeletConfigAuthorization(t *testing.T) {
+	for profile, want := range map[string]string{
+		config.SecurityDefault:  "mode: AlwaysAllow",
+		config.SecurityHardened: "mode: Webhook",
+	} {
+		inst := newTestInstaller(t, profile)
+		if err := inst.createKubeletConfig(); err != nil {
+			t.Fatalf("createKubeletConfig failed: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(inst.kubeletDir, "config.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s: expected %q in kubelet config:\n%s", profile, want, data)
+		}
+	}
+}
+
+func readCertificate(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseCertificate(t, data)
+}
+
+func parseCertificate(t *testing.T, data []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("No PEM block found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
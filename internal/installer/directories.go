@@ -13,7 +13,7 @@ func (i *Installer) CreateDirectories() error {
 		// Основные директории Kubernetes
 		i.baseDir,
 		filepath.Join(i.baseDir, "bin"),
-		filepath.Join(i.baseDir, "pki"),        // 🔑 КРИТИЧНО: директория для сертификатов
+		i.cluster.Paths.PKIDir,                 // 🔑 КРИТИЧНО: директория для сертификатов
 		i.etcdDataDir,
 		i.manifestsDir,
 		
@@ -22,15 +22,15 @@ func (i *Installer) CreateDirectories() error {
 		filepath.Join(i.kubeletDir, "pki"),     // 🔑 КРИТИЧНО: сертификаты kubelet
 		
 		// Директории для логов
-		"/var/log/kubernetes",
+		i.cluster.Paths.LogDir,
 		
 		// Директории для containerd
-		"/etc/containerd",
-		"/run/containerd",
+		filepath.Dir(i.cluster.Paths.ContainerdConfig),
+		filepath.Dir(i.cluster.Paths.ContainerdSocket),
 		
 		// Директории для CNI
 		i.cniConfDir,
-		"/opt/cni/bin",
+		i.cluster.Paths.CNIBinDir,
 	}
 
 	for _, dir := range dirs {
@@ -1,112 +1,436 @@
 package installer
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/dereban25/k8s-installer/internal/bundle"
+	"github.com/dereban25/k8s-installer/internal/cache"
+	"github.com/dereban25/k8s-installer/internal/config"
 	"github.com/dereban25/k8s-installer/internal/utils"
 )
 
 type download struct {
 	url      string
 	destPath string
-	extract  bool
-	chmod    bool
+	// extract is what to unpack from the archive; nil means the artifact
+	// is the binary itself.
+	extract *extractSpec
+	chmod   bool
+	// sumURL is the sha256 file upstream publishes for the artifact, empty
+	// when there is none (kubebuilder-tools).
+	sumURL string
 }
 
-func (i *Installer) DownloadBinaries() error {
-	downloads := []download{
+// extractSpec unpacks the members of an archive, named after stripping
+// strip leading path elements, into dir.
+type extractSpec struct {
+	dir     string
+	strip   int
+	members []string
+}
+
+// installedPaths lists the files installing the artifact creates.
+func (dl download) installedPaths() []string {
+	if dl.extract == nil {
+		return []string{dl.destPath}
+	}
+	paths := make([]string, len(dl.extract.members))
+	for n, member := range dl.extract.members {
+		paths[n] = filepath.Join(dl.extract.dir, filepath.FromSlash(member))
+	}
+	return paths
+}
+
+// Projects whose artifacts the installer downloads.
+const (
+	projectKubebuilder = "kubebuilder-tools"
+	projectKubernetes  = "kubernetes"
+	projectContainerd  = "containerd"
+	projectRunc        = "runc"
+	projectCNIPlugins  = "cni-plugins"
+	projectCrictl      = "crictl"
+	projectEtcd        = "etcd"
+)
+
+// artifactArches maps every architecture to its name in each project's
+// artifacts. A missing entry means the project publishes no build for it.
+var artifactArches = map[string]map[string]string{
+	projectKubebuilder: {config.ArchAMD64: "amd64", config.ArchARM64: "arm64"},
+	projectKubernetes:  {config.ArchAMD64: "amd64", config.ArchARM64: "arm64"},
+	projectContainerd:  {config.ArchAMD64: "amd64", config.ArchARM64: "arm64"},
+	projectRunc:        {config.ArchAMD64: "amd64", config.ArchARM64: "arm64"},
+	projectCNIPlugins:  {config.ArchAMD64: "amd64", config.ArchARM64: "arm64"},
+	projectCrictl:      {config.ArchAMD64: "amd64", config.ArchARM64: "arm64"},
+	projectEtcd:        {config.ArchAMD64: "amd64", config.ArchARM64: "arm64"},
+}
+
+// archNames returns the name of arch in the artifacts of every project.
+func archNames(arch string) map[string]string {
+	names := map[string]string{}
+	for project, arches := range artifactArches {
+		names[project] = arches[arch]
+	}
+	return names
+}
+
+// checkArch fails when any project publishes no build for arch.
+func checkArch(arch string) error {
+	var missing []string
+	for project, arches := range artifactArches {
+		if _, ok := arches[arch]; !ok {
+			missing = append(missing, project)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("linux/%s is not supported: no %s builds are published for it", arch, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// downloads returns the artifacts for the versions and architecture in the
+// spec.
+func (i *Installer) downloads() []download {
+	arch := archNames(i.cluster.Runtime.Arch)
+	return []download{
 		{
-			url:      fmt.Sprintf("https://storage.googleapis.com/kubebuilder-tools/kubebuilder-tools-%s-linux-amd64.tar.gz", KubebuilderVersion),
+			url:      fmt.Sprintf("https://storage.googleapis.com/kubebuilder-tools/kubebuilder-tools-%s-linux-%s.tar.gz", i.cluster.Versions.Kubebuilder, arch[projectKubebuilder]),
 			destPath: "/tmp/kubebuilder-tools.tar.gz",
-			extract:  true,
+			extract: &extractSpec{
+				// kubebuilder/bin/... -> <baseDir>/bin/...
+				dir:     i.baseDir,
+				strip:   1,
+				members: []string{"bin/kube-apiserver", "bin/etcd", "bin/kubectl"},
+			},
 		},
 		{
-			url:      fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/amd64/kubelet", i.config.K8sVersion),
+			url:      fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/%s/kubelet", i.cluster.Versions.Kubernetes, arch[projectKubernetes]),
 			destPath: filepath.Join(i.baseDir, "bin", "kubelet"),
+			sumURL:   fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/%s/kubelet.sha256", i.cluster.Versions.Kubernetes, arch[projectKubernetes]),
 			chmod:    true,
 		},
 		{
-			url:      fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/amd64/kube-controller-manager", i.config.K8sVersion),
+			url:      fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/%s/kube-controller-manager", i.cluster.Versions.Kubernetes, arch[projectKubernetes]),
 			destPath: filepath.Join(i.baseDir, "bin", "kube-controller-manager"),
+			sumURL:   fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/%s/kube-controller-manager.sha256", i.cluster.Versions.Kubernetes, arch[projectKubernetes]),
 			chmod:    true,
 		},
 		{
-			url:      fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/amd64/kube-scheduler", i.config.K8sVersion),
+			url:      fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/%s/kube-scheduler", i.cluster.Versions.Kubernetes, arch[projectKubernetes]),
 			destPath: filepath.Join(i.baseDir, "bin", "kube-scheduler"),
+			sumURL:   fmt.Sprintf("https://dl.k8s.io/%s/bin/linux/%s/kube-scheduler.sha256", i.cluster.Versions.Kubernetes, arch[projectKubernetes]),
 			chmod:    true,
 		},
 		// ✅ containerd
 		{
-			url:      fmt.Sprintf("https://github.com/containerd/containerd/releases/download/v%s/containerd-%s-linux-amd64.tar.gz", ContainerdVersion, ContainerdVersion),
+			url:      fmt.Sprintf("https://github.com/containerd/containerd/releases/download/v%s/containerd-%s-linux-%s.tar.gz", i.cluster.Versions.Containerd, i.cluster.Versions.Containerd, arch[projectContainerd]),
 			destPath: "/tmp/containerd.tar.gz",
-			extract:  true,
+			sumURL:   fmt.Sprintf("https://github.com/containerd/containerd/releases/download/v%s/containerd-%s-linux-%s.tar.gz.sha256sum", i.cluster.Versions.Containerd, i.cluster.Versions.Containerd, arch[projectContainerd]),
+			extract: &extractSpec{
+				dir:     filepath.Join(i.baseDir, "bin"),
+				strip:   1,
+				members: []string{"containerd", "containerd-shim-runc-v2", "ctr"},
+			},
 		},
 		{
-			url:      fmt.Sprintf("https://github.com/opencontainers/runc/releases/download/%s/runc.amd64", RuncVersion),
+			url:      fmt.Sprintf("https://github.com/opencontainers/runc/releases/download/%s/runc.%s", i.cluster.Versions.Runc, arch[projectRunc]),
 			destPath: filepath.Join(i.baseDir, "bin", "runc"),
+			sumURL:   fmt.Sprintf("https://github.com/opencontainers/runc/releases/download/%s/runc.sha256sum", i.cluster.Versions.Runc),
 			chmod:    true,
 		},
 		{
-			url:      fmt.Sprintf("https://github.com/containernetworking/plugins/releases/download/%s/cni-plugins-linux-amd64-%s.tgz", CNIPluginsVersion, CNIPluginsVersion),
+			url:      fmt.Sprintf("https://github.com/containernetworking/plugins/releases/download/%s/cni-plugins-linux-%s-%s.tgz", i.cluster.Versions.CNIPlugins, arch[projectCNIPlugins], i.cluster.Versions.CNIPlugins),
 			destPath: "/tmp/cni-plugins.tgz",
-			extract:  true,
+			sumURL:   fmt.Sprintf("https://github.com/containernetworking/plugins/releases/download/%s/cni-plugins-linux-%s-%s.tgz.sha256", i.cluster.Versions.CNIPlugins, arch[projectCNIPlugins], i.cluster.Versions.CNIPlugins),
+			extract: &extractSpec{
+				dir: i.cluster.Paths.CNIBinDir,
+				// containerd needs loopback for lo in every pod
+				members: []string{"bridge", "host-local", "loopback", "portmap"},
+			},
 		},
 		{
-			url:      fmt.Sprintf("https://github.com/kubernetes-sigs/cri-tools/releases/download/%s/crictl-%s-linux-amd64.tar.gz", CrictlVersion, CrictlVersion),
+			url:      fmt.Sprintf("https://github.com/kubernetes-sigs/cri-tools/releases/download/%s/crictl-%s-linux-%s.tar.gz", i.cluster.Versions.Crictl, i.cluster.Versions.Crictl, arch[projectCrictl]),
 			destPath: "/tmp/crictl.tar.gz",
-			extract:  true,
+			sumURL:   fmt.Sprintf("https://github.com/kubernetes-sigs/cri-tools/releases/download/%s/crictl-%s-linux-%s.tar.gz.sha256", i.cluster.Versions.Crictl, i.cluster.Versions.Crictl, arch[projectCrictl]),
+			extract: &extractSpec{
+				dir:     filepath.Join(i.baseDir, "bin"),
+				members: []string{"crictl"},
+			},
+		},
+		// etcdutl is needed for etcd restore
+		{
+			url:      fmt.Sprintf("https://github.com/etcd-io/etcd/releases/download/%s/etcd-%s-linux-%s.tar.gz", i.cluster.Versions.Etcd, i.cluster.Versions.Etcd, arch[projectEtcd]),
+			destPath: "/tmp/etcd-release.tar.gz",
+			sumURL:   fmt.Sprintf("https://github.com/etcd-io/etcd/releases/download/%s/SHA256SUMS", i.cluster.Versions.Etcd),
+			// etcd itself comes from kubebuilder-tools; only etcdutl is taken
+			// from the release
+			extract: &extractSpec{
+				dir:     filepath.Join(i.baseDir, "bin"),
+				strip:   1,
+				members: []string{"etcdutl"},
+			},
 		},
 	}
+}
 
-	for _, dl := range downloads {
-		log.Printf("  Downloading %s...", filepath.Base(dl.url))
+// downloadConcurrency is how many artifacts are downloaded at once.
+const downloadConcurrency = 4
 
-		if err := utils.DownloadFile(dl.url, dl.destPath); err != nil {
-			return fmt.Errorf("failed to download %s: %w", dl.url, err)
+// DownloadBinaries installs the artifacts of the configured versions,
+// verified against their checksums. Artifacts that are already installed
+// are skipped, and the rest come from the download cache when possible.
+func (i *Installer) DownloadBinaries() error {
+	if i.config.SkipDownload {
+		log.Println("  Skipping download (--skip-download), using the installed binaries")
+		return i.checkInstalled()
+	}
+
+	if arch, host := i.cluster.Runtime.Arch, config.HostArch(); arch != host {
+		log.Printf("  ⚠ Installing linux/%s binaries on a %s host", arch, host)
+	}
+
+	pinned, err := pinnedChecksums()
+	if err != nil {
+		return err
+	}
+	installed, err := i.loadInstalled()
+	if err != nil {
+		return err
+	}
+
+	// known is the sha256 the artifact must have when it is known without
+	// the network; it decides whether the artifact has to be reinstalled.
+	known := func(dl download) string {
+		if sum, ok := pinned[dl.url]; ok {
+			return sum
+		}
+		return installed[dl.url]
+	}
+	c := i.DownloadCache()
+	fetch := func(dl download) (string, error) {
+		return i.fetchCached(c, pinned, dl)
+	}
+	if i.config.Bundle != "" {
+		log.Printf("  Extracting bundle %s...", i.config.Bundle)
+		manifest, err := bundle.Extract(i.config.Bundle, i.bundleDir())
+		if err != nil {
+			return fmt.Errorf("failed to open bundle: %w", err)
 		}
+		if manifest.Arch != "" && manifest.Arch != i.cluster.Runtime.Arch {
+			return fmt.Errorf("bundle was created for linux/%s, but runtime.arch is %s", manifest.Arch, i.cluster.Runtime.Arch)
+		}
+		known = func(dl download) string {
+			a, _ := manifest.Artifact(dl.url)
+			return a.SHA256
+		}
+		fetch = func(dl download) (string, error) {
+			return i.copyFromBundle(manifest, pinned, dl)
+		}
+	}
 
-		if dl.extract {
-			if err := i.extractArchive(dl.destPath); err != nil {
-				return fmt.Errorf("failed to extract %s: %w", dl.destPath, err)
-			}
-			_ = os.Remove(dl.destPath)
+	var pending []download
+	for _, dl := range i.downloads() {
+		if sum := known(dl); sum != "" && i.upToDate(installed, dl, sum) {
+			log.Printf("  ✓ %s is up to date", path.Base(dl.url))
+			continue
 		}
+		pending = append(pending, dl)
+	}
 
-		if dl.chmod {
-			if err := os.Chmod(dl.destPath, 0755); err != nil {
-				return fmt.Errorf("failed to chmod %s: %w", dl.destPath, err)
-			}
+	// Artifacts are downloaded in parallel but installed one at a time:
+	// archives unpack into shared directories.
+	sums := make([]string, len(pending))
+	errs := make([]error, len(pending))
+	sem := make(chan struct{}, downloadConcurrency)
+	var wg sync.WaitGroup
+	for n, dl := range pending {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			sums[n], errs[n] = fetch(dl)
+		}()
+	}
+	wg.Wait()
+
+	// Whatever was downloaded is installed, even if other downloads failed.
+	for n, dl := range pending {
+		if errs[n] != nil {
+			continue
+		}
+		if err := i.install(dl); err != nil {
+			return err
+		}
+		installed[dl.url] = sums[n]
+		if err := i.saveInstalled(installed); err != nil {
+			return err
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// install unpacks a downloaded artifact or makes it executable.
+func (i *Installer) install(dl download) error {
+	if spec := dl.extract; spec != nil {
+		if _, err := utils.ExtractTarGz(dl.destPath, spec.dir, utils.ExtractOptions{StripComponents: spec.strip, Members: spec.members}); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", path.Base(dl.url), err)
+		}
+		_ = os.Remove(dl.destPath)
+	}
 
+	if dl.chmod {
+		if err := os.Chmod(dl.destPath, 0755); err != nil {
+			return fmt.Errorf("failed to chmod %s: %w", dl.destPath, err)
+		}
+	}
 	return nil
 }
 
-func (i *Installer) extractArchive(archivePath string) error {
-	var cmd *exec.Cmd
+// fetchCached copies the artifact from the download cache to dl.destPath,
+// downloading it into the cache on a miss. It returns the artifact's sha256.
+func (i *Installer) fetchCached(c *cache.Cache, pinned map[string]string, dl download) (string, error) {
+	entry, ok, err := c.Lookup(dl.url, pinned[dl.url])
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		log.Printf("  Using cached %s", path.Base(dl.url))
+	} else {
+		sum, err := expectedChecksum(i.downloader, pinned, dl)
+		if err != nil {
+			return "", err
+		}
+		log.Printf("  Downloading %s...", path.Base(dl.url))
+		if entry, err = c.Fetch(dl.url, sum); err != nil {
+			return "", fmt.Errorf("failed to download %s: %w", dl.url, err)
+		}
+	}
+	if err := copyFile(c.Path(entry), dl.destPath); err != nil {
+		return "", err
+	}
+	return entry.SHA256, nil
+}
+
+// DownloadCache returns the download cache configured in the spec.
+func (i *Installer) DownloadCache() *cache.Cache {
+	c := cache.New(i.cluster.Paths.CacheDir)
+	c.Downloader = i.downloader
+	return c
+}
+
+// PruneCache removes the cached artifacts the configured versions do not
+// use, or every artifact when all is set.
+func (i *Installer) PruneCache(all bool) ([]cache.Entry, error) {
+	used := map[string]bool{}
+	for _, dl := range i.downloads() {
+		used[dl.url] = true
+	}
+	return i.DownloadCache().Prune(func(e cache.Entry) bool {
+		return !all && used[e.URL]
+	})
+}
+
+// installedFile records the sha256 of every installed artifact by URL, so
+// that reinstalling the same versions skips them.
+func (i *Installer) installedFile() string {
+	return filepath.Join(i.baseDir, "bin", ".installed.json")
+}
 
-	switch {
-	case strings.Contains(archivePath, "kubebuilder-tools"):
-		cmd = exec.Command("tar", "-C", i.baseDir, "--strip-components=1", "-zxf", archivePath)
-	case strings.Contains(archivePath, "containerd"):
-		// ✅ распаковываем bin/containerd внутрь baseDir/bin
-		cmd = exec.Command("tar", "-C", filepath.Join(i.baseDir, "bin"), "--strip-components=1", "-zxf", archivePath)
-	case strings.Contains(archivePath, "cni-plugins"):
-		cmd = exec.Command("tar", "zxf", archivePath, "-C", "/opt/cni/bin/")
-	case strings.Contains(archivePath, "crictl"):
-		cmd = exec.Command("tar", "zxf", archivePath, "-C", filepath.Join(i.baseDir, "bin"))
-	default:
-		return fmt.Errorf("unknown archive type: %s", archivePath)
+func (i *Installer) loadInstalled() (map[string]string, error) {
+	installed := map[string]string{}
+	data, err := os.ReadFile(i.installedFile())
+	if os.IsNotExist(err) {
+		return installed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &installed); err != nil {
+		// A broken record only means reinstalling.
+		log.Printf("  ⚠ Ignoring unreadable %s: %v", i.installedFile(), err)
+		return map[string]string{}, nil
 	}
+	return installed, nil
+}
 
-	output, err := cmd.CombinedOutput()
+func (i *Installer) saveInstalled(installed map[string]string) error {
+	data, err := json.MarshalIndent(installed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(i.installedFile(), data, 0644)
+}
+
+// upToDate reports whether the artifact with this sha256 is installed. A
+// binary is checked by its content, an archive by its record and the
+// unpacked files.
+func (i *Installer) upToDate(installed map[string]string, dl download, sum string) bool {
+	if installed[dl.url] != sum {
+		return false
+	}
+	if dl.extract != nil {
+		for _, p := range dl.installedPaths() {
+			if _, err := os.Stat(p); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	got, err := utils.SHA256File(dl.destPath)
+	return err == nil && got == sum
+}
+
+// checkInstalled verifies that every artifact is in place for
+// --skip-download.
+func (i *Installer) checkInstalled() error {
+	var missing []string
+	for _, dl := range i.downloads() {
+		for _, p := range dl.installedPaths() {
+			if _, err := os.Stat(p); err != nil {
+				missing = append(missing, p)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("--skip-download given but binaries are missing: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// copyFile replaces dst through a rename, so that even a running binary can
+// be upgraded.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
 	if err != nil {
-		return fmt.Errorf("extraction failed: %w, output: %s", err, output)
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
 	}
 	return nil
 }
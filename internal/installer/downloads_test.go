@@ -0,0 +1,62 @@
+package installer
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func TestDownloadURLsForEveryArch(t *testing.T) {
+	for _, arch := range config.SupportedArches {
+		t.Run(arch, func(t *testing.T) {
+			inst := newTestInstaller(t, config.SecurityDefault)
+			inst.cluster.Runtime.Arch = arch
+
+			for _, dl := range inst.downloads() {
+				if !strings.Contains(dl.url, arch) {
+					t.Errorf("Expected %s in %s", arch, dl.url)
+				}
+				for _, other := range config.SupportedArches {
+					if other != arch && strings.Contains(dl.url, other) {
+						t.Errorf("Unexpected %s in %s", other, dl.url)
+					}
+				}
+				if dl.sumURL != "" && path.Dir(dl.sumURL) != path.Dir(dl.url) {
+					t.Errorf("Expected the checksum of %s next to it, got %s", dl.url, dl.sumURL)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadURLsForARM64(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	inst.cluster.Runtime.Arch = config.ArchARM64
+
+	want := map[string]bool{
+		"https://dl.k8s.io/v1.30.0/bin/linux/arm64/kubelet":                                         true,
+		"https://github.com/opencontainers/runc/releases/download/v1.2.6/runc.arm64":                true,
+		"https://github.com/etcd-io/etcd/releases/download/v3.5.12/etcd-v3.5.12-linux-arm64.tar.gz": true,
+	}
+	for _, dl := range inst.downloads() {
+		delete(want, dl.url)
+	}
+	for url := range want {
+		t.Errorf("Expected a download of %s", url)
+	}
+}
+
+func TestCheckArch(t *testing.T) {
+	for _, arch := range config.SupportedArches {
+		if err := checkArch(arch); err != nil {
+			t.Errorf("Expected every artifact to be published for %s: %v", arch, err)
+		}
+	}
+
+	err := checkArch("riscv64")
+	if err == nil || !strings.Contains(err.Error(), "kubebuilder-tools") {
+		t.Errorf("Expected the missing projects to be named, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package installer
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/dereban25/k8s-installer/internal/pki"
+)
+
+// generateEtcdCertificates ensures a dedicated etcd CA with the server,
+// peer and health check certificates under the etcd PKI dir, and the client
+// certificate the API server uses to reach etcd.
+func (i *Installer) generateEtcdCertificates() error {
+	etcdDir := i.cluster.EtcdPKIDir()
+	if err := os.MkdirAll(etcdDir, 0755); err != nil {
+		return fmt.Errorf("failed to create etcd PKI directory: %w", err)
+	}
+
+	caKey, caCert, err := i.ensureCA(filepath.Join(etcdDir, "ca"), pkix.Name{CommonName: "etcd-ca"})
+	if err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	// All members share the server and peer certificates, so they carry
+	// the address of every member.
+	hosts := []string{i.cluster.Network.HostIP}
+	for _, member := range i.cluster.EtcdMembers() {
+		hosts = append(hosts, member.Host)
+	}
+	dnsNames, ips := etcdSANs(hostname, hosts)
+	serverAndClient := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+	certs := []struct {
+		path     string
+		cn       string
+		org      string
+		usages   []x509.ExtKeyUsage
+		dnsNames []string
+		ips      []net.IP
+	}{
+		// Peers authenticate each other with the same certificate in both
+		// directions, so server and peer certs are valid for both usages.
+		{filepath.Join(etcdDir, "server"), hostname, "", serverAndClient, dnsNames, ips},
+		{filepath.Join(etcdDir, "peer"), hostname, "", serverAndClient, dnsNames, ips},
+		{filepath.Join(etcdDir, "healthcheck-client"), "kube-etcd-healthcheck-client", "", []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, nil, nil},
+		{filepath.Join(i.cluster.Paths.PKIDir, "apiserver-etcd-client"), "kube-apiserver-etcd-client", "system:masters", []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, nil, nil},
+	}
+	for _, c := range certs {
+		template := &x509.Certificate{
+			Subject:     pkix.Name{CommonName: c.cn},
+			KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: c.usages,
+			DNSNames:    c.dnsNames,
+			IPAddresses: c.ips,
+		}
+		if c.org != "" {
+			template.Subject.Organization = []string{c.org}
+		}
+		if _, _, err := i.ensureCertificate(c.path, caKey, caCert, template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// etcdSANs lists the names etcd is reached by: loopback, this host and
+// the addresses of all members.
+func etcdSANs(hostname string, hosts []string) ([]string, []net.IP) {
+	dnsNames := []string{"localhost", hostname}
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback}
+	for _, host := range hosts {
+		ip := net.ParseIP(host)
+		if ip == nil || ip.IsLoopback() || containsIP(ips, ip) {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	return dnsNames, ips
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, existing := range ips {
+		if existing.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// signCertificate generates an alg key and signs template with the CA; a
+// nil CA makes the certificate self-signed.
+func signCertificate(caKey crypto.Signer, caCert *x509.Certificate, template *x509.Certificate, alg pki.KeyAlgorithm) (crypto.Signer, *x509.Certificate, error) {
+	key, err := pki.GenerateKey(alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := pki.Sign(template, key, caCert, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// saveKeyPair writes <base>.crt and <base>.key.
+func (i *Installer) saveKeyPair(base string, key crypto.Signer, cert *x509.Certificate) error {
+	if err := i.saveCertificate(base+".crt", cert); err != nil {
+		return err
+	}
+	return i.savePrivateKey(base+".key", key)
+}
@@ -0,0 +1,112 @@
+package installer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func TestGenerateEtcdCertificates(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	inst.cluster.Network.HostIP = "192.168.1.10"
+	inst.cluster.Etcd.Members = []config.EtcdMember{
+		{Name: "etcd-a", Host: "192.168.1.10"},
+		{Name: "etcd-b", Host: "192.168.1.11"},
+	}
+	if err := inst.generateEtcdCertificates(); err != nil {
+		t.Fatalf("generateEtcdCertificates failed: %v", err)
+	}
+
+	etcdDir := inst.cluster.EtcdPKIDir()
+	etcdCA := readCertificate(t, filepath.Join(etcdDir, "ca.crt"))
+	roots := x509.NewCertPool()
+	roots.AddCert(etcdCA)
+
+	server := readCertificate(t, filepath.Join(etcdDir, "server.crt"))
+	for _, ip := range []string{"127.0.0.1", "192.168.1.10", "192.168.1.11"} {
+		if _, err := server.Verify(x509.VerifyOptions{
+			Roots:     roots,
+			DNSName:   ip,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}); err != nil {
+			t.Errorf("Server certificate is not valid for %s: %v", ip, err)
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join(etcdDir, "peer.crt"),
+		filepath.Join(etcdDir, "healthcheck-client.crt"),
+		filepath.Join(inst.cluster.Paths.PKIDir, "apiserver-etcd-client.crt"),
+	} {
+		cert := readCertificate(t, path)
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			t.Errorf("%s is not a client certificate of the etcd CA: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestEtcdCertificatesMutualTLS(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	if err := inst.generateEtcdCertificates(); err != nil {
+		t.Fatalf("generateEtcdCertificates failed: %v", err)
+	}
+	etcdDir := inst.cluster.EtcdPKIDir()
+
+	roots := x509.NewCertPool()
+	caPEM, err := os.ReadFile(filepath.Join(etcdDir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AppendCertsFromPEM(caPEM)
+
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(etcdDir, "server.crt"), filepath.Join(etcdDir, "server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"health":"true"}`))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    roots,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	get := func(certs []tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certs,
+		}}}
+		resp, err := client.Get(srv.URL + "/health")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// httptest listens on 127.0.0.1, which the server certificate covers.
+	if err := get(nil); err == nil {
+		t.Error("Expected a client without certificate to be rejected")
+	}
+	clientCert, err := tls.LoadX509KeyPair(
+		filepath.Join(inst.cluster.Paths.PKIDir, "apiserver-etcd-client.crt"),
+		filepath.Join(inst.cluster.Paths.PKIDir, "apiserver-etcd-client.key"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get([]tls.Certificate{clientCert}); err != nil {
+		t.Errorf("Expected the API server client certificate to be accepted: %v", err)
+	}
+}
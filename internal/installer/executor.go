@@ -0,0 +1,209 @@
+package installer
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// validateGraph checks that every dependency exists and that the steps form
+// a DAG.
+func validateGraph(steps []step) error {
+	index := map[string]int{}
+	for idx, s := range steps {
+		if _, dup := index[s.id]; dup {
+			return fmt.Errorf("duplicate step %q", s.id)
+		}
+		index[s.id] = idx
+	}
+	for _, s := range steps {
+		for _, dep := range s.deps {
+			if _, ok := index[dep]; !ok {
+				return fmt.Errorf("step %q depends on unknown step %q", s.id, dep)
+			}
+		}
+	}
+	if _, err := waves(steps); err != nil {
+		return err
+	}
+	return nil
+}
+
+// waves groups steps into levels: every step only depends on steps from
+// earlier levels. Dependencies outside the given slice are treated as
+// already satisfied. Within a level, declaration order is kept.
+func waves(steps []step) ([][]step, error) {
+	selected := map[string]bool{}
+	for _, s := range steps {
+		selected[s.id] = true
+	}
+
+	done := map[string]bool{}
+	remaining := steps
+	var result [][]step
+	for len(remaining) > 0 {
+		var wave, rest []step
+		for _, s := range remaining {
+			if depsSatisfied(s, selected, done) {
+				wave = append(wave, s)
+			} else {
+				rest = append(rest, s)
+			}
+		}
+		if len(wave) == 0 {
+			var ids []string
+			for _, s := range rest {
+				ids = append(ids, s.id)
+			}
+			return nil, fmt.Errorf("dependency cycle between steps: %s", strings.Join(ids, ", "))
+		}
+		for _, s := range wave {
+			done[s.id] = true
+		}
+		result = append(result, wave)
+		remaining = rest
+	}
+	return result, nil
+}
+
+func depsSatisfied(s step, selected, done map[string]bool) bool {
+	for _, dep := range s.deps {
+		if selected[dep] && !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// dependents returns the id and all transitive dependents of the given step.
+func dependents(steps []step, id string) map[string]bool {
+	result := map[string]bool{id: true}
+	for changed := true; changed; {
+		changed = false
+		for _, s := range steps {
+			if result[s.id] {
+				continue
+			}
+			for _, dep := range s.deps {
+				if result[dep] {
+					result[s.id] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+type stepResult struct {
+	step step
+	err  error
+}
+
+// executeGraph runs the steps respecting their dependencies with at most
+// parallel steps in flight. onSuccess is called from the scheduling
+// goroutine, so it does not need to be safe for concurrent use.
+//
+// On failure no new steps are started, the running ones are waited for and
+// the first error is returned. With continueOnError the failure is logged
+// and dependents run anyway, matching the sequential behaviour.
+func executeGraph(steps []step, parallel int, continueOnError bool, onSuccess func(step)) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	selected := map[string]bool{}
+	for _, s := range steps {
+		selected[s.id] = true
+	}
+
+	done := map[string]bool{}
+	started := map[string]bool{}
+	results := make(chan stepResult)
+	running := 0
+	var firstErr error
+
+	for {
+		if firstErr == nil {
+			for _, s := range steps {
+				if running >= parallel {
+					break
+				}
+				if started[s.id] || !depsSatisfied(s, selected, done) {
+					continue
+				}
+				started[s.id] = true
+				running++
+				log.Printf("=> %s...", s.name)
+				go func(s step) {
+					results <- stepResult{step: s, err: s.fn()}
+				}(s)
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		done[res.step.id] = true
+
+		if res.err != nil {
+			if continueOnError {
+				log.Printf("WARNING: %s failed: %v", res.step.name, res.err)
+				continue
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed at step '%s' (rerun resumes here, or use --from-step=%s): %w",
+					res.step.name, res.step.id, res.err)
+			} else {
+				log.Printf("WARNING: %s also failed: %v", res.step.name, res.err)
+			}
+			continue
+		}
+
+		log.Printf("%s completed", res.step.name)
+		onSuccess(res.step)
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if len(done) != len(steps) {
+		return fmt.Errorf("dependency cycle: only %d of %d steps could run", len(done), len(steps))
+	}
+	return nil
+}
+
+// formatPlan renders the step graph wave by wave. Steps recorded as done in
+// the state are marked so it is obvious what a rerun would skip.
+func formatPlan(steps []step, st *State, parallel int) (string, error) {
+	ws, err := waves(steps)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Installation plan: %d steps in %d waves, up to %d in parallel\n", len(steps), len(ws), parallel)
+	for n, wave := range ws {
+		fmt.Fprintf(&b, "\nWave %d:\n", n+1)
+		for _, s := range wave {
+			deps := append([]string(nil), s.deps...)
+			sort.Strings(deps)
+
+			status := ""
+			if st != nil && st.isCompleted(s.id) {
+				status = " [done]"
+			}
+			if len(deps) == 0 {
+				fmt.Fprintf(&b, "  %s%s\n", s.id, status)
+			} else {
+				fmt.Fprintf(&b, "  %-20s <- %s%s\n", s.id, strings.Join(deps, ", "), status)
+			}
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,195 @@
+package installer
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func TestExecuteGraphRespectsDependencies(t *testing.T) {
+	var mu sync.Mutex
+	finished := map[string]bool{}
+	var violations []string
+
+	mk := func(id string, deps ...string) step {
+		return step{id: id, name: id, deps: deps, fn: func() error {
+			mu.Lock()
+			for _, d := range deps {
+				if !finished[d] {
+					violations = append(violations, id+" started before "+d)
+				}
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			finished[id] = true
+			mu.Unlock()
+			return nil
+		}}
+	}
+
+	steps := []step{
+		mk("dirs"),
+		mk("download", "dirs"),
+		mk("certs", "dirs"),
+		mk("etcd", "download"),
+		mk("apiserver", "etcd", "certs"),
+	}
+
+	var order []string
+	if err := executeGraph(steps, 4, false, func(s step) { order = append(order, s.id) }); err != nil {
+		t.Fatalf("executeGraph failed: %v", err)
+	}
+
+	if len(violations) > 0 {
+		t.Errorf("Dependency violations: %v", violations)
+	}
+	if len(order) != len(steps) {
+		t.Errorf("Expected %d completed steps, got %v", len(steps), order)
+	}
+}
+
+func TestExecuteGraphBoundsConcurrency(t *testing.T) {
+	var current, peak int32
+	mk := func(id string) step {
+		return step{id: id, name: id, fn: func() error {
+			n := atomic.AddInt32(&current, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&current, -1)
+			return nil
+		}}
+	}
+
+	steps := []step{mk("a"), mk("b"), mk("c"), mk("d"), mk("e")}
+	if err := executeGraph(steps, 2, false, func(step) {}); err != nil {
+		t.Fatalf("executeGraph failed: %v", err)
+	}
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent steps, got %d", peak)
+	}
+	if peak < 2 {
+		t.Errorf("Expected independent steps to run in parallel, peak was %d", peak)
+	}
+}
+
+func TestExecuteGraphStopsOnFailure(t *testing.T) {
+	var ranDependent bool
+	steps := []step{
+		{id: "a", name: "a", fn: func() error { return errors.New("boom") }},
+		{id: "b", name: "b", deps: []string{"a"}, fn: func() error { ranDependent = true; return nil }},
+	}
+
+	err := executeGraph(steps, 2, false, func(step) {})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Expected failure from step a, got %v", err)
+	}
+	if ranDependent {
+		t.Error("Dependent step ran after its dependency failed")
+	}
+
+	ranDependent = false
+	if err := executeGraph(steps, 2, true, func(step) {}); err != nil {
+		t.Fatalf("Expected no error with continueOnError, got %v", err)
+	}
+	if !ranDependent {
+		t.Error("Expected dependent step to run with continueOnError")
+	}
+}
+
+func TestValidateGraph(t *testing.T) {
+	cycle := []step{
+		{id: "a", deps: []string{"b"}},
+		{id: "b", deps: []string{"a"}},
+	}
+	if err := validateGraph(cycle); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+
+	unknown := []step{{id: "a", deps: []string{"missing"}}}
+	if err := validateGraph(unknown); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("Expected unknown dependency error, got %v", err)
+	}
+
+	for _, mode := range []string{config.ControlPlaneHost, config.ControlPlaneStaticPod} {
+		inst, err := New(&Config{ControlPlane: mode})
+		if err != nil {
+			t.Fatalf("Failed to create installer: %v", err)
+		}
+		if err := validateGraph(inst.steps()); err != nil {
+			t.Errorf("Installer step graph for %s control plane is invalid: %v", mode, err)
+		}
+	}
+}
+
+func TestEtcdWaitsForCertificates(t *testing.T) {
+	for _, mode := range []string{config.ControlPlaneHost, config.ControlPlaneStaticPod} {
+		inst, err := New(&Config{ControlPlane: mode})
+		if err != nil {
+			t.Fatalf("Failed to create installer: %v", err)
+		}
+		steps := inst.steps()
+		idx, err := findStep(steps, "etcd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// etcd reads its serving and peer certificates on start.
+		if !slices.Contains(steps[idx].deps, "certificates") {
+			t.Errorf("%s control plane: expected etcd to depend on certificates, got %v", mode, steps[idx].deps)
+		}
+	}
+}
+
+func TestStaticPodGraphStartsKubeletFirst(t *testing.T) {
+	inst, err := New(&Config{ControlPlane: config.ControlPlaneStaticPod})
+	if err != nil {
+		t.Fatalf("Failed to create installer: %v", err)
+	}
+
+	steps := inst.steps()
+	if !dependents(steps, "kubelet")["etcd"] {
+		t.Error("Expected etcd to run after the kubelet")
+	}
+	// The kubelet must not wait for the API server it is about to run.
+	if dependents(steps, "apiserver")["kubelet"] {
+		t.Error("Expected kubelet not to depend on the API server")
+	}
+}
+
+func TestFormatPlan(t *testing.T) {
+	steps := []step{
+		{id: "dirs"},
+		{id: "download", deps: []string{"dirs"}},
+		{id: "certs", deps: []string{"dirs"}},
+	}
+	st := &State{Completed: map[string]time.Time{"dirs": time.Now()}}
+
+	out, err := formatPlan(steps, st, 4)
+	if err != nil {
+		t.Fatalf("formatPlan failed: %v", err)
+	}
+
+	if !strings.Contains(out, "3 steps in 2 waves") {
+		t.Errorf("Unexpected plan header:\n%s", out)
+	}
+	if !strings.Contains(out, "  dirs [done]") {
+		t.Errorf("Expected completed step to be marked:\n%s", out)
+	}
+	if !strings.Contains(out, "certs                <- dirs") {
+		t.Errorf("Expected dependency to be listed:\n%s", out)
+	}
+}
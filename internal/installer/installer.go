@@ -5,35 +5,68 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/dereban25/k8s-installer/internal/config"
 	"github.com/dereban25/k8s-installer/internal/services"
-)
-
-const (
-	ContainerdVersion  = "2.0.5"
-	RuncVersion        = "v1.2.6"
-	CNIPluginsVersion  = "v1.6.2"
-	KubebuilderVersion = "1.30.0"
-	CrictlVersion      = "v1.30.0"
+	"github.com/dereban25/k8s-installer/internal/utils"
 )
 
 type Installer struct {
 	config       *Config
+	cluster      *config.ClusterConfig
 	baseDir      string
 	kubeletDir   string
 	services     *services.Manager
 	etcdDataDir  string
 	manifestsDir string
 	cniConfDir   string
+	downloader   *utils.Downloader
 }
 
 type Config struct {
-	K8sVersion      string
+	// Cluster is the resolved cluster spec (defaults, --config file and
+	// environment). When nil, New resolves it from defaults and environment.
+	Cluster *config.ClusterConfig
+
+	// K8sVersion overrides Cluster.Versions.Kubernetes when set. Both may
+	// be "latest-<minor>", resolved through ReleaseIndex.
+	K8sVersion string
+	// Init overrides Cluster.Runtime.Init when set.
+	Init string
+	// ControlPlane overrides Cluster.Runtime.ControlPlane when set.
+	ControlPlane string
+	// SecurityProfile overrides Cluster.Security.Profile when set.
+	SecurityProfile string
+	// Arch overrides Cluster.Runtime.Arch when set.
+	Arch string
 	SkipDownload    bool
 	SkipVerify      bool
 	SkipAPIWait     bool
 	ContinueOnError bool
 	Verbose         bool
+
+	// FromStep re-executes the named step and everything after it.
+	FromStep string
+	// OnlyStep executes just the named step.
+	OnlyStep string
+	// Fresh ignores steps recorded as completed by a previous run.
+	Fresh bool
+	// Parallel bounds how many independent steps run at once.
+	Parallel int
+	// Bundle is an install bundle to take artifacts and images from
+	// instead of the network.
+	Bundle string
+	// Progress selects how download progress is shown: auto, tty, plain,
+	// json or none (see utils.NewProgressReporter).
+	Progress string
+	// ReleaseIndex resolves "latest-<minor>" versions; nil reads
+	// DefaultReleaseIndex.
+	ReleaseIndex ReleaseIndex
+	// Installed resolves "latest-<minor>" to the version recorded in the
+	// state file instead of asking ReleaseIndex, for maintenance commands
+	// that have to work offline on an existing installation.
+	Installed bool
 }
 
 func New(cfg *Config) (*Installer, error) {
@@ -41,69 +74,159 @@ func New(cfg *Config) (*Installer, error) {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
-	baseDir := "/var/lib/kubernetes"
-	kubeletDir := "/var/lib/kubelet"
-	hostIP := "127.0.0.1"
+	cluster := cfg.Cluster
+	if cluster == nil {
+		var err error
+		if cluster, err = config.Resolve(""); err != nil {
+			return nil, err
+		}
+	}
 
-	if v := os.Getenv("K8S_BASE_DIR"); v != "" {
-		baseDir = v
+	// Flags are the last layer on top of the spec.
+	if cfg.K8sVersion != "" {
+		cluster.Versions.Kubernetes = cfg.K8sVersion
 	}
-	if v := os.Getenv("K8S_KUBELET_DIR"); v != "" {
-		kubeletDir = v
+	if cfg.Init != "" {
+		cluster.Runtime.Init = cfg.Init
+	}
+	if cfg.ControlPlane != "" {
+		cluster.Runtime.ControlPlane = cfg.ControlPlane
+	}
+	if cfg.SecurityProfile != "" {
+		cluster.Security.Profile = cfg.SecurityProfile
+	}
+	if cfg.Arch != "" {
+		cluster.Runtime.Arch = cfg.Arch
+	}
+
+	downloader := utils.NewDownloader()
+	transport, err := utils.NewTransport(cluster.Downloads.Proxy, cluster.Downloads.CABundle)
+	if err != nil {
+		return nil, err
 	}
-	if v := os.Getenv("K8S_HOST_IP"); v != "" {
-		hostIP = v
+	downloader.Client.Transport = transport
+	downloader.Mirror = cluster.MirrorURL
+	if downloader.Progress, err = utils.NewProgressReporter(cfg.Progress, os.Stderr); err != nil {
+		return nil, err
 	}
 
+	requested := cluster.Versions.Kubernetes
+	if cfg.Installed {
+		cluster.Versions.Kubernetes, err = installedK8sVersion(filepath.Join(cluster.Paths.BaseDir, stateFileName), requested)
+	} else {
+		index := cfg.ReleaseIndex
+		if index == nil {
+			index = &markerIndex{base: DefaultReleaseIndex, downloader: downloader}
+		}
+		cluster.Versions.Kubernetes, err = resolveK8sVersion(index, requested)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if requested != cluster.Versions.Kubernetes {
+		log.Printf("Resolved Kubernetes %s to %s", requested, cluster.Versions.Kubernetes)
+	}
+
+	cluster.Complete()
+	if err := cluster.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkArch(cluster.Runtime.Arch); err != nil {
+		return nil, err
+	}
+	cfg.Cluster = cluster
+	cfg.K8sVersion = cluster.Versions.Kubernetes
+
 	inst := &Installer{
 		config:       cfg,
-		baseDir:      baseDir,
-		kubeletDir:   kubeletDir,
-		services:     services.NewManager(baseDir, kubeletDir, hostIP, cfg.SkipAPIWait),
-		etcdDataDir:  filepath.Join(baseDir, "etcd"),
-		manifestsDir: filepath.Join(baseDir, "manifests"),
-		cniConfDir:   "/etc/cni/net.d",
+		cluster:      cluster,
+		baseDir:      cluster.Paths.BaseDir,
+		kubeletDir:   cluster.Paths.KubeletDir,
+		services:     services.NewManagerFromConfig(cluster, cfg.SkipAPIWait),
+		etcdDataDir:  cluster.Paths.EtcdDataDir,
+		manifestsDir: cluster.Paths.ManifestsDir,
+		cniConfDir:   cluster.Paths.CNIConfDir,
+		downloader:   downloader,
 	}
 	return inst, nil
 }
 
+func (i *Installer) GetBaseDir() string {
+	return i.baseDir
+}
+
+func (i *Installer) GetKubeletDir() string {
+	return i.kubeletDir
+}
+
+func (i *Installer) GetHostIP() string {
+	return i.cluster.Network.HostIP
+}
+
+// Services returns the manager that starts and supervises the components.
+func (i *Installer) Services() *services.Manager {
+	return i.services
+}
+
+// loadRunState reads the saved progress, discarding it when it belongs to a
+// different Kubernetes version or --fresh was requested.
+func (i *Installer) loadRunState() (*State, error) {
+	st, err := loadState(i.statePath())
+	if err != nil {
+		return nil, err
+	}
+	if i.config.Fresh || (st.K8sVersion != "" && st.K8sVersion != i.cluster.Versions.Kubernetes) {
+		if len(st.Completed) > 0 {
+			log.Printf("Discarding saved progress for %s", st.K8sVersion)
+		}
+		st.Completed = map[string]time.Time{}
+	}
+	st.K8sVersion = i.cluster.Versions.Kubernetes
+	return st, nil
+}
+
+// Plan renders the step graph that Run would execute.
+func (i *Installer) Plan() (string, error) {
+	steps := i.steps()
+	if err := validateGraph(steps); err != nil {
+		return "", err
+	}
+	st, err := i.loadRunState()
+	if err != nil {
+		return "", err
+	}
+	return formatPlan(steps, st, i.config.Parallel)
+}
+
 func (i *Installer) Run() error {
-	steps := []struct {
-		name string
-		fn   func() error
-	}{
-		{"Creating directories", i.CreateDirectories},
-		{"Downloading binaries", i.DownloadBinaries},
-		{"Generating certificates", i.GenerateCertificates},
-		{"Creating configurations", i.CreateConfigurations},
-		{"Starting etcd", i.services.StartEtcd},
-		{"Starting API server", i.services.StartAPIServer},
-		{"Configure kubectl", i.ConfigureKubectl},
-		{"Testing API connectivity", i.TestAPIServerConnection},
-		{"Verifying kubeconfig", i.VerifyKubeconfigSetup},
-		{"Starting containerd", i.services.StartContainerd},
-		{"Starting controller-manager", i.services.StartControllerManager},
-		{"Starting scheduler", i.services.StartScheduler},
-		{"Starting kubelet", i.services.StartKubelet},
-		{"Creating system namespaces", i.services.CreateSystemNamespaces},  // Added this step
-		{"Creating default resources", i.CreateDefaultResources},
-		{"Verifying installation", i.VerifyInstallation},
-		{"Testing deployment", i.TestDeployment},  // Added test step
+	st, err := i.loadRunState()
+	if err != nil {
+		return err
+	}
+
+	steps := i.steps()
+	if err := validateGraph(steps); err != nil {
+		return err
+	}
+	selected, err := selectSteps(steps, st, i.config.FromStep, i.config.OnlyStep)
+	if err != nil {
+		return err
+	}
+	if skipped := len(steps) - len(selected); skipped > 0 {
+		log.Printf("Skipping %d step(s) recorded in %s", skipped, i.statePath())
 	}
 
 	log.Println("Starting Kubernetes installation...")
-	for _, step := range steps {
-		log.Printf("=> %s...", step.name)
-		if err := step.fn(); err != nil {
-			if i.config.ContinueOnError {
-				log.Printf("WARNING: %s failed: %v", step.name, err)
-				continue
-			}
-			return fmt.Errorf("failed at step '%s': %w", step.name, err)
+	err = executeGraph(selected, i.config.Parallel, i.config.ContinueOnError, func(s step) {
+		st.markCompleted(s.id)
+		if err := st.save(i.statePath()); err != nil {
+			log.Printf("WARNING: failed to record progress: %v", err)
 		}
-		log.Printf("%s completed", step.name)
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Println("Kubernetes installation completed successfully!")
 	return nil
-}
\ No newline at end of file
+}
@@ -1,7 +1,12 @@
 package installer
 
 import (
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
 )
 
 func TestNew(t *testing.T) {
@@ -21,8 +26,8 @@ func TestNew(t *testing.T) {
 		t.Fatal("Installer is nil")
 	}
 
-	if inst.baseDir != "./kubebuilder" {
-		t.Errorf("Expected baseDir to be './kubebuilder', got '%s'", inst.baseDir)
+	if inst.baseDir != config.DefaultBaseDir {
+		t.Errorf("Expected baseDir to be '%s', got '%s'", config.DefaultBaseDir, inst.baseDir)
 	}
 
 	if inst.config.K8sVersion != "v1.30.0" {
@@ -40,7 +45,7 @@ func TestGetters(t *testing.T) {
 		t.Fatalf("Failed to create installer: %v", err)
 	}
 
-	if inst.GetBaseDir() != "./kubebuilder" {
+	if inst.GetBaseDir() != config.DefaultBaseDir {
 		t.Errorf("GetBaseDir() failed")
 	}
 
@@ -51,4 +56,111 @@ func TestGetters(t *testing.T) {
 	if inst.GetHostIP() == "" {
 		t.Errorf("GetHostIP() returned empty string")
 	}
-}
\ No newline at end of file
+}
+
+func TestNewFlagsOverrideClusterConfig(t *testing.T) {
+	cluster := config.Default()
+	cluster.Paths.BaseDir = "/opt/k8s"
+	cluster.Versions.Kubernetes = "v1.30.1"
+
+	inst, err := New(&Config{Cluster: cluster, K8sVersion: "v1.30.2"})
+	if err != nil {
+		t.Fatalf("Failed to create installer: %v", err)
+	}
+
+	if inst.cluster.Versions.Kubernetes != "v1.30.2" {
+		t.Errorf("Expected flag to override version, got '%s'", inst.cluster.Versions.Kubernetes)
+	}
+	if inst.etcdDataDir != "/opt/k8s/etcd" {
+		t.Errorf("Expected etcdDataDir derived from baseDir, got '%s'", inst.etcdDataDir)
+	}
+}
+
+func TestNewRejectsInvalidClusterConfig(t *testing.T) {
+	cluster := config.Default()
+	cluster.Network.ServiceCIDR = "not-a-cidr"
+
+	if _, err := New(&Config{Cluster: cluster}); err == nil {
+		t.Fatal("Expected error for invalid cluster config")
+	}
+}
+
+func stepIDs(steps []step) []string {
+	var ids []string
+	for _, s := range steps {
+		ids = append(ids, s.id)
+	}
+	return ids
+}
+
+func TestSelectSteps(t *testing.T) {
+	steps := []step{
+		{id: "a"},
+		{id: "b", deps: []string{"a"}},
+		{id: "c", deps: []string{"b"}},
+		{id: "d", deps: []string{"a"}},
+	}
+	st := &State{Completed: map[string]time.Time{"a": time.Now()}}
+
+	tests := []struct {
+		name     string
+		fromStep string
+		onlyStep string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "skips completed", want: []string{"b", "c", "d"}},
+		{name: "from step reruns completed", fromStep: "a", want: []string{"a", "b", "c", "d"}},
+		{name: "from step reruns dependents only", fromStep: "b", want: []string{"b", "c"}},
+		{name: "only step", onlyStep: "a", want: []string{"a"}},
+		{name: "unknown step", onlyStep: "x", wantErr: true},
+		{name: "both flags", fromStep: "a", onlyStep: "b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectSteps(steps, st, tt.fromStep, tt.onlyStep)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(stepIDs(got), tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, stepIDs(got))
+			}
+		})
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", stateFileName)
+
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState on missing file failed: %v", err)
+	}
+	if len(st.Completed) != 0 {
+		t.Errorf("Expected empty state, got %v", st.Completed)
+	}
+
+	st.K8sVersion = "v1.30.0"
+	st.markCompleted("download")
+	if err := st.save(path); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState failed: %v", err)
+	}
+	if !loaded.isCompleted("download") || loaded.isCompleted("etcd") {
+		t.Errorf("Unexpected completed steps: %v", loaded.Completed)
+	}
+	if loaded.K8sVersion != "v1.30.0" {
+		t.Errorf("Expected K8sVersion 'v1.30.0', got '%s'", loaded.K8sVersion)
+	}
+}
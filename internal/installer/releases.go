@@ -0,0 +1,71 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+// DefaultReleaseIndex is where dl.k8s.io publishes stable-<minor>.txt with
+// the newest patch release of every minor.
+const DefaultReleaseIndex = "https://dl.k8s.io/release"
+
+// ReleaseIndex resolves the newest patch release of a Kubernetes minor such
+// as "1.31" to a version such as "v1.31.4".
+type ReleaseIndex interface {
+	Latest(minor string) (string, error)
+}
+
+// markerIndex reads the stable-<minor>.txt markers under base.
+type markerIndex struct {
+	base       string
+	downloader *utils.Downloader
+}
+
+func (x *markerIndex) Latest(minor string) (string, error) {
+	url := fmt.Sprintf("%s/stable-%s.txt", x.base, minor)
+	data, err := x.downloader.FetchSmall(url, 1<<10)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolveK8sVersion turns "latest" and "latest-<minor>" into the release
+// the index reports, checking that it belongs to the requested minor.
+// Other versions are returned unchanged.
+func resolveK8sVersion(index ReleaseIndex, version string) (string, error) {
+	minor, ok, err := config.LatestMinor(version)
+	if err != nil || !ok {
+		return version, err
+	}
+	resolved, err := index.Latest(minor)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", version, err)
+	}
+	if r, known := config.LookupRelease(resolved); !known || r.Minor != minor || !strings.HasPrefix(resolved, "v") {
+		return "", fmt.Errorf("failed to resolve %s: release index returned %q", version, resolved)
+	}
+	return resolved, nil
+}
+
+// installedK8sVersion resolves "latest" and "latest-<minor>" without the
+// network: to the version the state file at path records, or to the first
+// release of the minor when nothing is installed yet. Other versions are
+// returned unchanged.
+func installedK8sVersion(path, version string) (string, error) {
+	minor, ok, err := config.LatestMinor(version)
+	if err != nil || !ok {
+		return version, err
+	}
+	st, err := loadState(path)
+	if err != nil {
+		return "", err
+	}
+	if st.K8sVersion != "" {
+		return st.K8sVersion, nil
+	}
+	return "v" + minor + ".0", nil
+}
@@ -0,0 +1,94 @@
+package installer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/utils"
+)
+
+type fakeIndex map[string]string
+
+func (f fakeIndex) Latest(minor string) (string, error) {
+	if v, ok := f[minor]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("no release for %s", minor)
+}
+
+func TestNewResolvesLatestVersion(t *testing.T) {
+	root := t.TempDir()
+	cluster := config.Default()
+	cluster.Paths.BaseDir = filepath.Join(root, "k8s")
+
+	inst, err := New(&Config{Cluster: cluster, K8sVersion: "latest-1.31", ReleaseIndex: fakeIndex{"1.31": "v1.31.4"}})
+	if err != nil {
+		t.Fatalf("Failed to create installer: %v", err)
+	}
+	if v := inst.cluster.Versions; v.Kubernetes != "v1.31.4" || v.Kubebuilder != "1.31.0" {
+		t.Errorf("Expected v1.31.4 with matching components, got %+v", v)
+	}
+}
+
+func TestNewUsesInstalledVersionOffline(t *testing.T) {
+	root := t.TempDir()
+	cluster := config.Default()
+	cluster.Paths.BaseDir = filepath.Join(root, "k8s")
+	st := &State{K8sVersion: "v1.31.2"}
+	if err := st.save(filepath.Join(cluster.Paths.BaseDir, stateFileName)); err != nil {
+		t.Fatal(err)
+	}
+
+	// An index that fails stands in for dl.k8s.io being unreachable.
+	inst, err := New(&Config{Cluster: cluster, K8sVersion: "latest-1.31", ReleaseIndex: fakeIndex{}, Installed: true})
+	if err != nil {
+		t.Fatalf("Failed to create installer: %v", err)
+	}
+	if v := inst.cluster.Versions.Kubernetes; v != "v1.31.2" {
+		t.Errorf("Expected the installed v1.31.2, got %s", v)
+	}
+
+	if _, err := New(&Config{Cluster: config.Default(), K8sVersion: "latest-1.31", ReleaseIndex: fakeIndex{}}); err == nil {
+		t.Error("Expected install to resolve the version through the index")
+	}
+}
+
+func TestResolveK8sVersion(t *testing.T) {
+	index := fakeIndex{"1.30": "v1.30.8", "1.31": "v1.30.2"}
+
+	if v, err := resolveK8sVersion(index, "v1.30.1"); v != "v1.30.1" || err != nil {
+		t.Errorf("Expected a release to be kept, got %q, %v", v, err)
+	}
+	if v, err := resolveK8sVersion(index, "latest-1.30"); v != "v1.30.8" || err != nil {
+		t.Errorf("Expected v1.30.8, got %q, %v", v, err)
+	}
+	if _, err := resolveK8sVersion(index, "latest-1.31"); err == nil {
+		t.Error("Expected a release of another minor to be rejected")
+	}
+	if _, err := resolveK8sVersion(index, "latest-1.29"); err == nil {
+		t.Error("Expected an unsupported minor to be rejected")
+	}
+}
+
+func TestMarkerIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/release/stable-1.31.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "v1.31.4")
+	}))
+	defer srv.Close()
+
+	index := &markerIndex{base: srv.URL + "/release", downloader: utils.NewDownloader()}
+	if v, err := index.Latest("1.31"); v != "v1.31.4" || err != nil {
+		t.Errorf("Expected v1.31.4, got %q, %v", v, err)
+	}
+	if _, err := index.Latest("1.30"); err == nil {
+		t.Error("Expected a missing marker to fail")
+	}
+}
@@ -0,0 +1,280 @@
+package installer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ResetReport describes everything Reset did (or would do in dry-run mode).
+type ResetReport struct {
+	Stopped   []string
+	Unmounted []string
+	Removed   []string
+	Errors    []error
+}
+
+func (r *ResetReport) fail(format string, args ...any) {
+	err := fmt.Errorf(format, args...)
+	log.Printf("  Warning: %v", err)
+	r.Errors = append(r.Errors, err)
+}
+
+// Reset tears down an installation using the same paths the installer used:
+// it stops all daemons, unmounts kubelet pod volumes, removes the CNI bridge
+// and IPAM state, deletes generated PKI and configuration and drops the
+// installer's entries from ~/.kube/config. Downloaded binaries and logs are
+// kept unless all is set. Reset keeps going after errors and reports all of
+// them.
+func (i *Installer) Reset(dryRun, all bool) (*ResetReport, error) {
+	report := &ResetReport{}
+	paths := i.cluster.Paths
+
+	if !dryRun {
+		// Without manifests the kubelet stops recreating control plane pods.
+		manifests, err := i.services.RemoveStaticPods()
+		report.Removed = append(report.Removed, manifests...)
+		if err != nil {
+			report.fail("failed to remove static pod manifests: %v", err)
+		}
+
+		i.removePods()
+
+		log.Println("=> Stopping components...")
+		stopped, err := i.services.StopAll(10 * time.Second)
+		report.Stopped = stopped
+		if err != nil {
+			report.fail("failed to stop components: %v", err)
+		}
+	}
+
+	log.Println("=> Unmounting kubelet volumes...")
+	mounts, err := readMounts(paths.KubeletDir)
+	if err != nil {
+		report.fail("%v", err)
+	}
+	mountsLeft := err != nil
+	for _, mp := range mounts {
+		if !dryRun {
+			if err := unmount(mp); err != nil {
+				report.fail("failed to unmount %s: %v", mp, err)
+				mountsLeft = true
+				continue
+			}
+		}
+		report.Unmounted = append(report.Unmounted, mp)
+	}
+
+	log.Println("=> Removing CNI network...")
+	bridge := i.cluster.Network.CNIBridge
+	if exists(filepath.Join("/sys/class/net", bridge)) {
+		if !dryRun {
+			if err := runCommand("ip", "link", "delete", bridge); err != nil {
+				report.fail("failed to delete bridge %s: %v", bridge, err)
+			} else {
+				report.Removed = append(report.Removed, "bridge "+bridge)
+			}
+		} else {
+			report.Removed = append(report.Removed, "bridge "+bridge)
+		}
+	}
+
+	log.Println("=> Removing generated files...")
+	targets := []string{
+		filepath.Join(paths.CNIStateDir, "networks", i.cluster.Network.CNINetworkName),
+		filepath.Join(paths.CNIConfDir, "10-"+i.cluster.Network.CNINetworkName+".conf"),
+		paths.ContainerdConfig,
+		paths.PKIDir,
+		paths.EtcdDataDir,
+		i.services.RemoteEtcdDir(),
+		i.bundleDir(),
+		paths.ManifestsDir,
+		paths.KubeletDir,
+		i.statePath(),
+	}
+	units, err := i.services.UnitFiles()
+	if err != nil {
+		report.fail("failed to list systemd units: %v", err)
+	}
+	targets = append(targets, units...)
+	if all {
+		targets = append(targets, paths.BaseDir, paths.LogDir)
+	}
+
+	for _, p := range targets {
+		if !exists(p) {
+			continue
+		}
+		// Never recurse into volumes that are still mounted.
+		if mountsLeft && (p == paths.KubeletDir || strings.HasPrefix(paths.KubeletDir, p+"/")) {
+			report.fail("skipping %s: kubelet volumes are still mounted", p)
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(p); err != nil {
+				report.fail("failed to remove %s: %v", p, err)
+				continue
+			}
+		}
+		report.Removed = append(report.Removed, p)
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		removed, err := removeKubeconfigEntries(filepath.Join(home, ".kube", "config"), dryRun)
+		if err != nil {
+			report.fail("%v", err)
+		} else if removed != "" {
+			report.Removed = append(report.Removed, removed)
+		}
+	}
+
+	if len(units) > 0 && !dryRun {
+		if err := runCommand("systemctl", "daemon-reload"); err != nil {
+			report.fail("failed to reload systemd: %v", err)
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		return report, fmt.Errorf("reset finished with %d error(s)", len(report.Errors))
+	}
+	return report, nil
+}
+
+// removeKubeconfigEntries drops the cluster, user and context that
+// ConfigureKubectl wrote from the kubeconfig at path, keeping entries for
+// other clusters. The file is deleted only when nothing else is left. It
+// returns what was removed, or "" when the file has none of the entries.
+func removeKubeconfigEntries(path string, dryRun bool) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var kubeconfig map[string]any
+	if err := yaml.Unmarshal(data, &kubeconfig); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	found, left := false, 0
+	for key, name := range map[string]string{
+		"clusters": kubeconfigCluster,
+		"users":    kubeconfigUser,
+		"contexts": kubeconfigContext,
+	} {
+		entries, _ := kubeconfig[key].([]any)
+		var keep []any
+		for _, e := range entries {
+			if entry, ok := e.(map[string]any); ok && entry["name"] == name {
+				continue
+			}
+			keep = append(keep, e)
+		}
+		if len(keep) != len(entries) {
+			kubeconfig[key] = keep
+			found = true
+		}
+		left += len(keep)
+	}
+	if !found {
+		return "", nil
+	}
+
+	if left == 0 {
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return "", fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+		return path, nil
+	}
+	if kubeconfig["current-context"] == kubeconfigContext {
+		kubeconfig["current-context"] = ""
+	}
+	if !dryRun {
+		out, err := yaml.Marshal(kubeconfig)
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, out, 0600); err != nil {
+			return "", fmt.Errorf("failed to update %s: %w", path, err)
+		}
+	}
+	return fmt.Sprintf("%s entries from %s", kubeconfigContext, path), nil
+}
+
+// removePods asks the CRI to tear down pod sandboxes so their network
+// namespaces and mounts are released before the daemons go away.
+func (i *Installer) removePods() {
+	crictl := filepath.Join(i.baseDir, "bin", "crictl")
+	if !exists(crictl) || !exists(i.cluster.Paths.ContainerdSocket) {
+		return
+	}
+	log.Println("=> Removing pods...")
+	if err := runCommand(crictl,
+		"--runtime-endpoint", "unix://"+i.cluster.Paths.ContainerdSocket,
+		"--timeout", "30s",
+		"rmp", "--all", "--force",
+	); err != nil {
+		log.Printf("  Warning: failed to remove pods: %v", err)
+	}
+}
+
+// readMounts returns the mount points below dir, deepest first, so that
+// nested mounts are unmounted before their parents.
+func readMounts(dir string) ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+	defer f.Close()
+	return parseMountinfo(f, dir)
+}
+
+func parseMountinfo(r io.Reader, dir string) ([]string, error) {
+	prefix := filepath.Clean(dir) + "/"
+
+	var mounts []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Field 5 is the mount point; spaces in it are escaped as \040.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mp := strings.ReplaceAll(fields[4], `\040`, " ")
+		if strings.HasPrefix(mp, prefix) {
+			mounts = append(mounts, mp)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mounts, func(a, b int) bool {
+		da, db := strings.Count(mounts[a], "/"), strings.Count(mounts[b], "/")
+		if da != db {
+			return da > db
+		}
+		return mounts[a] > mounts[b]
+	})
+	return mounts, nil
+}
+
+func unmount(mp string) error {
+	if err := syscall.Unmount(mp, 0); err != nil {
+		// Busy mounts are detached lazily rather than left behind.
+		return syscall.Unmount(mp, syscall.MNT_DETACH)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func TestParseMountinfo(t *testing.T) {
+	mountinfo := `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+100 22 0:50 / /var/lib/kubelet/pods/abc/volumes/kubernetes.io~projected/kube-api-access rw - tmpfs tmpfs rw
+101 22 0:51 / /var/lib/kubelet/pods/abc/volumes/kubernetes.io~empty-dir/data rw - tmpfs tmpfs rw
+102 22 0:52 / /var/lib/kubelet/pods/abc/volume\040with\040space rw - tmpfs tmpfs rw
+103 22 0:53 / /var/lib/kubelet-other rw - tmpfs tmpfs rw
+104 22 0:54 / /var/lib/kubelet/pods rw - tmpfs tmpfs rw
+`
+
+	got, err := parseMountinfo(strings.NewReader(mountinfo), "/var/lib/kubelet")
+	if err != nil {
+		t.Fatalf("parseMountinfo failed: %v", err)
+	}
+
+	want := []string{
+		"/var/lib/kubelet/pods/abc/volumes/kubernetes.io~projected/kube-api-access",
+		"/var/lib/kubelet/pods/abc/volumes/kubernetes.io~empty-dir/data",
+		"/var/lib/kubelet/pods/abc/volume with space",
+		"/var/lib/kubelet/pods",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestReset(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", filepath.Join(root, "home"))
+
+	cluster := config.Default()
+	cluster.Paths.BaseDir = filepath.Join(root, "k8s")
+	cluster.Paths.KubeletDir = filepath.Join(root, "kubelet")
+	cluster.Paths.LogDir = filepath.Join(root, "log")
+	cluster.Paths.CNIConfDir = filepath.Join(root, "cni", "net.d")
+	cluster.Paths.CNIStateDir = filepath.Join(root, "cni-state")
+	cluster.Paths.ContainerdConfig = filepath.Join(root, "containerd", "config.toml")
+	cluster.Paths.SystemdUnitDir = filepath.Join(root, "systemd")
+	cluster.Network.CNIBridge = "k8stest-none0"
+
+	inst, err := New(&Config{Cluster: cluster})
+	if err != nil {
+		t.Fatalf("Failed to create installer: %v", err)
+	}
+
+	files := []string{
+		filepath.Join(cluster.Paths.BaseDir, "bin", "kubelet"),
+		filepath.Join(cluster.Paths.BaseDir, "pki", "ca.crt"),
+		filepath.Join(cluster.Paths.BaseDir, stateFileName),
+		filepath.Join(cluster.Paths.KubeletDir, "config.yaml"),
+		filepath.Join(cluster.Paths.CNIConfDir, "10-mynet.conf"),
+		filepath.Join(cluster.Paths.CNIStateDir, "networks", "mynet", "10.22.0.2"),
+		cluster.Paths.ContainerdConfig,
+	}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kubeconfig := filepath.Join(root, "home", ".kube", "config")
+	writeKubeconfig(t, kubeconfig, installerKubeconfig)
+	files = append(files, kubeconfig)
+
+	report, err := inst.Reset(true, false)
+	if err != nil {
+		t.Fatalf("Dry-run reset failed: %v", err)
+	}
+	if len(report.Removed) != 7 {
+		t.Errorf("Expected 7 paths to be reported, got %v", report.Removed)
+	}
+	for _, f := range files {
+		if !exists(f) {
+			t.Errorf("Dry run removed %s", f)
+		}
+	}
+
+	if _, err := inst.Reset(false, false); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	for _, f := range files[1:] {
+		if exists(f) {
+			t.Errorf("Expected %s to be removed", f)
+		}
+	}
+	if !exists(files[0]) {
+		t.Error("Expected binaries to be kept without --all")
+	}
+
+	if _, err := inst.Reset(false, true); err != nil {
+		t.Fatalf("Reset --all failed: %v", err)
+	}
+	if exists(cluster.Paths.BaseDir) {
+		t.Error("Expected base dir to be removed with --all")
+	}
+
+	// A kubeconfig for other clusters is not the installer's to delete.
+	writeKubeconfig(t, kubeconfig, otherKubeconfig)
+	if _, err := inst.Reset(false, true); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if data, _ := os.ReadFile(kubeconfig); string(data) != otherKubeconfig {
+		t.Errorf("Expected an unrelated kubeconfig to survive reset, got %q", data)
+	}
+}
+
+const installerKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: k8s-installer
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: k8s-installer-admin
+  user:
+    client-certificate-data: eA==
+contexts:
+- name: k8s-installer
+  context:
+    cluster: k8s-installer
+    user: k8s-installer-admin
+current-context: k8s-installer
+`
+
+// otherKubeconfig belongs to another cluster and uses generic entry names.
+const otherKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: local-cluster
+  cluster:
+    server: https://prod.example.com:6443
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: local-context
+  context:
+    cluster: local-cluster
+    user: admin
+current-context: local-context
+`
+
+func TestResetKeepsOtherKubeconfigs(t *testing.T) {
+	dir := t.TempDir()
+
+	unrelated := filepath.Join(dir, "unrelated")
+	writeKubeconfig(t, unrelated, otherKubeconfig)
+	if removed, err := removeKubeconfigEntries(unrelated, false); err != nil || removed != "" {
+		t.Errorf("Expected nothing to be removed, got %q (%v)", removed, err)
+	}
+	if data, _ := os.ReadFile(unrelated); string(data) != otherKubeconfig {
+		t.Errorf("Expected an unrelated kubeconfig to be left alone, got:\n%s", data)
+	}
+
+	// A kubeconfig the installer's entries were merged into keeps the rest.
+	merged := filepath.Join(dir, "merged")
+	writeKubeconfig(t, merged, strings.Replace(otherKubeconfig, "clusters:\n", "clusters:\n- name: k8s-installer\n  cluster:\n    server: https://127.0.0.1:6443\n", 1))
+	if _, err := removeKubeconfigEntries(merged, false); err != nil {
+		t.Fatalf("removeKubeconfigEntries failed: %v", err)
+	}
+	data, err := os.ReadFile(merged)
+	if err != nil {
+		t.Fatalf("Expected the merged kubeconfig to survive: %v", err)
+	}
+	var kc struct {
+		Clusters []struct {
+			Name string `yaml:"name"`
+		} `yaml:"clusters"`
+		CurrentContext string `yaml:"current-context"`
+	}
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		t.Fatal(err)
+	}
+	if len(kc.Clusters) != 1 || kc.Clusters[0].Name != "local-cluster" || kc.CurrentContext != "local-context" {
+		t.Errorf("Expected only the other cluster's entries to be kept, got:\n%s", data)
+	}
+}
+
+func writeKubeconfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const stateFileName = "install-state.json"
+
+// State records which installation steps have completed so that a rerun
+// can skip them.
+type State struct {
+	K8sVersion string               `json:"k8sVersion"`
+	Completed  map[string]time.Time `json:"completed"`
+}
+
+func (i *Installer) statePath() string {
+	return filepath.Join(i.baseDir, stateFileName)
+}
+
+// loadState reads the state file. A missing file yields an empty state.
+func loadState(path string) (*State, error) {
+	st := &State{Completed: map[string]time.Time{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if st.Completed == nil {
+		st.Completed = map[string]time.Time{}
+	}
+	return st, nil
+}
+
+// save writes the state atomically so an interrupted run never leaves a
+// truncated file behind.
+func (s *State) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *State) isCompleted(id string) bool {
+	_, ok := s.Completed[id]
+	return ok
+}
+
+func (s *State) markCompleted(id string) {
+	s.Completed[id] = time.Now().UTC()
+}
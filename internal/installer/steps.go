@@ -0,0 +1,127 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+// step is a single named unit of the installation. The id is stable and is
+// what gets recorded in the state file and accepted by --from-step/--only-step.
+// deps lists the ids that must complete before the step may start.
+type step struct {
+	id   string
+	name string
+	fn   func() error
+	deps []string
+}
+
+func (i *Installer) steps() []step {
+	if i.cluster.Runtime.ControlPlane == config.ControlPlaneStaticPod {
+		return i.staticPodSteps()
+	}
+	return []step{
+		{"directories", "Creating directories", i.CreateDirectories, nil},
+		{"download", "Downloading binaries", i.DownloadBinaries, []string{"directories"}},
+		{"certificates", "Generating certificates", i.GenerateCertificates, []string{"directories"}},
+		{"configs", "Creating configurations", i.CreateConfigurations, []string{"directories"}},
+		{"etcd", "Starting etcd", i.services.StartEtcd, []string{"download", "certificates"}},
+		{"apiserver", "Starting API server", i.services.StartAPIServer, []string{"etcd", "certificates"}},
+		{"kubectl", "Configure kubectl", i.ConfigureKubectl, []string{"download", "certificates"}},
+		{"api-connectivity", "Testing API connectivity", i.TestAPIServerConnection, []string{"apiserver"}},
+		{"kubeconfig", "Verifying kubeconfig", i.VerifyKubeconfigSetup, []string{"kubectl"}},
+		{"containerd", "Starting containerd", i.services.StartContainerd, []string{"download", "configs"}},
+		{"images", "Importing bundled images", i.ImportImages, []string{"containerd"}},
+		{"controller-manager", "Starting controller-manager", i.services.StartControllerManager, []string{"api-connectivity", "kubectl"}},
+		{"scheduler", "Starting scheduler", i.services.StartScheduler, []string{"api-connectivity", "kubectl"}},
+		{"kubelet", "Starting kubelet", i.services.StartKubelet, []string{"containerd", "images", "api-connectivity", "kubectl"}},
+		{"namespaces", "Creating system namespaces", i.services.CreateSystemNamespaces, []string{"api-connectivity", "kubectl"}},
+		{"default-resources", "Creating default resources", i.CreateDefaultResources, []string{"namespaces"}},
+		{"verify", "Verifying installation", i.VerifyInstallation, []string{"kubelet", "controller-manager", "scheduler", "default-resources", "kubeconfig"}},
+		{"test-deployment", "Testing deployment", i.TestDeployment, []string{"verify"}},
+	}
+}
+
+// staticPodSteps is the graph for a static-pod control plane: the kubelet
+// comes up first and runs etcd and the control plane from manifests, so the
+// node can only be waited for once the API server answers.
+func (i *Installer) staticPodSteps() []step {
+	return []step{
+		{"directories", "Creating directories", i.CreateDirectories, nil},
+		{"download", "Downloading binaries", i.DownloadBinaries, []string{"directories"}},
+		{"certificates", "Generating certificates", i.GenerateCertificates, []string{"directories"}},
+		{"configs", "Creating configurations", i.CreateConfigurations, []string{"directories"}},
+		{"kubectl", "Configure kubectl", i.ConfigureKubectl, []string{"download", "certificates"}},
+		{"kubeconfig", "Verifying kubeconfig", i.VerifyKubeconfigSetup, []string{"kubectl"}},
+		{"containerd", "Starting containerd", i.services.StartContainerd, []string{"download", "configs"}},
+		{"images", "Importing bundled images", i.ImportImages, []string{"containerd"}},
+		{"kubelet", "Starting kubelet", i.services.LaunchKubelet, []string{"containerd", "images", "kubectl"}},
+		{"etcd", "Starting etcd static pod", i.services.StartEtcd, []string{"kubelet", "certificates"}},
+		{"apiserver", "Starting API server static pod", i.services.StartAPIServer, []string{"etcd", "certificates"}},
+		{"api-connectivity", "Testing API connectivity", i.TestAPIServerConnection, []string{"apiserver"}},
+		{"controller-manager", "Starting controller-manager static pod", i.services.StartControllerManager, []string{"api-connectivity", "kubectl"}},
+		{"scheduler", "Starting scheduler static pod", i.services.StartScheduler, []string{"api-connectivity", "kubectl"}},
+		{"node", "Waiting for node", i.services.WaitForNode, []string{"kubelet", "api-connectivity"}},
+		{"namespaces", "Creating system namespaces", i.services.CreateSystemNamespaces, []string{"api-connectivity", "kubectl"}},
+		{"default-resources", "Creating default resources", i.CreateDefaultResources, []string{"namespaces"}},
+		{"verify", "Verifying installation", i.VerifyInstallation, []string{"node", "controller-manager", "scheduler", "default-resources", "kubeconfig"}},
+		{"test-deployment", "Testing deployment", i.TestDeployment, []string{"verify"}},
+	}
+}
+
+func findStep(steps []step, id string) (int, error) {
+	for idx, s := range steps {
+		if s.id == id {
+			return idx, nil
+		}
+	}
+	var ids []string
+	for _, s := range steps {
+		ids = append(ids, s.id)
+	}
+	return -1, fmt.Errorf("unknown step %q (available: %s)", id, strings.Join(ids, ", "))
+}
+
+// selectSteps decides which steps to execute:
+//   - --only-step runs exactly that step, regardless of state;
+//   - --from-step runs that step and every step depending on it, regardless
+//     of state;
+//   - otherwise every step not yet recorded as completed runs.
+//
+// Dependencies of unselected steps are treated as satisfied.
+func selectSteps(steps []step, st *State, fromStep, onlyStep string) ([]step, error) {
+	if fromStep != "" && onlyStep != "" {
+		return nil, fmt.Errorf("--from-step and --only-step are mutually exclusive")
+	}
+
+	if onlyStep != "" {
+		idx, err := findStep(steps, onlyStep)
+		if err != nil {
+			return nil, err
+		}
+		return steps[idx : idx+1], nil
+	}
+
+	if fromStep != "" {
+		if _, err := findStep(steps, fromStep); err != nil {
+			return nil, err
+		}
+		rerun := dependents(steps, fromStep)
+		var selected []step
+		for _, s := range steps {
+			if rerun[s.id] {
+				selected = append(selected, s)
+			}
+		}
+		return selected, nil
+	}
+
+	var pending []step
+	for _, s := range steps {
+		if !st.isCompleted(s.id) {
+			pending = append(pending, s)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,181 @@
+package installer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/services"
+)
+
+// UpgradeOptions tune Upgrade.
+type UpgradeOptions struct {
+	// BackupDir receives the etcd snapshot taken before anything changes.
+	BackupDir string
+	// Timeout bounds how long each restarted component may take to become
+	// healthy before the upgrade is rolled back.
+	Timeout time.Duration
+}
+
+// Upgrade moves the installation recorded in the state file to the
+// Kubernetes version the installer was created for. It snapshots etcd,
+// keeps the installed binaries aside, installs the new ones and restarts
+// the components in services.UpgradeOrder, waiting for each to become
+// healthy. When a component does not come up, the previous binaries are put
+// back and the components restarted so far are restarted on them.
+func (i *Installer) Upgrade(opts UpgradeOptions) error {
+	st, err := loadState(i.statePath())
+	if err != nil {
+		return err
+	}
+	from, to := st.K8sVersion, i.cluster.Versions.Kubernetes
+	if from == "" {
+		return fmt.Errorf("no installation is recorded in %s; run install first", i.statePath())
+	}
+	if err := config.CheckUpgrade(from, to); err != nil {
+		return err
+	}
+	log.Printf("Upgrading Kubernetes from %s to %s...", from, to)
+
+	log.Printf("=> Backing up etcd to %s...", opts.BackupDir)
+	snapshot, err := i.services.BackupEtcd(opts.BackupDir, 0)
+	if err != nil {
+		return fmt.Errorf("etcd backup failed, nothing was changed: %w", err)
+	}
+
+	rollbackDir := i.rollbackDir(from)
+	log.Printf("=> Keeping the %s binaries in %s...", from, rollbackDir)
+	if err := i.backupBinaries(rollbackDir); err != nil {
+		return fmt.Errorf("failed to back up binaries, nothing was changed: %w", err)
+	}
+
+	log.Println("=> Installing new binaries...")
+	if err := i.DownloadBinaries(); err != nil {
+		return i.rollback(from, nil, opts.Timeout, err)
+	}
+	if err := i.ImportImages(); err != nil {
+		return i.rollback(from, nil, opts.Timeout, err)
+	}
+
+	var restarted []string
+	for _, name := range services.UpgradeOrder {
+		log.Printf("=> Restarting %s...", name)
+		restarted = append(restarted, name)
+		if err := i.services.Relaunch(name, opts.Timeout); err != nil {
+			return i.rollback(from, restarted, opts.Timeout, err)
+		}
+	}
+
+	st.K8sVersion = to
+	if err := st.save(i.statePath()); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(rollbackDir); err != nil {
+		log.Printf("  ⚠ Failed to remove %s: %v", rollbackDir, err)
+	}
+	log.Printf("Kubernetes upgraded to %s (etcd snapshot taken before the upgrade: %s)", to, snapshot)
+	return nil
+}
+
+// rollbackDir is where the binaries of version are kept during an upgrade.
+func (i *Installer) rollbackDir(version string) string {
+	return filepath.Join(i.baseDir, "upgrade", version)
+}
+
+// rollback puts the binaries of version from back and restarts the
+// components in restarted on them. It returns cause, annotated with the
+// outcome of the rollback.
+func (i *Installer) rollback(from string, restarted []string, timeout time.Duration, cause error) error {
+	to := i.cluster.Versions.Kubernetes
+	dir := i.rollbackDir(from)
+	log.Printf("=> Upgrade failed, rolling back to %s: %v", from, cause)
+	if err := i.restoreBinaries(dir); err != nil {
+		return fmt.Errorf("upgrade to %s failed: %w; restoring the %s binaries failed too, they are kept in %s: %v", to, cause, from, dir, err)
+	}
+
+	mgr := services.NewManagerFromConfig(i.previousCluster(from), i.config.SkipAPIWait)
+	for _, name := range restarted {
+		log.Printf("  Restarting %s on %s...", name, from)
+		if err := mgr.Relaunch(name, timeout); err != nil {
+			return fmt.Errorf("upgrade to %s failed: %w; %s did not come back on %s either: %v", to, cause, name, from, err)
+		}
+	}
+	return fmt.Errorf("upgrade to %s failed, rolled back to %s: %w", to, from, cause)
+}
+
+// previousCluster is the spec with Kubernetes set back to version. The
+// versions derived from it are taken from the matrix again.
+func (i *Installer) previousCluster(version string) *config.ClusterConfig {
+	prev := *i.cluster
+	prev.Versions.Kubernetes = version
+	prev.Versions.Kubebuilder = ""
+	prev.Versions.Crictl = ""
+	prev.Versions.Etcd = ""
+	prev.Versions.PauseImage = ""
+	prev.Versions.EtcdImage = ""
+	prev.Complete()
+	return &prev
+}
+
+// backupBinaries keeps every installed artifact file, and the record of
+// what is installed, under dir at its absolute path.
+func (i *Installer) backupBinaries(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for _, dl := range i.downloads() {
+		for _, p := range dl.installedPaths() {
+			if err := preserveFile(p, filepath.Join(dir, p)); err != nil {
+				return err
+			}
+		}
+	}
+	// The record is rewritten in place, so it is copied rather than linked.
+	if _, err := os.Stat(i.installedFile()); err != nil {
+		return nil
+	}
+	return copyFile(i.installedFile(), filepath.Join(dir, i.installedFile()))
+}
+
+// restoreBinaries puts the files kept by backupBinaries back.
+func (i *Installer) restoreBinaries(dir string) error {
+	for _, dl := range i.downloads() {
+		for _, p := range dl.installedPaths() {
+			if err := preserveFile(filepath.Join(dir, p), p); err != nil {
+				return err
+			}
+		}
+	}
+	kept := filepath.Join(dir, i.installedFile())
+	if _, err := os.Stat(kept); err != nil {
+		return nil
+	}
+	return copyFile(kept, i.installedFile())
+}
+
+// preserveFile puts the content of src at dst, sharing the inode when both
+// are on one filesystem. dst is replaced through a rename, so neither a
+// running binary nor its kept copy is ever written to.
+func preserveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(src, tmp); err != nil {
+			return err
+		}
+		if err := os.Chmod(tmp, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, dst)
+}
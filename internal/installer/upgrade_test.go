@@ -0,0 +1,75 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func TestUpgradeChecksInstalledVersion(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	opts := UpgradeOptions{BackupDir: t.TempDir()}
+
+	if err := inst.Upgrade(opts); err == nil || !strings.Contains(err.Error(), "run install first") {
+		t.Errorf("Expected a missing installation to be reported, got %v", err)
+	}
+
+	st := &State{K8sVersion: inst.cluster.Versions.Kubernetes}
+	if err := st.save(inst.statePath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Upgrade(opts); err == nil || !strings.Contains(err.Error(), "already installed") {
+		t.Errorf("Expected the installed version to be rejected, got %v", err)
+	}
+}
+
+func TestBackupAndRestoreBinaries(t *testing.T) {
+	inst := newBundleInstaller(t)
+	if err := inst.DownloadBinaries(); err != nil {
+		t.Fatalf("DownloadBinaries failed: %v", err)
+	}
+	kubelet := filepath.Join(inst.baseDir, "bin", "kubelet")
+	want, _ := os.ReadFile(kubelet)
+
+	dir := inst.rollbackDir("v1.30.0")
+	if err := inst.backupBinaries(dir); err != nil {
+		t.Fatalf("backupBinaries failed: %v", err)
+	}
+	if err := copyFile(filepath.Join(inst.baseDir, "bin", "runc"), kubelet); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(inst.installedFile(), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inst.restoreBinaries(dir); err != nil {
+		t.Fatalf("restoreBinaries failed: %v", err)
+	}
+	if got, _ := os.ReadFile(kubelet); string(got) != string(want) {
+		t.Errorf("Expected the kept kubelet back, got %q", got)
+	}
+	if info, err := os.Stat(kubelet); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("Expected the restored kubelet to be executable: %v", err)
+	}
+	installed, err := inst.loadInstalled()
+	if err != nil || len(installed) != len(inst.downloads()) {
+		t.Errorf("Expected the install record back, got %d entries (%v)", len(installed), err)
+	}
+}
+
+func TestPreviousCluster(t *testing.T) {
+	inst := newTestInstaller(t, config.SecurityDefault)
+	inst.cluster.Versions.Kubernetes = "v1.31.2"
+	inst.cluster.Versions.Kubebuilder = "1.31.0"
+
+	prev := inst.previousCluster("v1.30.4")
+	if prev.Versions.Kubernetes != "v1.30.4" || prev.Versions.Kubebuilder != "1.30.0" {
+		t.Errorf("Expected 1.30 versions, got %+v", prev.Versions)
+	}
+	if inst.cluster.Versions.Kubernetes != "v1.31.2" {
+		t.Error("Expected the spec itself to be left alone")
+	}
+}
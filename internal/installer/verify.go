@@ -14,9 +14,10 @@ import (
 func (i *Installer) TestAPIServerConnection() error {
 	log.Println("🔍 Testing API server connectivity...")
 	
+	port := i.cluster.Network.APIServerPort
 	addresses := []string{
-		"127.0.0.1:6443",
-		"localhost:6443",
+		fmt.Sprintf("127.0.0.1:%d", port),
+		fmt.Sprintf("localhost:%d", port),
 	}
 	
 	// Увеличиваем количество попыток и время ожидания
@@ -48,7 +49,7 @@ func (i *Installer) TestAPIServerConnection() error {
 	
 	// Проверяем процесс
 	if exec.Command("pgrep", "kube-apiserver").Run() == nil {
-		log.Println("  ⚠️  API server process is running but not listening on port 6443")
+		log.Printf("  ⚠️  API server process is running but not listening on port %d", port)
 		log.Println("  This might be a configuration or certificate issue")
 		
 		// Показываем логи если возможно
@@ -101,15 +102,16 @@ func (i *Installer) VerifyKubeconfigSetup() error {
 	server := strings.TrimSpace(string(output))
 	log.Printf("  ✓ Cluster server: %s", server)
 	
-	if !strings.Contains(server, "6443") {
-		return fmt.Errorf("❌ kubeconfig uses wrong port! Expected 6443, got: %s", server)
+	port := fmt.Sprintf(":%d", i.cluster.Network.APIServerPort)
+	if !strings.Contains(server, port) {
+		return fmt.Errorf("❌ kubeconfig uses wrong port! Expected %s, got: %s", port, server)
 	}
 	
 	if !strings.HasPrefix(server, "https://") {
 		return fmt.Errorf("❌ kubeconfig uses insecure connection! Expected https://, got: %s", server)
 	}
 	
-	log.Println("  ✓ kubeconfig properly configured (HTTPS on port 6443)")
+	log.Printf("  ✓ kubeconfig properly configured (HTTPS on port %d)", i.cluster.Network.APIServerPort)
 	return nil
 }
 
@@ -131,7 +133,7 @@ func (i *Installer) CreateDefaultResources() error {
 	}
 
 	// Создаем kube-root-ca configmap
-	caPath := filepath.Join(i.baseDir, "pki", "ca.crt")
+	caPath := filepath.Join(i.cluster.Paths.PKIDir, "ca.crt")
 	if err := runCommandWithCheck(kubectlPath, "create", "configmap", "kube-root-ca.crt",
 		fmt.Sprintf("--from-file=ca.crt=%s", caPath), "-n", "default"); err != nil {
 		if !strings.Contains(err.Error(), "already exists") {
@@ -306,7 +308,7 @@ func (i *Installer) TestDeployment() error {
 	log.Printf("  Found ready node(s): %s", readyNodes)
 	
 	// Создаем deployment
-	cmd = exec.Command(kubectlPath, "create", "deployment", "nginx", "--image=nginx:latest")
+	cmd = exec.Command(kubectlPath, "create", "deployment", "nginx", "--image="+i.cluster.Versions.TestImage)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		if !strings.Contains(string(output), "already exists") {
 			return fmt.Errorf("failed to create deployment: %w\nOutput: %s", err, string(output))
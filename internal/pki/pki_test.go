@@ -0,0 +1,112 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestSignAndRoundTrip(t *testing.T) {
+	caKey, err := GenerateKey(ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := Sign(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, caKey, nil, nil)
+	if err != nil {
+		t.Fatalf("Sign CA failed: %v", err)
+	}
+
+	for _, alg := range KeyAlgorithms {
+		t.Run(string(alg), func(t *testing.T) {
+			if testing.Short() && (alg == RSA3072 || alg == RSA4096) {
+				t.Skip("slow key generation")
+			}
+			key, err := GenerateKey(alg)
+			if err != nil {
+				t.Fatalf("GenerateKey failed: %v", err)
+			}
+			if got := AlgorithmOf(key.Public()); got != alg {
+				t.Errorf("Expected algorithm %s, got %s", alg, got)
+			}
+
+			cert, err := Sign(&x509.Certificate{
+				Subject:     pkix.Name{CommonName: "leaf"},
+				NotAfter:    time.Now().Add(time.Hour),
+				KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}, key, ca, caKey)
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			if err := cert.CheckSignatureFrom(ca); err != nil {
+				t.Errorf("Expected the certificate to be signed by the CA: %v", err)
+			}
+			_, isRSA := key.(*rsa.PrivateKey)
+			if enc := cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0; enc != isRSA {
+				t.Errorf("Expected key encipherment only for RSA keys, got %v", enc)
+			}
+
+			data, err := EncodePrivateKey(key)
+			if err != nil {
+				t.Fatalf("EncodePrivateKey failed: %v", err)
+			}
+			if block, _ := pem.Decode(data); block == nil || block.Type != "PRIVATE KEY" {
+				t.Errorf("Expected a PKCS#8 block, got %q", data)
+			}
+			parsed, err := ParsePrivateKey(data)
+			if err != nil {
+				t.Fatalf("ParsePrivateKey failed: %v", err)
+			}
+			if !KeyMatches(parsed, cert) {
+				t.Error("Expected the parsed key to match the certificate")
+			}
+			certs, err := ParseCertificates(append(EncodeCertificate(cert), EncodeCertificate(ca)...))
+			if err != nil || len(certs) != 2 || !certs[0].Equal(cert) {
+				t.Errorf("Expected the certificate and the CA back, got %d (%v)", len(certs), err)
+			}
+		})
+	}
+}
+
+func TestParseLegacyPrivateKeys(t *testing.T) {
+	rsaKey, _ := GenerateKey(RSA2048)
+	ecKey, _ := GenerateKey(ECDSAP384)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey.(*rsa.PrivateKey))},
+		{Type: "EC PRIVATE KEY", Bytes: ecDER},
+	} {
+		key, err := ParsePrivateKey(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Errorf("%s: ParsePrivateKey failed: %v", block.Type, err)
+			continue
+		}
+		if AlgorithmOf(key.Public()) == "" {
+			t.Errorf("%s: unexpected key %T", block.Type, key)
+		}
+	}
+
+	if _, err := ParsePrivateKey(EncodeCertificate(&x509.Certificate{})); err == nil {
+		t.Error("Expected data without a key to be rejected")
+	}
+}
+
+func TestGenerateKeyRejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := GenerateKey("dsa-1024"); err == nil {
+		t.Error("Expected an unknown algorithm to be rejected")
+	}
+}
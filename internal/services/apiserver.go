@@ -1,102 +1,205 @@
 package services
 
 import (
+	"crypto/rand"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 )
 
 func (m *Manager) StartAPIServer() error {
-	// По умолчанию etcd = localhost
-	etcdEndpoint := "127.0.0.1"
+	etcdServers := m.EtcdServers()
+	if len(m.cluster.Etcd.Members) == 0 {
+		var err error
+		if etcdServers, err = m.localEtcdServers(); err != nil {
+			return err
+		}
+	}
+
+	if err := m.ensureTokenFile(); err != nil {
+		return err
+	}
+
+	if err := m.launch(m.apiServerComponent(etcdServers)); err != nil {
+		return err
+	}
 
+	if m.skipAPIWait {
+		log.Println("  ⚠️ Skipping API server readiness check (--skip-api-wait enabled)")
+		log.Println("  Giving API server 15 seconds to start...")
+		time.Sleep(15 * time.Second)
+		return nil
+	}
+
+	log.Println("  Waiting for API server to become ready (up to 10 minutes)...")
+	return m.waitForAPIServer()
+}
+
+// localEtcdServers — для единственного неявного члена проверяем etcd
+// по hostIP и при неудаче переключаемся на 127.0.0.1.
+func (m *Manager) localEtcdServers() ([]string, error) {
 	// Проверим health у etcd по hostIP
-	url := fmt.Sprintf("http://%s:2379/health", m.hostIP)
-	client := &http.Client{Timeout: 1 * time.Second}
+	url := m.cluster.EtcdClientURL(m.hostIP) + "/health"
+	client, err := m.etcdClient(1 * time.Second)
+	if err != nil {
+		return nil, err
+	}
 
 	if resp, err := client.Get(url); err == nil {
 		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode == 200 && strings.Contains(string(body), "health") {
-			etcdEndpoint = m.hostIP
-			log.Printf("  ✓ etcd доступен по %s:2379 (health-check ok), используем его", m.hostIP)
-		} else {
-			log.Printf("  ⚠ etcd health-check по %s:2379 вернул %d (%s), fallback на 127.0.0.1",
-				m.hostIP, resp.StatusCode, string(body))
+			log.Printf("  ✓ etcd доступен по %s (health-check ok), используем его", url)
+			return []string{m.cluster.EtcdClientURL(m.hostIP)}, nil
 		}
+		log.Printf("  ⚠ etcd health-check по %s вернул %d (%s), fallback на 127.0.0.1",
+			url, resp.StatusCode, string(body))
 	} else {
-		log.Printf("  ⚠ etcd health-check по %s:2379 не прошёл (%v), fallback на 127.0.0.1",
-			m.hostIP, err)
+		log.Printf("  ⚠ etcd health-check по %s не прошёл (%v), fallback на 127.0.0.1",
+			url, err)
 	}
+	return []string{m.cluster.EtcdClientURL("127.0.0.1")}, nil
+}
 
+// apiServerComponent собирает API server; etcdServers — клиентские URL
+// всех членов etcd.
+func (m *Manager) apiServerComponent(etcdServers []string) component {
 	// Пути к сертификатам
-	pkiDir := filepath.Join(m.baseDir, "pki")
+	pkiDir := m.cluster.Paths.PKIDir
 	caCert := filepath.Join(pkiDir, "ca.crt")
 	apiServerCert := filepath.Join(pkiDir, "apiserver.crt")
 	apiServerKey := filepath.Join(pkiDir, "apiserver.key")
 	saKey := filepath.Join(pkiDir, "sa.key")
 	saPub := filepath.Join(pkiDir, "sa.pub")
-
-	// Создаем token file если его нет
 	tokenFile := filepath.Join(pkiDir, "token.csv")
-	if _, err := os.Stat(tokenFile); os.IsNotExist(err) {
-		// Создаем простой токен для bootstrap
-		tokenContent := "bootstrap-token-123456,system:bootstrap,10001,\"system:bootstrappers\"\n"
-		if err := os.WriteFile(tokenFile, []byte(tokenContent), 0600); err != nil {
-			log.Printf("  ⚠ Failed to create token file: %v", err)
+
+	authz := []string{
+		"--authorization-mode=AlwaysAllow",
+		"--anonymous-auth=true",
+	}
+	if m.cluster.Hardened() {
+		authz = []string{
+			"--authorization-mode=Node,RBAC",
+			"--anonymous-auth=false",
+			"--enable-admission-plugins=NodeRestriction",
 		}
 	}
 
-	cmd := exec.Command(
-		filepath.Join(m.baseDir, "bin", "kube-apiserver"),
-		fmt.Sprintf("--etcd-servers=http://%s:2379", etcdEndpoint),
-		"--service-cluster-ip-range=10.0.0.0/16",
-		"--bind-address=0.0.0.0",
-		"--secure-port=6443",
-		fmt.Sprintf("--advertise-address=%s", m.hostIP),
+	// Ждем только членов etcd, запущенных на этом хосте.
+	var after []string
+	for _, member := range m.cluster.EtcdMembers() {
+		if m.cluster.IsLocal(member.Host) {
+			after = append(after, m.etcdName(member))
+		}
+	}
 
-		"--authorization-mode=AlwaysAllow",
-		"--anonymous-auth=true",
+	return component{
+		name:        "apiserver",
+		description: "Kubernetes API server",
+		path:        m.binPath("kube-apiserver"),
+		args: append([]string{
+			fmt.Sprintf("--etcd-servers=%s", strings.Join(etcdServers, ",")),
+			fmt.Sprintf("--etcd-cafile=%s", filepath.Join(m.cluster.EtcdPKIDir(), "ca.crt")),
+			fmt.Sprintf("--etcd-certfile=%s", filepath.Join(pkiDir, "apiserver-etcd-client.crt")),
+			fmt.Sprintf("--etcd-keyfile=%s", filepath.Join(pkiDir, "apiserver-etcd-client.key")),
+			fmt.Sprintf("--service-cluster-ip-range=%s", m.cluster.Network.ServiceCIDR),
+			"--bind-address=0.0.0.0",
+			fmt.Sprintf("--secure-port=%d", m.cluster.Network.APIServerPort),
+			fmt.Sprintf("--advertise-address=%s", m.hostIP),
 
-		// 🔑 ИСПРАВЛЕНО: используем правильные пути к сертификатам
-		fmt.Sprintf("--client-ca-file=%s", caCert),
-		fmt.Sprintf("--tls-cert-file=%s", apiServerCert),
-		fmt.Sprintf("--tls-private-key-file=%s", apiServerKey),
-		fmt.Sprintf("--service-account-key-file=%s", saPub),
-		fmt.Sprintf("--service-account-signing-key-file=%s", saKey),
-		fmt.Sprintf("--token-auth-file=%s", tokenFile),
-
-		"--service-account-issuer=https://kubernetes.default.svc.cluster.local",
-		"--enable-priority-and-fairness=false",
-		"--allow-privileged=true",
-		"--profiling=false",
-		"--storage-backend=etcd3",
-		"--storage-media-type=application/json",
-		"--cert-dir=/var/run/kubernetes",
-		"--cloud-provider=external",
-		"--v=5",
-	)
-
-	if err := m.startDaemon(cmd, "/var/log/kubernetes/apiserver.log"); err != nil {
-		return err
+			// 🔑 ИСПРАВЛЕНО: используем правильные пути к сертификатам
+			fmt.Sprintf("--client-ca-file=%s", caCert),
+			fmt.Sprintf("--tls-cert-file=%s", apiServerCert),
+			fmt.Sprintf("--tls-private-key-file=%s", apiServerKey),
+			fmt.Sprintf("--service-account-key-file=%s", saPub),
+			fmt.Sprintf("--service-account-signing-key-file=%s", saKey),
+			fmt.Sprintf("--token-auth-file=%s", tokenFile),
+			// К kubelet (exec/logs/port-forward) API server ходит со своим
+			// клиентским сертификатом: в hardened kubelet авторизует его
+			// через webhook.
+			fmt.Sprintf("--kubelet-client-certificate=%s", filepath.Join(pkiDir, "apiserver-kubelet-client.crt")),
+			fmt.Sprintf("--kubelet-client-key=%s", filepath.Join(pkiDir, "apiserver-kubelet-client.key")),
+			// Aggregation layer: API server проксирует запросы с сертификатом
+			// front-proxy-client и передает пользователя в заголовках.
+			fmt.Sprintf("--requestheader-client-ca-file=%s", filepath.Join(pkiDir, "front-proxy-ca.crt")),
+			"--requestheader-allowed-names=front-proxy-client",
+			"--requestheader-username-headers=X-Remote-User",
+			"--requestheader-group-headers=X-Remote-Group",
+			"--requestheader-extra-headers-prefix=X-Remote-Extra-",
+			fmt.Sprintf("--proxy-client-cert-file=%s", filepath.Join(pkiDir, "front-proxy-client.crt")),
+			fmt.Sprintf("--proxy-client-key-file=%s", filepath.Join(pkiDir, "front-proxy-client.key")),
+
+			fmt.Sprintf("--service-account-issuer=https://kubernetes.default.svc.%s", m.cluster.Network.ClusterDomain),
+			"--enable-priority-and-fairness=false",
+			"--allow-privileged=true",
+			"--profiling=false",
+			"--storage-backend=etcd3",
+			"--storage-media-type=application/json",
+			"--cert-dir=/var/run/kubernetes",
+			"--cloud-provider=external",
+			"--v=5",
+		}, authz...),
+		after: after,
+		image: m.imageFor("kube-apiserver"),
+		mounts: []mount{
+			{path: pkiDir},
+			{path: "/var/run/kubernetes", writable: true},
+		},
 	}
+}
 
-	if m.skipAPIWait {
-		log.Println("  ⚠️ Skipping API server readiness check (--skip-api-wait enabled)")
-		log.Println("  Giving API server 15 seconds to start...")
-		time.Sleep(15 * time.Second)
-		return nil
+// defaultBootstrapToken — общеизвестный токен профиля default.
+const defaultBootstrapToken = "bootstrap-token-123456"
+
+// ensureTokenFile создает token.csv, если его еще нет. В профиле hardened
+// токен случайный и в формате kubeadm (<id>.<secret>); общеизвестный токен,
+// оставшийся от профиля default, заменяется.
+func (m *Manager) ensureTokenFile() error {
+	tokenFile := filepath.Join(m.cluster.Paths.PKIDir, "token.csv")
+	if _, err := os.Stat(tokenFile); err == nil {
+		if !m.cluster.Hardened() || readBootstrapToken(tokenFile) != defaultBootstrapToken {
+			return nil
+		}
 	}
 
-	log.Println("  Waiting for API server to become ready (up to 10 minutes)...")
-	return m.waitForAPIServer()
+	token, user := defaultBootstrapToken, "system:bootstrap"
+	if m.cluster.Hardened() {
+		var err error
+		if token, err = generateBootstrapToken(); err != nil {
+			return fmt.Errorf("failed to generate bootstrap token: %w", err)
+		}
+		user = "system:bootstrap:" + token[:6]
+	}
+
+	tokenContent := fmt.Sprintf("%s,%s,10001,\"system:bootstrappers\"\n", token, user)
+	if err := os.WriteFile(tokenFile, []byte(tokenContent), 0600); err != nil {
+		if m.cluster.Hardened() {
+			return fmt.Errorf("failed to create token file: %w", err)
+		}
+		log.Printf("  ⚠ Failed to create token file: %v", err)
+	}
+	return nil
+}
+
+// generateBootstrapToken возвращает токен вида [a-z0-9]{6}.[a-z0-9]{16}.
+func generateBootstrapToken() (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	token := make([]byte, 22)
+	for i := range token {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		token[i] = alphabet[n.Int64()]
+	}
+	return string(token[:6]) + "." + string(token[6:]), nil
 }
 
 func (m *Manager) waitForAPIServer() error {
@@ -107,17 +210,18 @@ func (m *Manager) waitForAPIServer() error {
 		},
 	}
 
-	tokenFile := filepath.Join(m.baseDir, "pki", "token.csv")
+	tokenFile := filepath.Join(m.cluster.Paths.PKIDir, "token.csv")
 	token := readBootstrapToken(tokenFile)
+	port := m.cluster.Network.APIServerPort
 
 	maxRetries := 300 // 10 минут
 	successCount := 0
 	required := 3
 
 	for i := 0; i < maxRetries; i++ {
-		if probeReadyz(client, "https://127.0.0.1:6443/readyz", token) ||
-			probeReadyz(client, fmt.Sprintf("https://%s:6443/readyz", m.hostIP), token) ||
-			probeReadyz(client, "https://127.0.0.1:6443/livez", token) {
+		if probeReadyz(client, fmt.Sprintf("https://127.0.0.1:%d/readyz", port), token) ||
+			probeReadyz(client, fmt.Sprintf("https://%s:%d/readyz", m.hostIP, port), token) ||
+			probeReadyz(client, fmt.Sprintf("https://127.0.0.1:%d/livez", port), token) {
 			successCount++
 			if successCount >= required {
 				log.Println("  ✓ API server is ready")
@@ -135,7 +239,7 @@ func (m *Manager) waitForAPIServer() error {
 		time.Sleep(2 * time.Second)
 	}
 
-	return fmt.Errorf("API server did not become ready in 10 minutes. Check: tail -100 /var/log/kubernetes/apiserver.log")
+	return fmt.Errorf("API server did not become ready in 10 minutes. Check: tail -100 %s", m.logPath("apiserver"))
 }
 
 func probeReadyz(client *http.Client, url string, token string) bool {
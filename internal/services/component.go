@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/supervisor"
+)
+
+// component — команда запуска одного демона. Флаги собираются один раз
+// в *Component-методах и используются всеми способами запуска, так что
+// supervisor и systemd запускают ровно одно и то же.
+type component struct {
+	name        string
+	description string
+	path        string
+	args        []string
+	// env дополняет окружение процесса (а для systemd — задается в unit).
+	env []string
+	// after — компоненты, которые должны быть запущены раньше.
+	after []string
+	// unitOptions — дополнительные строки секции [Service] unit-файла.
+	unitOptions []string
+
+	// image задан только у компонентов control plane: с ним компонент
+	// может работать как static pod. mounts — пути хоста, нужные процессу.
+	image  string
+	mounts []mount
+}
+
+// mount — путь хоста, монтируемый в static pod по тому же пути.
+type mount struct {
+	path     string
+	writable bool
+}
+
+// launch запускает компонент выбранным в spec способом.
+func (m *Manager) launch(c component) error {
+	var err error
+	switch {
+	case c.image != "" && m.staticPods():
+		err = m.writeStaticPod(c)
+	case m.cluster.Runtime.Init == config.InitSystemd:
+		err = m.startUnit(c)
+	default:
+		err = m.startProcess(c)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось запустить %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// startProcess запускает процесс под супервизором: пишет PID-файл,
+// stdout/stderr идут в <logDir>/<name>.log, при падении процесс перезапускается.
+func (m *Manager) startProcess(c component) error {
+	var env []string
+	if len(c.env) > 0 {
+		env = append(os.Environ(), c.env...)
+	}
+	return m.supervisor.Start(supervisor.Process{
+		Name:    c.name,
+		Path:    c.path,
+		Args:    c.args,
+		Env:     env,
+		LogFile: m.logPath(c.name),
+	})
+}
+
+// pathEnv добавляет каталоги к текущему PATH.
+func pathEnv(dirs ...string) string {
+	path := "PATH=" + os.Getenv("PATH")
+	for _, d := range dirs {
+		path += ":" + d
+	}
+	return path
+}
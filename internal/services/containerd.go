@@ -23,20 +23,7 @@ func (m *Manager) StartContainerd() error {
 		log.Printf("Warning: failed to cleanup old data: %v", err)
 	}
 
-	cmd := exec.Command(
-		filepath.Join(m.baseDir, "bin", "containerd"),
-		"-c", "/etc/containerd/config.toml",
-		"--log-level", "info", // Добавляем явный уровень логирования
-	)
-
-	// Улучшенные переменные окружения для GitHub Actions
-	cmd.Env = append(os.Environ(),
-		"PATH="+os.Getenv("PATH")+":"+filepath.Join(m.baseDir, "bin")+":/usr/local/bin:/usr/sbin",
-		"CONTAINERD_NAMESPACE=k8s.io", // Явно устанавливаем namespace
-		"TMPDIR=/tmp",                 // Устанавливаем временную директорию
-	)
-
-	if err := m.startDaemon(cmd, "/var/log/kubernetes/containerd.log"); err != nil {
+	if err := m.launch(m.containerdComponent()); err != nil {
 		return err
 	}
 
@@ -44,11 +31,31 @@ func (m *Manager) StartContainerd() error {
 	return m.waitForContainerdWithContext()
 }
 
+func (m *Manager) containerdComponent() component {
+	return component{
+		name:        "containerd",
+		description: "containerd container runtime",
+		path:        m.binPath("containerd"),
+		args: []string{
+			"-c", m.cluster.Paths.ContainerdConfig,
+			"--log-level", "info", // Добавляем явный уровень логирования
+		},
+		// Улучшенные переменные окружения для GitHub Actions
+		env: []string{
+			pathEnv(filepath.Join(m.baseDir, "bin"), "/usr/local/bin", "/usr/sbin"),
+			"CONTAINERD_NAMESPACE=k8s.io", // Явно устанавливаем namespace
+			"TMPDIR=/tmp",                 // Устанавливаем временную директорию
+		},
+		// Как в upstream containerd.service: шимы переживают рестарт containerd.
+		unitOptions: []string{"Delegate=yes", "KillMode=process", "OOMScoreAdjust=-999"},
+	}
+}
+
 func (m *Manager) ensureContainerdDirectories() error {
 	dirs := []string{
 		"/var/lib/containerd",
-		"/run/containerd",
-		"/var/log/kubernetes",
+		filepath.Dir(m.cluster.Paths.ContainerdSocket),
+		m.cluster.Paths.LogDir,
 		"/tmp/containerd",
 	}
 
@@ -63,9 +70,10 @@ func (m *Manager) ensureContainerdDirectories() error {
 
 func (m *Manager) cleanupOldContainerdData() error {
 	// Удаляем старые сокеты и lock файлы
+	socket := m.cluster.Paths.ContainerdSocket
 	filesToCleanup := []string{
-		"/run/containerd/containerd.sock",
-		"/run/containerd/containerd.sock.ttrpc",
+		socket,
+		socket + ".ttrpc",
 		"/var/lib/containerd/io.containerd.metadata.v1.bolt/meta.db.lock",
 	}
 
@@ -131,8 +139,9 @@ func (m *Manager) waitForContainerdProcess(ctx context.Context) error {
 }
 
 func (m *Manager) waitForSocket(ctx context.Context) error {
-	socketPath := "/run/containerd/containerd.sock"
-	
+	socketPath := m.cluster.Paths.ContainerdSocket
+
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -172,7 +181,7 @@ func (m *Manager) waitForMetadataStore(ctx context.Context) error {
 		}
 
 		// Проверяем последние строки лога
-		if output, err := exec.Command("tail", "-5", "/var/log/kubernetes/containerd.log").Output(); err == nil {
+		if output, err := exec.Command("tail", "-5", m.logPath("containerd")).Output(); err == nil {
 			logContent := string(output)
 			
 			// Если нет сообщений о BoltDB проблемах в последних строках
@@ -195,7 +204,7 @@ func (m *Manager) waitForMetadataStore(ctx context.Context) error {
 func (m *Manager) waitForCRIPlugin(ctx context.Context, maxRetries int) error {
 	criCmd := []string{
 		"crictl",
-		"--runtime-endpoint", "unix:///run/containerd/containerd.sock",
+		"--runtime-endpoint", "unix://" + m.cluster.Paths.ContainerdSocket,
 		"--timeout", "5s", // Добавляем таймаут для crictl
 	}
 
@@ -244,12 +253,12 @@ func (m *Manager) showDetailedDiagnostics() {
 	}
 	
 	// Сокеты
-	if output, err := exec.Command("ls", "-la", "/run/containerd/").Output(); err == nil {
+	if output, err := exec.Command("ls", "-la", filepath.Dir(m.cluster.Paths.ContainerdSocket)).Output(); err == nil {
 		log.Printf("  Containerd sockets:\n%s", string(output))
 	}
 	
 	// Последние логи
-	if output, err := exec.Command("tail", "-20", "/var/log/kubernetes/containerd.log").Output(); err == nil {
+	if output, err := exec.Command("tail", "-20", m.logPath("containerd")).Output(); err == nil {
 		log.Printf("  Last 20 log lines:\n%s", string(output))
 	}
 	
@@ -264,7 +273,7 @@ func (m *Manager) showDetailedDiagnostics() {
 	}
 
 	// Попытка подключения к сокету
-	if output, err := exec.Command("timeout", "5", "ctr", "--address", "/run/containerd/containerd.sock", "version").Output(); err != nil {
+	if output, err := exec.Command("timeout", "5", "ctr", "--address", m.cluster.Paths.ContainerdSocket, "version").Output(); err != nil {
 		log.Printf("  Direct socket test failed: %v", err)
 	} else {
 		log.Printf("  Direct socket test successful:\n%s", string(output))
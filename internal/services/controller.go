@@ -2,27 +2,41 @@ package services
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
 )
 
 func (m *Manager) StartControllerManager() error {
-	pkiDir := filepath.Join(m.baseDir, "pki")
-	
-	cmd := exec.Command(
-		filepath.Join(m.baseDir, "bin", "kube-controller-manager"),
-		fmt.Sprintf("--kubeconfig=%s/kubeconfig", m.kubeletDir),
-		"--leader-elect=false",
-		"--cloud-provider=external",
-		"--service-cluster-ip-range=10.0.0.0/24",
-		"--cluster-name=kubernetes",
-		fmt.Sprintf("--root-ca-file=%s/ca.crt", pkiDir),
-		fmt.Sprintf("--service-account-private-key-file=%s/sa.key", pkiDir),
-		"--use-service-account-credentials=true",
-		"--v=2",
-	)
-	cmd.Env = append(os.Environ(), "PATH="+os.Getenv("PATH")+":/opt/cni/bin:/usr/sbin")
+	return m.launch(m.controllerManagerComponent())
+}
 
-	return m.startDaemon(cmd, "/var/log/kubernetes/controller-manager.log")
-}
\ No newline at end of file
+func (m *Manager) controllerManagerComponent() component {
+	pkiDir := m.cluster.Paths.PKIDir
+
+	// Своя учетка system:kube-controller-manager вместо admin.
+	kubeconfig := m.cluster.ComponentKubeconfig("controller-manager")
+
+	return component{
+		name:        "controller-manager",
+		description: "Kubernetes controller manager",
+		path:        m.binPath("kube-controller-manager"),
+		args: []string{
+			fmt.Sprintf("--kubeconfig=%s", kubeconfig),
+			fmt.Sprintf("--authentication-kubeconfig=%s", kubeconfig),
+			fmt.Sprintf("--authorization-kubeconfig=%s", kubeconfig),
+			"--leader-elect=false",
+			"--cloud-provider=external",
+			fmt.Sprintf("--service-cluster-ip-range=%s", m.cluster.Network.ServiceCIDR),
+			"--cluster-name=kubernetes",
+			fmt.Sprintf("--root-ca-file=%s/ca.crt", pkiDir),
+			fmt.Sprintf("--service-account-private-key-file=%s/sa.key", pkiDir),
+			"--use-service-account-credentials=true",
+			"--v=2",
+		},
+		env:   []string{pathEnv(m.cluster.Paths.CNIBinDir, "/usr/sbin")},
+		after: []string{"apiserver"},
+		image: m.imageFor("kube-controller-manager"),
+		mounts: []mount{
+			{path: kubeconfig},
+			{path: pkiDir},
+		},
+	}
+}
@@ -1,66 +1,267 @@
 package services
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os/exec"
+	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
 )
 
 func (m *Manager) StartEtcd() error {
-	cmd := exec.Command(
-		filepath.Join(m.baseDir, "bin", "etcd"),
-		fmt.Sprintf("--advertise-client-urls=http://%s:2379", m.hostIP),
-		"--listen-client-urls=http://0.0.0.0:2379",
-		"--data-dir=./etcd",
-		"--listen-peer-urls=http://0.0.0.0:2380",
-		fmt.Sprintf("--initial-cluster=default=http://%s:2380", m.hostIP),
-		fmt.Sprintf("--initial-advertise-peer-urls=http://%s:2380", m.hostIP),
-		"--initial-cluster-state=new",
-		"--initial-cluster-token=test-token",
-	)
-
-	if err := m.startDaemon(cmd, "/var/log/kubernetes/etcd.log"); err != nil {
-		return err
+	for _, member := range m.cluster.EtcdMembers() {
+		if !m.cluster.IsLocal(member.Host) {
+			path, err := m.writeRemoteEtcdFlags(member)
+			if err != nil {
+				return err
+			}
+			log.Printf("  etcd member %s (%s) is remote: copy %s there and start etcd with the flags from %s",
+				member.Name, member.Host, m.cluster.EtcdPKIDir(), path)
+			continue
+		}
+		if err := m.launch(m.etcdComponent(member)); err != nil {
+			return err
+		}
 	}
 
 	log.Println("  Waiting for etcd to become ready...")
 	return m.waitForEtcd()
 }
 
+// etcdName — имя компонента для члена etcd. Единственный член сохраняет
+// прежние имя и каталог данных, несколько — etcd-<name> и <etcdDataDir>/<name>.
+func (m *Manager) etcdName(member config.EtcdMember) string {
+	if len(m.cluster.Etcd.Members) <= 1 {
+		return "etcd"
+	}
+	return "etcd-" + member.Name
+}
+
+// LocalEtcdNames возвращает имена компонентов членов etcd на этом хосте.
+func (m *Manager) LocalEtcdNames() []string {
+	var names []string
+	for _, member := range m.cluster.EtcdMembers() {
+		if m.cluster.IsLocal(member.Host) {
+			names = append(names, m.etcdName(member))
+		}
+	}
+	return names
+}
+
+func (m *Manager) etcdDataDir(member config.EtcdMember) string {
+	if len(m.cluster.Etcd.Members) <= 1 {
+		return m.cluster.Paths.EtcdDataDir
+	}
+	return filepath.Join(m.cluster.Paths.EtcdDataDir, member.Name)
+}
+
+// etcdLoopbackURL — клиентский адрес члена на loopback, если он слушает
+// не только там.
+func etcdLoopbackURL(member config.EtcdMember) string {
+	if ip := net.ParseIP(member.Host); ip != nil && ip.IsLoopback() {
+		return ""
+	}
+	member.Host = "127.0.0.1"
+	return member.ClientURL()
+}
+
+func (m *Manager) etcdComponent(member config.EtcdMember) component {
+	etcdPKI := m.cluster.EtcdPKIDir()
+
+	// Слушаем только loopback и адрес члена, а не 0.0.0.0.
+	clientURLs := member.ClientURL()
+	if loopback := etcdLoopbackURL(member); loopback != "" {
+		clientURLs = loopback + "," + clientURLs
+	}
+
+	description := "etcd key-value store"
+	if len(m.cluster.Etcd.Members) > 1 {
+		description += fmt.Sprintf(" (member %s)", member.Name)
+	}
+
+	return component{
+		name:        m.etcdName(member),
+		description: description,
+		path:        m.binPath("etcd"),
+		args: []string{
+			fmt.Sprintf("--name=%s", member.Name),
+			fmt.Sprintf("--advertise-client-urls=%s", member.ClientURL()),
+			fmt.Sprintf("--listen-client-urls=%s", clientURLs),
+			fmt.Sprintf("--data-dir=%s", m.etcdDataDir(member)),
+			fmt.Sprintf("--listen-peer-urls=%s", member.PeerURL()),
+			fmt.Sprintf("--initial-cluster=%s", m.initialCluster()),
+			fmt.Sprintf("--initial-advertise-peer-urls=%s", member.PeerURL()),
+			"--initial-cluster-state=new",
+			fmt.Sprintf("--initial-cluster-token=%s", m.cluster.Etcd.ClusterToken),
+
+			// mTLS для клиентов (API server) и для пиров.
+			"--client-cert-auth=true",
+			fmt.Sprintf("--trusted-ca-file=%s/ca.crt", etcdPKI),
+			fmt.Sprintf("--cert-file=%s/server.crt", etcdPKI),
+			fmt.Sprintf("--key-file=%s/server.key", etcdPKI),
+			"--peer-client-cert-auth=true",
+			fmt.Sprintf("--peer-trusted-ca-file=%s/ca.crt", etcdPKI),
+			fmt.Sprintf("--peer-cert-file=%s/peer.crt", etcdPKI),
+			fmt.Sprintf("--peer-key-file=%s/peer.key", etcdPKI),
+		},
+		image: m.cluster.Versions.EtcdImage,
+		mounts: []mount{
+			{path: m.etcdDataDir(member), writable: true},
+			{path: etcdPKI},
+		},
+	}
+}
+
+// initialCluster — значение --initial-cluster со всеми членами.
+func (m *Manager) initialCluster() string {
+	var peers []string
+	for _, peer := range m.cluster.EtcdMembers() {
+		peers = append(peers, peer.Name+"="+peer.PeerURL())
+	}
+	return strings.Join(peers, ",")
+}
+
+// RemoteEtcdDir — куда пишутся флаги для удаленных членов etcd.
+func (m *Manager) RemoteEtcdDir() string {
+	return filepath.Join(m.baseDir, "etcd-members")
+}
+
+// writeRemoteEtcdFlags записывает флаги удаленного члена по одному в
+// строке, чтобы на его хосте можно было выполнить etcd $(cat <file>).
+func (m *Manager) writeRemoteEtcdFlags(member config.EtcdMember) (string, error) {
+	if err := os.MkdirAll(m.RemoteEtcdDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", m.RemoteEtcdDir(), err)
+	}
+	path := filepath.Join(m.RemoteEtcdDir(), member.Name+".flags")
+	flags := strings.Join(m.etcdComponent(member).args, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(flags), 0644); err != nil {
+		return "", fmt.Errorf("failed to write flags for etcd member %s: %w", member.Name, err)
+	}
+	return path, nil
+}
+
+// EtcdServers возвращает клиентские адреса всех членов для --etcd-servers.
+func (m *Manager) EtcdServers() []string {
+	var servers []string
+	for _, member := range m.cluster.EtcdMembers() {
+		servers = append(servers, member.ClientURL())
+	}
+	return servers
+}
+
+// etcdClient возвращает HTTP-клиент с сертификатом healthcheck-client для
+// проверки /health по mTLS.
+func (m *Manager) etcdClient(timeout time.Duration) (*http.Client, error) {
+	etcdPKI := m.cluster.EtcdPKIDir()
+
+	caPEM, err := os.ReadFile(filepath.Join(etcdPKI, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read etcd CA: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in etcd CA")
+	}
+	cert, err := tls.LoadX509KeyPair(
+		filepath.Join(etcdPKI, "healthcheck-client.crt"),
+		filepath.Join(etcdPKI, "healthcheck-client.key"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load etcd health check certificate: %w", err)
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			},
+		},
+	}, nil
+}
+
 func (m *Manager) waitForEtcd() error {
-	client := &http.Client{
-		Timeout: 2 * time.Second,
+	client, err := m.etcdClient(2 * time.Second)
+	if err != nil {
+		return err
 	}
 
+	pending := m.cluster.EtcdMembers()
 	maxRetries := 30
+	if m.staticPods() || len(pending) > 1 {
+		// kubelet сначала скачивает образ, а кворум из нескольких членов
+		// (в том числе удаленных, запускаемых вручную) собирается дольше.
+		maxRetries = 300
+	}
 	for i := 0; i < maxRetries; i++ {
-		resp, err := client.Get(fmt.Sprintf("http://%s:2379/health", m.hostIP))
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode == 200 {
-				log.Println("  ✓ Etcd is ready")
-				return nil
+		var unhealthy []config.EtcdMember
+		for _, member := range pending {
+			if !m.etcdHealthy(client, member) {
+				unhealthy = append(unhealthy, member)
 			}
 		}
-
-		// Also try localhost
-		resp, err = client.Get("http://127.0.0.1:2379/health")
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode == 200 {
-				log.Println("  ✓ Etcd is ready")
-				return nil
-			}
+		if len(unhealthy) == 0 {
+			log.Println("  ✓ Etcd is ready")
+			return nil
 		}
+		pending = unhealthy
 
 		if i%5 == 0 && i > 0 {
-			log.Printf("  Still waiting for etcd... (%d/%d seconds)", i, maxRetries)
+			log.Printf("  Still waiting for etcd %s... (%d/%d seconds)", memberNames(pending), i, maxRetries)
 		}
 		time.Sleep(1 * time.Second)
 	}
 
-	return fmt.Errorf("etcd did not become ready in time. Check: tail -100 /var/log/kubernetes/etcd.log")
-}
\ No newline at end of file
+	var hints []string
+	for _, member := range pending {
+		if m.cluster.IsLocal(member.Host) {
+			hints = append(hints, "tail -100 "+m.logPath(m.etcdName(member)))
+		} else {
+			hints = append(hints, fmt.Sprintf("etcd on %s", member.Host))
+		}
+	}
+	return fmt.Errorf("etcd %s did not become ready in time. Check: %s", memberNames(pending), strings.Join(hints, "; "))
+}
+
+// etcdHealthy проверяет /health члена по его адресу, а локального — еще и
+// по loopback.
+func (m *Manager) etcdHealthy(client *http.Client, member config.EtcdMember) bool {
+	return m.etcdHealthyURL(client, member) != ""
+}
+
+// etcdHealthyURL возвращает клиентский URL, по которому член ответил на
+// /health, или пустую строку.
+func (m *Manager) etcdHealthyURL(client *http.Client, member config.EtcdMember) string {
+	urls := []string{member.ClientURL()}
+	if loopback := etcdLoopbackURL(member); loopback != "" && m.cluster.IsLocal(member.Host) {
+		urls = append(urls, loopback)
+	}
+	for _, url := range urls {
+		resp, err := client.Get(url + "/health")
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == 200 {
+			return url
+		}
+	}
+	return ""
+}
+
+func memberNames(members []config.EtcdMember) string {
+	var names []string
+	for _, member := range members {
+		names = append(names, member.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func newEtcdClusterManager(t *testing.T) *Manager {
+	cluster := config.Default()
+	cluster.Paths.BaseDir = t.TempDir()
+	cluster.Network.HostIP = "10.1.0.1"
+	cluster.Etcd.Members = []config.EtcdMember{
+		{Name: "a", Host: "10.1.0.1"},
+		{Name: "b", Host: "10.1.0.2"},
+		{Name: "c", Host: "10.1.0.3"},
+	}
+	cluster.Complete()
+	return NewManagerFromConfig(cluster, false)
+}
+
+func TestEtcdMemberFlags(t *testing.T) {
+	mgr := newEtcdClusterManager(t)
+	c := mgr.etcdComponent(mgr.cluster.Etcd.Members[0])
+
+	if c.name != "etcd-a" {
+		t.Errorf("Expected component etcd-a, got %s", c.name)
+	}
+	args := strings.Join(c.args, " ")
+	for _, flag := range []string{
+		"--name=a",
+		"--listen-client-urls=https://127.0.0.1:2379,https://10.1.0.1:2379",
+		"--advertise-client-urls=https://10.1.0.1:2379",
+		"--initial-advertise-peer-urls=https://10.1.0.1:2380",
+		"--initial-cluster=a=https://10.1.0.1:2380,b=https://10.1.0.2:2380,c=https://10.1.0.3:2380",
+		"--initial-cluster-token=" + mgr.cluster.Etcd.ClusterToken,
+		"--data-dir=" + mgr.cluster.Paths.EtcdDataDir + "/a",
+	} {
+		if !strings.Contains(args, flag) {
+			t.Errorf("Expected %s in etcd flags, got %s", flag, args)
+		}
+	}
+
+	apiserver := mgr.apiServerComponent(mgr.EtcdServers())
+	if !strings.Contains(strings.Join(apiserver.args, " "), "--etcd-servers=https://10.1.0.1:2379,https://10.1.0.2:2379,https://10.1.0.3:2379") {
+		t.Errorf("Expected every member in --etcd-servers, got %v", apiserver.args)
+	}
+	if len(apiserver.after) != 1 || apiserver.after[0] != "etcd-a" {
+		t.Errorf("Expected API server to wait for the local member only, got %v", apiserver.after)
+	}
+	if podName(c) != "etcd-a" {
+		t.Errorf("Expected static pod etcd-a, got %s", podName(c))
+	}
+}
+
+func TestWriteRemoteEtcdFlags(t *testing.T) {
+	mgr := newEtcdClusterManager(t)
+	member := mgr.cluster.Etcd.Members[1]
+
+	path, err := mgr.writeRemoteEtcdFlags(member)
+	if err != nil {
+		t.Fatalf("writeRemoteEtcdFlags failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if want := mgr.etcdComponent(member).args; strings.Join(lines, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected one flag per line %v, got %v", want, lines)
+	}
+	if lines[0] != "--name=b" {
+		t.Errorf("Expected flags for member b, got %s", lines[0])
+	}
+}
+
+func TestStopRankTreatsMembersAsEtcd(t *testing.T) {
+	names := []string{"etcd-b", "apiserver", "kubelet"}
+	sortStopOrder(names)
+	if names[0] != "kubelet" || names[2] != "etcd-b" {
+		t.Errorf("Expected etcd members to stop last, got %v", names)
+	}
+}
+
+func TestSortComponents(t *testing.T) {
+	names := []string{"apiserver", "containerd", "controller-manager", "etcd", "kubelet", "scheduler"}
+	SortComponents(names, false)
+	if got := strings.Join(names, ","); got != "kubelet,scheduler,controller-manager,apiserver,containerd,etcd" {
+		t.Errorf("Unexpected stop order %s", got)
+	}
+	SortComponents(names, true)
+	if got := strings.Join(names, ","); got != "etcd,containerd,apiserver,controller-manager,scheduler,kubelet" {
+		t.Errorf("Unexpected start order %s", got)
+	}
+}
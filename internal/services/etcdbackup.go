@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+// snapshotChunk — одно сообщение потока /v3/maintenance/snapshot
+// grpc-gateway etcd.
+type snapshotChunk struct {
+	Result *struct {
+		Blob []byte `json:"blob"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// snapshotTimeFormat — метка времени в именах плановых снимков; такие
+// имена сортируются в хронологическом порядке.
+const snapshotTimeFormat = "20060102-150405"
+
+// SnapshotEtcd сохраняет согласованный снимок etcd в path через клиентский
+// API и проверяет его хеш. Возвращает sha256 данных снимка.
+func (m *Manager) SnapshotEtcd(path string) (string, error) {
+	client, err := m.etcdClient(10 * time.Minute)
+	if err != nil {
+		return "", err
+	}
+	endpoint, err := m.healthyEtcdEndpoint(client)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup dir: %w", err)
+	}
+	// Недописанный снимок не должен выглядеть как готовый.
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".part")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	err = streamSnapshot(client, endpoint, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to save snapshot from %s: %w", endpoint, err)
+	}
+
+	sum, err := VerifySnapshot(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// streamSnapshot копирует поток снимка в w. etcd отдает базу целиком, а
+// последним сообщением — sha256 переданных данных.
+func streamSnapshot(client *http.Client, endpoint string, w io.Writer) error {
+	resp, err := client.Post(endpoint+"/v3/maintenance/snapshot", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk snapshotChunk
+		if err := dec.Decode(&chunk); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read snapshot stream: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("etcd: %s", chunk.Error.Message)
+		}
+		if chunk.Result == nil {
+			continue
+		}
+		if _, err := w.Write(chunk.Result.Blob); err != nil {
+			return err
+		}
+	}
+}
+
+// VerifySnapshot проверяет sha256, которым etcd завершает снимок (база
+// кратна 512 байтам, за ней 32 байта хеша). Возвращает хеш в hex.
+func VerifySnapshot(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	size := info.Size() - sha256.Size
+	if size <= 0 || size%512 != 0 {
+		return "", fmt.Errorf("%s is not an etcd snapshot with an integrity hash (%d bytes)", path, info.Size())
+	}
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, size); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	want := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(f, want); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !bytes.Equal(h.Sum(nil), want) {
+		return "", fmt.Errorf("%s is corrupted: sha256 does not match", path)
+	}
+	return hex.EncodeToString(want), nil
+}
+
+// BackupEtcd пишет снимок etcd-<время>.db в dir и оставляет только keep
+// последних снимков (keep <= 0 — все). Возвращает путь нового снимка.
+func (m *Manager) BackupEtcd(dir string, keep int) (string, error) {
+	path := filepath.Join(dir, "etcd-"+time.Now().Format(snapshotTimeFormat)+".db")
+	sum, err := m.SnapshotEtcd(path)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("  ✓ Snapshot saved to %s (sha256 %s)", path, sum)
+
+	removed, err := pruneSnapshots(dir, keep)
+	for _, p := range removed {
+		log.Printf("  Removed old snapshot %s", p)
+	}
+	return path, err
+}
+
+// pruneSnapshots удаляет самые старые плановые снимки сверх keep.
+func pruneSnapshots(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	snapshots, err := filepath.Glob(filepath.Join(dir, "etcd-*.db"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(snapshots)
+
+	var removed []string
+	for len(snapshots) > keep {
+		if err := os.Remove(snapshots[0]); err != nil {
+			return removed, err
+		}
+		removed = append(removed, snapshots[0])
+		snapshots = snapshots[1:]
+	}
+	return removed, nil
+}
+
+// RestoreEtcd восстанавливает локальные члены etcd (или только member)
+// из снимка: останавливает их, разворачивает снимок etcdutl в новый каталог
+// данных, откладывает старый в <dataDir>.bak-<время> и запускает члены.
+func (m *Manager) RestoreEtcd(snapshot, member string) error {
+	if _, err := VerifySnapshot(snapshot); err != nil {
+		return err
+	}
+	etcdutl, err := m.etcdutlPath()
+	if err != nil {
+		return err
+	}
+
+	var local []config.EtcdMember
+	for _, mem := range m.cluster.EtcdMembers() {
+		if member != "" && mem.Name != member {
+			continue
+		}
+		if !m.cluster.IsLocal(mem.Host) {
+			// Все члены восстанавливаются из одного снимка, удаленные — вручную.
+			log.Printf("  ⚠ etcd member %s (%s) is remote, restore it there with: etcdutl %s",
+				mem.Name, mem.Host, strings.Join(m.etcdutlRestoreArgs(snapshot, mem, m.etcdDataDir(mem)), " "))
+			continue
+		}
+		local = append(local, mem)
+	}
+	if len(local) == 0 {
+		if member != "" {
+			return fmt.Errorf("etcd member %q is not declared or does not run on this host", member)
+		}
+		return fmt.Errorf("no etcd members run on this host")
+	}
+
+	// Останавливаем все члены до подмены данных, иначе оставшиеся
+	// реплицируют старое состояние обратно.
+	for _, mem := range local {
+		log.Printf("  Stopping %s...", m.etcdName(mem))
+		if err := m.stopEtcdMember(mem); err != nil {
+			return err
+		}
+	}
+	for _, mem := range local {
+		if err := m.restoreEtcdMember(etcdutl, snapshot, mem); err != nil {
+			return err
+		}
+	}
+	for _, mem := range local {
+		log.Printf("  Starting %s...", m.etcdName(mem))
+		if err := m.launch(m.etcdComponent(mem)); err != nil {
+			return err
+		}
+	}
+
+	log.Println("  Waiting for etcd to become ready...")
+	return m.waitForEtcd()
+}
+
+func (m *Manager) restoreEtcdMember(etcdutl, snapshot string, member config.EtcdMember) error {
+	dataDir := m.etcdDataDir(member)
+	restored := dataDir + ".restore"
+	if err := os.RemoveAll(restored); err != nil {
+		return err
+	}
+
+	log.Printf("  Restoring %s into %s...", snapshot, dataDir)
+	out, err := exec.Command(etcdutl, m.etcdutlRestoreArgs(snapshot, member, restored)...).CombinedOutput()
+	if err != nil {
+		os.RemoveAll(restored)
+		return fmt.Errorf("etcdutl snapshot restore failed: %w, output: %s", err, out)
+	}
+
+	if _, err := os.Stat(dataDir); err == nil {
+		backup := dataDir + ".bak-" + time.Now().Format(snapshotTimeFormat)
+		if err := os.Rename(dataDir, backup); err != nil {
+			return fmt.Errorf("failed to move old data dir aside: %w", err)
+		}
+		log.Printf("  Previous data of %s kept in %s", member.Name, backup)
+	}
+	return os.Rename(restored, dataDir)
+}
+
+// etcdutlRestoreArgs — аргументы etcdutl snapshot restore для члена; новый
+// кластер получает то же членство, что и при установке.
+func (m *Manager) etcdutlRestoreArgs(snapshot string, member config.EtcdMember, dataDir string) []string {
+	return []string{
+		"snapshot", "restore", snapshot,
+		"--name=" + member.Name,
+		"--initial-cluster=" + m.initialCluster(),
+		"--initial-cluster-token=" + m.cluster.Etcd.ClusterToken,
+		"--initial-advertise-peer-urls=" + member.PeerURL(),
+		"--data-dir=" + dataDir,
+	}
+}
+
+func (m *Manager) etcdutlPath() (string, error) {
+	if path := m.binPath("etcdutl"); fileExists(path) {
+		return path, nil
+	}
+	path, err := exec.LookPath("etcdutl")
+	if err != nil {
+		return "", fmt.Errorf("etcdutl not found in %s or PATH; rerun the installer with --only-step=download", filepath.Dir(m.binPath("etcdutl")))
+	}
+	return path, nil
+}
+
+// stopEtcdMember останавливает члена. Static pod останавливает kubelet
+// после удаления манифеста, поэтому ждем, пока etcd перестанет отвечать.
+func (m *Manager) stopEtcdMember(member config.EtcdMember) error {
+	c := m.etcdComponent(member)
+	if !m.staticPods() {
+		return m.Stop(c.name)
+	}
+
+	if err := os.Remove(m.staticPodPath(c)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	client, err := m.etcdClient(time.Second)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 120; i++ {
+		if !m.etcdHealthy(client, member) {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("%s is still running after its manifest was removed", c.name)
+}
+
+// healthyEtcdEndpoint возвращает клиентский URL первого здорового члена,
+// начиная с локальных.
+func (m *Manager) healthyEtcdEndpoint(client *http.Client) (string, error) {
+	members := append([]config.EtcdMember(nil), m.cluster.EtcdMembers()...)
+	sort.SliceStable(members, func(a, b int) bool {
+		return m.cluster.IsLocal(members[a].Host) && !m.cluster.IsLocal(members[b].Host)
+	})
+	for _, member := range members {
+		if url := m.etcdHealthyURL(client, member); url != "" {
+			return url, nil
+		}
+	}
+	return "", fmt.Errorf("no healthy etcd member (%s)", memberNames(members))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
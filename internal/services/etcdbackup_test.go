@@ -0,0 +1,125 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSnapshot returns a database of two pages followed by its sha256, the
+// way etcd streams it.
+func fakeSnapshot() []byte {
+	db := bytes.Repeat([]byte("etcd"), 256)
+	sum := sha256.Sum256(db)
+	return append(db, sum[:]...)
+}
+
+func TestStreamSnapshot(t *testing.T) {
+	snapshot := fakeSnapshot()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/maintenance/snapshot" {
+			http.NotFound(w, r)
+			return
+		}
+		enc := json.NewEncoder(w)
+		for _, blob := range [][]byte{snapshot[:700], snapshot[700:1024], snapshot[1024:]} {
+			enc.Encode(map[string]any{"result": map[string]any{"blob": blob}})
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := streamSnapshot(srv.Client(), srv.URL, f); err != nil {
+		t.Fatalf("streamSnapshot failed: %v", err)
+	}
+	f.Close()
+
+	sum, err := VerifySnapshot(path)
+	if err != nil {
+		t.Fatalf("VerifySnapshot failed: %v", err)
+	}
+	if want := hex.EncodeToString(snapshot[1024:]); sum != want {
+		t.Errorf("Expected hash %s, got %s", want, sum)
+	}
+}
+
+func TestStreamSnapshotError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":{"grpc_code":14,"message":"etcdserver: no leader"}}`))
+	}))
+	defer srv.Close()
+
+	err := streamSnapshot(srv.Client(), srv.URL, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "no leader") {
+		t.Errorf("Expected the etcd error, got %v", err)
+	}
+}
+
+func TestVerifySnapshotRejectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+
+	corrupted := fakeSnapshot()
+	corrupted[10] ^= 0xff
+	truncated := fakeSnapshot()[:1000]
+
+	for name, data := range map[string][]byte{"corrupted": corrupted, "truncated": truncated} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VerifySnapshot(path); err == nil {
+			t.Errorf("Expected %s snapshot to be rejected", name)
+		}
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"etcd-20240101-000000.db",
+		"etcd-20240102-000000.db",
+		"etcd-20240103-000000.db",
+		"manual.db",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := pruneSnapshots(dir, 2)
+	if err != nil {
+		t.Fatalf("pruneSnapshots failed: %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != names[0] {
+		t.Errorf("Expected only the oldest snapshot to be removed, got %v", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manual.db")); err != nil {
+		t.Errorf("Snapshots not made by the schedule must be kept: %v", err)
+	}
+}
+
+func TestEtcdutlRestoreArgs(t *testing.T) {
+	mgr := newEtcdClusterManager(t)
+	member := mgr.cluster.Etcd.Members[1]
+
+	args := strings.Join(mgr.etcdutlRestoreArgs("/backup/etcd.db", member, "/data/b"), " ")
+	want := "snapshot restore /backup/etcd.db --name=b" +
+		" --initial-cluster=a=https://10.1.0.1:2380,b=https://10.1.0.2:2380,c=https://10.1.0.3:2380" +
+		" --initial-cluster-token=" + mgr.cluster.Etcd.ClusterToken +
+		" --initial-advertise-peer-urls=https://10.1.0.2:2380 --data-dir=/data/b"
+	if args != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, args)
+	}
+}
@@ -5,12 +5,20 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 )
 
 func (m *Manager) StartKubelet() error {
+	if err := m.LaunchKubelet(); err != nil {
+		return err
+	}
+	return m.WaitForNode()
+}
+
+// LaunchKubelet запускает kubelet, не дожидаясь регистрации ноды: в режиме
+// static pod API server поднимает сам kubelet, так что ждать его здесь нельзя.
+func (m *Manager) LaunchKubelet() error {
 	// Сначала убедимся что containerd готов
 	if err := m.verifyContainerdCRI(); err != nil {
 		return fmt.Errorf("containerd not ready: %w", err)
@@ -21,39 +29,58 @@ func (m *Manager) StartKubelet() error {
 		return fmt.Errorf("failed to get hostname: %w", err)
 	}
 
-	cmd := exec.Command(
-		filepath.Join(m.baseDir, "bin", "kubelet"),
-		fmt.Sprintf("--kubeconfig=%s/kubeconfig", m.kubeletDir),
-		fmt.Sprintf("--config=%s/config.yaml", m.kubeletDir),
-		fmt.Sprintf("--root-dir=%s", m.kubeletDir),
-		fmt.Sprintf("--cert-dir=%s/pki", m.kubeletDir),
-		fmt.Sprintf("--hostname-override=%s", hostname),
-		"--pod-infra-container-image=registry.k8s.io/pause:3.10",
-		fmt.Sprintf("--node-ip=%s", m.hostIP),
-		"--cloud-provider=external",
-		"--cgroup-driver=cgroupfs",
-		"--max-pods=10",
-		"--runtime-request-timeout=5m",
-		"--v=2",
-	)
-	cmd.Env = append(os.Environ(), "PATH="+os.Getenv("PATH")+":/opt/cni/bin:/usr/sbin")
+	return m.launch(m.kubeletComponent(hostname))
+}
 
-	if err := m.startDaemon(cmd, "/var/log/kubernetes/kubelet.log"); err != nil {
-		return err
+// WaitForNode ждет регистрации ноды, снимает taints и ждет статуса Ready.
+func (m *Manager) WaitForNode() error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
 	}
 
 	log.Println("  Waiting for node registration and removing taints...")
 	return m.waitForNodeReady(hostname)
 }
 
+func (m *Manager) kubeletComponent(hostname string) component {
+	after := []string{"containerd", "apiserver"}
+	if m.staticPods() {
+		// API server — под этого же kubelet.
+		after = after[:1]
+	}
+
+	return component{
+		name:        "kubelet",
+		description: "Kubernetes node agent",
+		path:        m.binPath("kubelet"),
+		args: []string{
+			fmt.Sprintf("--kubeconfig=%s/kubeconfig", m.kubeletDir),
+			fmt.Sprintf("--config=%s/config.yaml", m.kubeletDir),
+			fmt.Sprintf("--root-dir=%s", m.kubeletDir),
+			fmt.Sprintf("--cert-dir=%s/pki", m.kubeletDir),
+			fmt.Sprintf("--hostname-override=%s", hostname),
+			fmt.Sprintf("--pod-infra-container-image=%s", m.cluster.Versions.PauseImage),
+			fmt.Sprintf("--node-ip=%s", m.hostIP),
+			"--cloud-provider=external",
+			fmt.Sprintf("--cgroup-driver=%s", m.cluster.Kubelet.CgroupDriver),
+			fmt.Sprintf("--max-pods=%d", m.cluster.Kubelet.MaxPods),
+			"--runtime-request-timeout=5m",
+			"--v=2",
+		},
+		env:   []string{pathEnv(m.cluster.Paths.CNIBinDir, "/usr/sbin")},
+		after: after,
+	}
+}
+
 func (m *Manager) verifyContainerdCRI() error {
 	log.Println("  Verifying containerd CRI readiness...")
 	maxRetries := 30
 	
 	for i := 0; i < maxRetries; i++ {
 		// Проверяем через crictl
-		cmd := exec.Command("crictl", 
-			"--runtime-endpoint", "unix:///run/containerd/containerd.sock", 
+		cmd := exec.Command("crictl",
+			"--runtime-endpoint", "unix://"+m.cluster.Paths.ContainerdSocket,
 			"version")
 		if err := cmd.Run(); err == nil {
 			log.Println("  Containerd CRI is ready")
@@ -70,7 +97,7 @@ func (m *Manager) verifyContainerdCRI() error {
 }
 
 func (m *Manager) waitForNodeReady(hostname string) error {
-	kubectlPath := filepath.Join(m.baseDir, "bin", "kubectl")
+	kubectlPath := m.binPath("kubectl")
 	maxRetries := 60
 	
 	// Шаг 1: Ждем регистрации ноды
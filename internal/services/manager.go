@@ -1,37 +1,118 @@
 package services
 import (
-	"fmt"
-	"os"
-	"os/exec"
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+	"github.com/dereban25/k8s-installer/internal/supervisor"
 )
+
+// stopTimeout — сколько ждать после SIGTERM перед SIGKILL
+const stopTimeout = 10 * time.Second
+
 // Manager управляет системными сервисами (etcd, api-server, kubelet, containerd и т.д.)
 type Manager struct {
+	cluster     *config.ClusterConfig
 	baseDir     string
 	kubeletDir  string
 	hostIP      string
 	skipAPIWait bool
+	supervisor  *supervisor.Supervisor
 }
 
-// NewManager: (string, string, string, bool) — последний флаг = skipAPIWait (fast mode)
+// NewManager: (string, string, string, bool) — последний флаг = skipAPIWait (fast mode).
+// Все остальные параметры берутся из config.Default().
 func NewManager(baseDir, kubeletDir, hostIP string, skipAPIWait bool) *Manager {
+	cluster := config.Default()
+	cluster.Paths.BaseDir = baseDir
+	cluster.Paths.KubeletDir = kubeletDir
+	cluster.Network.HostIP = hostIP
+	cluster.Complete()
+	return NewManagerFromConfig(cluster, skipAPIWait)
+}
+
+// NewManagerFromConfig создает менеджер из полностью разрешенного cluster spec.
+func NewManagerFromConfig(cluster *config.ClusterConfig, skipAPIWait bool) *Manager {
 	return &Manager{
-		baseDir:     baseDir,
-		kubeletDir:  kubeletDir,
-		hostIP:      hostIP,
+		cluster:     cluster,
+		baseDir:     cluster.Paths.BaseDir,
+		kubeletDir:  cluster.Paths.KubeletDir,
+		hostIP:      cluster.Network.HostIP,
 		skipAPIWait: skipAPIWait,
+		supervisor:  supervisor.New(filepath.Join(cluster.Paths.BaseDir, "run")),
 	}
 }
-// startDaemon запускает процесс и пишет stdout/stderr в лог-файл
-func (m *Manager) startDaemon(cmd *exec.Cmd, logPath string) error {
-	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+// logPath возвращает путь к лог-файлу компонента в paths.logDir
+func (m *Manager) logPath(name string) string {
+	return filepath.Join(m.cluster.Paths.LogDir, name+".log")
+}
+
+// binPath возвращает путь к бинарнику в baseDir/bin
+func (m *Manager) binPath(name string) string {
+	return filepath.Join(m.baseDir, "bin", name)
+}
+
+// Status возвращает состояние всех запущенных компонентов — и под
+// супервизором, и установленных как systemd unit.
+func (m *Manager) Status() ([]supervisor.Status, error) {
+	statuses, err := m.supervisor.Status()
 	if err != nil {
-		return fmt.Errorf("не удалось открыть лог %s: %w", logPath, err)
+		return nil, err
 	}
-	cmd.Stdout = f
-	cmd.Stderr = f
+	units, err := m.installedUnits()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range units {
+		st, err := m.unitStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(a, b int) bool { return statuses[a].Name < statuses[b].Name })
+	return statuses, nil
+}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("не удалось запустить %s: %w", cmd.Path, err)
+// Stop останавливает компонент; он не будет перезапущен до Restart.
+func (m *Manager) Stop(name string) error {
+	if m.hasUnit(name) {
+		return systemctl("stop", unitName(name))
 	}
-	return nil
-}
\ No newline at end of file
+	return m.supervisor.Stop(name, stopTimeout)
+}
+
+// Restart перезапускает компонент с теми же флагами, что и при установке.
+func (m *Manager) Restart(name string) error {
+	if m.hasUnit(name) {
+		return systemctl("restart", unitName(name))
+	}
+	if path := m.manifestPath(name); m.staticPods() && fileExists(path) {
+		return m.restartStaticPod(path)
+	}
+	return m.supervisor.Restart(name, stopTimeout)
+}
+
+// Components возвращает имена всех компонентов, запущенных менеджером.
+func (m *Manager) Components() ([]string, error) {
+	names, err := m.supervisor.Names()
+	if err != nil {
+		return nil, err
+	}
+	units, err := m.installedUnits()
+	if err != nil {
+		return nil, err
+	}
+	names = append(names, units...)
+	sort.Strings(names)
+	return names, nil
+}
+
+// Supervise следит за компонентами до отмены ctx, перезапуская упавшие.
+// Компоненты под systemd перезапускает сам systemd.
+func (m *Manager) Supervise(ctx context.Context) error {
+	return m.supervisor.Watch(ctx, 2*time.Second)
+}
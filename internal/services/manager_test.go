@@ -1,7 +1,13 @@
 package services
 
 import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/config"
 )
 
 func TestNewManager(t *testing.T) {
@@ -48,4 +54,123 @@ func TestNewManagerWithSkipAPIWait(t *testing.T) {
 	if !mgr.skipAPIWait {
 		t.Errorf("Expected skipAPIWait to be true, got false")
 	}
-}
\ No newline at end of file
+}
+func TestAPIServerSecurityProfile(t *testing.T) {
+	mgr := NewManager("./kubebuilder", "/var/lib/kubelet", "192.168.1.1", false)
+	args := strings.Join(mgr.apiServerComponent([]string{"https://127.0.0.1:2379"}).args, " ")
+	if !strings.Contains(args, "--authorization-mode=AlwaysAllow") {
+		t.Errorf("Expected AlwaysAllow in the default profile, got %s", args)
+	}
+
+	mgr.cluster.Security.Profile = config.SecurityHardened
+	args = strings.Join(mgr.apiServerComponent([]string{"https://127.0.0.1:2379"}).args, " ")
+	for _, flag := range []string{
+		"--authorization-mode=Node,RBAC",
+		"--anonymous-auth=false",
+		"--enable-admission-plugins=NodeRestriction",
+		"--kubelet-client-certificate=",
+	} {
+		if !strings.Contains(args, flag) {
+			t.Errorf("Expected %s in the hardened profile, got %s", flag, args)
+		}
+	}
+	if strings.Contains(args, "AlwaysAllow") || strings.Contains(args, "--anonymous-auth=true") {
+		t.Errorf("Hardened profile must not allow everything, got %s", args)
+	}
+
+	scheduler := strings.Join(mgr.schedulerComponent().args, " ")
+	if !strings.Contains(scheduler, "--kubeconfig="+mgr.cluster.ComponentKubeconfig("scheduler")) {
+		t.Errorf("Expected scheduler to use its own kubeconfig, got %s", scheduler)
+	}
+}
+
+func TestEnsureTokenFileHardened(t *testing.T) {
+	mgr := NewManager(t.TempDir(), "/var/lib/kubelet", "192.168.1.1", false)
+	if err := os.MkdirAll(mgr.cluster.Paths.PKIDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.ensureTokenFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Switching to the hardened profile replaces the well-known token.
+	mgr.cluster.Security.Profile = config.SecurityHardened
+	if err := mgr.ensureTokenFile(); err != nil {
+		t.Fatalf("ensureTokenFile failed: %v", err)
+	}
+	tokenFile := filepath.Join(mgr.cluster.Paths.PKIDir, "token.csv")
+	token := readBootstrapToken(tokenFile)
+	if !regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`).MatchString(token) {
+		t.Errorf("Unexpected bootstrap token %q", token)
+	}
+	if token == defaultBootstrapToken {
+		t.Error("Hardened profile must not use the well-known token")
+	}
+
+	// An existing token is kept so that restarts do not invalidate it.
+	if err := mgr.ensureTokenFile(); err != nil {
+		t.Fatal(err)
+	}
+	if again := readBootstrapToken(tokenFile); again != token {
+		t.Errorf("Expected token to be kept, got %q then %q", token, again)
+	}
+}
+
+func TestEtcdUsesMutualTLS(t *testing.T) {
+	mgr := NewManager("./kubebuilder", "/var/lib/kubelet", "192.168.1.1", false)
+
+	etcd := strings.Join(mgr.etcdComponent(mgr.cluster.EtcdMembers()[0]).args, " ")
+	for _, flag := range []string{
+		"--listen-client-urls=https://127.0.0.1:2379,https://192.168.1.1:2379",
+		"--listen-peer-urls=https://192.168.1.1:2380",
+		"--client-cert-auth=true",
+		"--peer-client-cert-auth=true",
+		"--trusted-ca-file=" + mgr.cluster.EtcdPKIDir() + "/ca.crt",
+	} {
+		if !strings.Contains(etcd, flag) {
+			t.Errorf("Expected %s in etcd flags, got %s", flag, etcd)
+		}
+	}
+	if strings.Contains(etcd, "http://") || strings.Contains(etcd, "0.0.0.0") {
+		t.Errorf("etcd must not listen on plain HTTP or all interfaces, got %s", etcd)
+	}
+
+	apiserver := strings.Join(mgr.apiServerComponent([]string{"https://127.0.0.1:2379"}).args, " ")
+	for _, flag := range []string{
+		"--etcd-servers=https://127.0.0.1:2379",
+		"--etcd-cafile=",
+		"--etcd-certfile=",
+		"--etcd-keyfile=",
+	} {
+		if !strings.Contains(apiserver, flag) {
+			t.Errorf("Expected %s in API server flags, got %s", flag, apiserver)
+		}
+	}
+}
+
+func TestComponentCredentialsInDefaultProfile(t *testing.T) {
+	mgr := NewManager("./kubebuilder", "/var/lib/kubelet", "192.168.1.1", false)
+	pkiDir := mgr.cluster.Paths.PKIDir
+
+	args := strings.Join(mgr.apiServerComponent([]string{"https://127.0.0.1:2379"}).args, " ")
+	for _, flag := range []string{
+		"--kubelet-client-certificate=" + filepath.Join(pkiDir, "apiserver-kubelet-client.crt"),
+		"--requestheader-client-ca-file=" + filepath.Join(pkiDir, "front-proxy-ca.crt"),
+		"--requestheader-allowed-names=front-proxy-client",
+		"--proxy-client-cert-file=" + filepath.Join(pkiDir, "front-proxy-client.crt"),
+	} {
+		if !strings.Contains(args, flag) {
+			t.Errorf("Expected %s, got %s", flag, args)
+		}
+	}
+
+	for name, c := range map[string]component{
+		"controller-manager": mgr.controllerManagerComponent(),
+		"scheduler":          mgr.schedulerComponent(),
+	} {
+		args := strings.Join(c.args, " ")
+		if !strings.Contains(args, "--kubeconfig="+mgr.cluster.ComponentKubeconfig(name)) {
+			t.Errorf("Expected %s to use its own kubeconfig, got %s", name, args)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 )
@@ -12,7 +11,7 @@ import (
 func (m *Manager) CreateSystemNamespaces() error {
 	log.Println("  Creating system namespaces...")
 	
-	kubectlPath := filepath.Join(m.baseDir, "bin", "kubectl")
+	kubectlPath := m.binPath("kubectl")
 	
 	// Wait for API to accept requests
 	maxRetries := 30
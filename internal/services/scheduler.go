@@ -2,24 +2,30 @@ package services
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
 )
 
 func (m *Manager) StartScheduler() error {
-	homeDir := os.Getenv("HOME")
-	if homeDir == "" {
-		homeDir = "/root"
-	}
+	return m.launch(m.schedulerComponent())
+}
 
-	cmd := exec.Command(
-		filepath.Join(m.baseDir, "bin", "kube-scheduler"),
-		fmt.Sprintf("--kubeconfig=%s/.kube/config", homeDir),
-		"--leader-elect=false",
-		"--v=2",
-		"--bind-address=0.0.0.0",
-	)
+func (m *Manager) schedulerComponent() component {
+	// Своя учетка system:kube-scheduler вместо admin.
+	kubeconfig := m.cluster.ComponentKubeconfig("scheduler")
 
-	return m.startDaemon(cmd, "/var/log/kubernetes/scheduler.log")
-}
\ No newline at end of file
+	return component{
+		name:        "scheduler",
+		description: "Kubernetes scheduler",
+		path:        m.binPath("kube-scheduler"),
+		args: []string{
+			fmt.Sprintf("--kubeconfig=%s", kubeconfig),
+			fmt.Sprintf("--authentication-kubeconfig=%s", kubeconfig),
+			fmt.Sprintf("--authorization-kubeconfig=%s", kubeconfig),
+			"--leader-elect=false",
+			"--v=2",
+			"--bind-address=0.0.0.0",
+		},
+		after:  []string{"apiserver"},
+		image:  m.imageFor("kube-scheduler"),
+		mounts: []mount{{path: kubeconfig}},
+	}
+}
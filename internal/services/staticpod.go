@@ -0,0 +1,245 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+// Минимальное подмножество core/v1 Pod, достаточное для static pod.
+type pod struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   podMetadata `yaml:"metadata"`
+	Spec       podSpec     `yaml:"spec"`
+}
+
+type podMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type podSpec struct {
+	HostNetwork       bool           `yaml:"hostNetwork"`
+	PriorityClassName string         `yaml:"priorityClassName"`
+	Containers        []podContainer `yaml:"containers"`
+	Volumes           []podVolume    `yaml:"volumes,omitempty"`
+}
+
+type podContainer struct {
+	Name            string        `yaml:"name"`
+	Image           string        `yaml:"image"`
+	ImagePullPolicy string        `yaml:"imagePullPolicy"`
+	Command         []string      `yaml:"command"`
+	VolumeMounts    []volumeMount `yaml:"volumeMounts,omitempty"`
+}
+
+type volumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type podVolume struct {
+	Name     string       `yaml:"name"`
+	HostPath hostPathSpec `yaml:"hostPath"`
+}
+
+type hostPathSpec struct {
+	Path string `yaml:"path"`
+	Type string `yaml:"type,omitempty"`
+}
+
+func (m *Manager) staticPods() bool {
+	return m.cluster.Runtime.ControlPlane == config.ControlPlaneStaticPod
+}
+
+// imageFor возвращает образ control plane для версии Kubernetes из spec.
+func (m *Manager) imageFor(binary string) string {
+	return fmt.Sprintf("%s/%s:%s", m.cluster.Versions.ImageRepository, binary, m.cluster.Versions.Kubernetes)
+}
+
+// podName — имя пода и манифеста. Члены etcd на одном хосте запускаются
+// одним бинарником, поэтому у них в имени остается имя члена.
+func podName(c component) string {
+	if strings.HasPrefix(c.name, "etcd-") {
+		return c.name
+	}
+	return filepath.Base(c.path)
+}
+
+// Images возвращает образы, нужные компонентам: pause и, для static-pod
+// control plane, образы etcd и control plane.
+func (m *Manager) Images() []string {
+	images := []string{m.cluster.Versions.PauseImage}
+	if m.staticPods() {
+		images = append(images,
+			m.cluster.Versions.EtcdImage,
+			m.imageFor("kube-apiserver"),
+			m.imageFor("kube-controller-manager"),
+			m.imageFor("kube-scheduler"),
+		)
+	}
+	return images
+}
+
+func (m *Manager) staticPodPath(c component) string {
+	return filepath.Join(m.cluster.Paths.ManifestsDir, podName(c)+".yaml")
+}
+
+// manifestPath — манифест компонента по имени в супервизоре (см. podName).
+func (m *Manager) manifestPath(name string) string {
+	if name != "etcd" && !strings.HasPrefix(name, "etcd-") {
+		name = "kube-" + name
+	}
+	return filepath.Join(m.cluster.Paths.ManifestsDir, name+".yaml")
+}
+
+// restartAnnotation меняется при каждом перезапуске static pod: у пода с
+// другим манифестом другой UID, и kubelet пересоздает его.
+const restartAnnotation = "k8s-installer/restarted-at"
+
+// restartStaticPod заставляет kubelet пересоздать под, например чтобы
+// процесс перечитал обновленные сертификаты.
+func (m *Manager) restartStaticPod(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var p pod
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if p.Metadata.Annotations == nil {
+		p.Metadata.Annotations = map[string]string{}
+	}
+	p.Metadata.Annotations[restartAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	if data, err = yaml.Marshal(p); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// writeStaticPod кладет манифест в staticPodPath kubelet; дальше запуском
+// и перезапусками занимается kubelet.
+func (m *Manager) writeStaticPod(c component) error {
+	data, err := m.renderStaticPod(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.cluster.Paths.ManifestsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create manifests dir: %w", err)
+	}
+
+	// kubelet игнорирует файлы, начинающиеся с точки, поэтому
+	// недописанный манифест он не подхватит.
+	path := m.staticPodPath(c)
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// renderStaticPod собирает манифест из тех же флагов, что и для демона на
+// хосте; пути хоста монтируются в контейнер без изменений, поэтому флаги
+// остаются верными.
+func (m *Manager) renderStaticPod(c component) ([]byte, error) {
+	name := podName(c)
+	binary := filepath.Base(c.path)
+
+	container := podContainer{
+		Name:            binary,
+		Image:           c.image,
+		ImagePullPolicy: "IfNotPresent",
+		Command:         append([]string{binary}, c.args...),
+	}
+	var volumes []podVolume
+	for _, mt := range c.mounts {
+		volName := volumeName(mt.path)
+		hostPath := hostPathSpec{Path: mt.path}
+		if mt.writable {
+			hostPath.Type = "DirectoryOrCreate"
+		}
+		volumes = append(volumes, podVolume{Name: volName, HostPath: hostPath})
+		container.VolumeMounts = append(container.VolumeMounts, volumeMount{
+			Name:      volName,
+			MountPath: mt.path,
+			ReadOnly:  !mt.writable,
+		})
+	}
+
+	p := pod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata: podMetadata{
+			Name:      name,
+			Namespace: "kube-system",
+			Labels: map[string]string{
+				"component": name,
+				"tier":      "control-plane",
+			},
+		},
+		Spec: podSpec{
+			HostNetwork:       true,
+			PriorityClassName: "system-node-critical",
+			Containers:        []podContainer{container},
+			Volumes:           volumes,
+		},
+	}
+
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s manifest: %w", name, err)
+	}
+	return data, nil
+}
+
+var nonDNSChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// volumeName строит имя тома (DNS label) из пути хоста.
+func volumeName(path string) string {
+	name := strings.Trim(nonDNSChars.ReplaceAllString(strings.ToLower(path), "-"), "-")
+	if len(name) > 63 {
+		name = strings.Trim(name[len(name)-63:], "-")
+	}
+	return name
+}
+
+// RemoveStaticPods удаляет манифесты control plane, чтобы kubelet остановил
+// поды. Возвращает пути удаленных манифестов.
+func (m *Manager) RemoveStaticPods() ([]string, error) {
+	var removed []string
+	components := []component{
+		m.schedulerComponent(),
+		m.controllerManagerComponent(),
+		m.apiServerComponent(m.EtcdServers()),
+	}
+	for _, member := range m.cluster.EtcdMembers() {
+		components = append(components, m.etcdComponent(member))
+	}
+	for _, c := range components {
+		path := m.staticPodPath(c)
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func newStaticPodManager(t *testing.T) *Manager {
+	cluster := config.Default()
+	cluster.Paths.BaseDir = t.TempDir()
+	cluster.Network.HostIP = "192.168.1.10"
+	cluster.Runtime.ControlPlane = config.ControlPlaneStaticPod
+	cluster.Complete()
+	return NewManagerFromConfig(cluster, false)
+}
+
+func TestRenderStaticPodUsesComponentFlags(t *testing.T) {
+	mgr := newStaticPodManager(t)
+	c := mgr.apiServerComponent([]string{"https://127.0.0.1:2379"})
+
+	data, err := mgr.renderStaticPod(c)
+	if err != nil {
+		t.Fatalf("renderStaticPod failed: %v", err)
+	}
+	var p pod
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		t.Fatalf("Manifest is not valid YAML: %v\n%s", err, data)
+	}
+
+	if p.Kind != "Pod" || p.Metadata.Name != "kube-apiserver" || p.Metadata.Namespace != "kube-system" {
+		t.Errorf("Unexpected metadata: %+v %+v", p.Kind, p.Metadata)
+	}
+	if !p.Spec.HostNetwork {
+		t.Error("Expected hostNetwork to be enabled")
+	}
+	if len(p.Spec.Containers) != 1 {
+		t.Fatalf("Expected one container, got %d", len(p.Spec.Containers))
+	}
+	ctr := p.Spec.Containers[0]
+	if ctr.Image != "registry.k8s.io/kube-apiserver:"+config.DefaultK8sVersion {
+		t.Errorf("Unexpected image %s", ctr.Image)
+	}
+	want := append([]string{"kube-apiserver"}, c.args...)
+	if !reflect.DeepEqual(ctr.Command, want) {
+		t.Errorf("Expected command %v, got %v", want, ctr.Command)
+	}
+
+	mounts := map[string]bool{}
+	for _, vm := range ctr.VolumeMounts {
+		mounts[vm.MountPath] = vm.ReadOnly
+	}
+	if ro, ok := mounts[mgr.cluster.Paths.PKIDir]; !ok || !ro {
+		t.Errorf("Expected PKI dir to be mounted read-only, got %v", ctr.VolumeMounts)
+	}
+	if len(p.Spec.Volumes) != len(ctr.VolumeMounts) {
+		t.Errorf("Expected a volume per mount, got %d volumes and %d mounts", len(p.Spec.Volumes), len(ctr.VolumeMounts))
+	}
+}
+
+func TestLaunchWritesStaticPodForControlPlaneOnly(t *testing.T) {
+	mgr := newStaticPodManager(t)
+
+	if err := mgr.launch(mgr.etcdComponent(mgr.cluster.EtcdMembers()[0])); err != nil {
+		t.Fatalf("launch failed: %v", err)
+	}
+	manifest := filepath.Join(mgr.cluster.Paths.ManifestsDir, "etcd.yaml")
+	if _, err := os.Stat(manifest); err != nil {
+		t.Fatalf("Expected etcd manifest: %v", err)
+	}
+	if names, _ := mgr.supervisor.Names(); len(names) != 0 {
+		t.Errorf("Expected nothing to be supervised, got %v", names)
+	}
+
+	removed, err := mgr.RemoveStaticPods()
+	if err != nil {
+		t.Fatalf("RemoveStaticPods failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != manifest {
+		t.Errorf("Expected %s to be removed, got %v", manifest, removed)
+	}
+}
+
+func TestRestartRecreatesStaticPod(t *testing.T) {
+	mgr := newStaticPodManager(t)
+	if err := mgr.launch(mgr.schedulerComponent()); err != nil {
+		t.Fatalf("launch failed: %v", err)
+	}
+	manifest := filepath.Join(mgr.cluster.Paths.ManifestsDir, "kube-scheduler.yaml")
+	before, _ := os.ReadFile(manifest)
+
+	if err := mgr.Restart("scheduler"); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	after, _ := os.ReadFile(manifest)
+	var p pod
+	if err := yaml.Unmarshal(after, &p); err != nil {
+		t.Fatalf("Manifest is not valid YAML: %v", err)
+	}
+	if p.Metadata.Annotations[restartAnnotation] == "" || string(before) == string(after) {
+		t.Errorf("Expected the manifest to change, got\n%s", after)
+	}
+	if len(p.Spec.Containers) != 1 || p.Spec.Containers[0].Name != "kube-scheduler" {
+		t.Errorf("Expected the pod spec to be kept, got %+v", p.Spec)
+	}
+}
+
+func TestVolumeName(t *testing.T) {
+	if got := volumeName("/var/lib/kubernetes/pki"); got != "var-lib-kubernetes-pki" {
+		t.Errorf("Unexpected volume name %s", got)
+	}
+	long := volumeName("/very/long/path/that/goes/on/and/on/and/on/well/past/the/dns/label/limit")
+	if len(long) > 63 {
+		t.Errorf("Volume name %s is longer than 63 characters", long)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/supervisor"
+)
+
+// daemonBinaries lists everything the manager starts from baseDir/bin in
+// reverse start order, which is also the order they are stopped in.
+var daemonBinaries = []string{
+	"kubelet",
+	"kube-scheduler",
+	"kube-controller-manager",
+	"kube-apiserver",
+	"containerd-shim-runc-v2",
+	"containerd",
+	"etcd",
+}
+
+// daemonNames — имена компонентов в супервизоре, в том же порядке остановки.
+var daemonNames = []string{
+	"kubelet",
+	"scheduler",
+	"controller-manager",
+	"apiserver",
+	"containerd",
+	"etcd",
+}
+
+// StopAll stops and disables every systemd unit, terminates every supervised
+// component and then every leftover process that runs one of the manager's
+// binaries (e.g. containerd shims or daemons started before PID files
+// existed). Processes get SIGTERM first and SIGKILL after timeout. It returns
+// a description of everything it stopped.
+func (m *Manager) StopAll(timeout time.Duration) ([]string, error) {
+	var stopped []string
+	var firstErr error
+
+	units, err := m.installedUnits()
+	if err != nil {
+		return nil, err
+	}
+	sortStopOrder(units)
+	for _, name := range units {
+		if err := systemctl("disable", "--now", unitName(name)); err != nil {
+			log.Printf("  Warning: failed to stop %s: %v", unitName(name), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		stopped = append(stopped, unitName(name))
+	}
+
+	statuses, err := m.supervisor.Status()
+	if err != nil {
+		return stopped, err
+	}
+	sort.SliceStable(statuses, func(a, b int) bool {
+		return stopRank(statuses[a].Name) < stopRank(statuses[b].Name)
+	})
+	for _, st := range statuses {
+		if err := m.supervisor.Stop(st.Name, timeout); err != nil {
+			log.Printf("  Warning: failed to stop %s: %v", st.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if st.Running {
+			stopped = append(stopped, fmt.Sprintf("%s (pid %d)", st.Name, st.PID))
+		}
+	}
+
+	for _, name := range daemonBinaries {
+		pids, err := findProcessesByExe(m.binPath(name))
+		if err != nil {
+			return stopped, err
+		}
+		for _, pid := range pids {
+			if err := stopProcess(pid, timeout); err != nil {
+				log.Printf("  Warning: failed to stop %s (pid %d): %v", name, pid, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			stopped = append(stopped, fmt.Sprintf("%s (pid %d)", name, pid))
+		}
+	}
+
+	return stopped, firstErr
+}
+
+// SortComponents orders component names for stopping (kubelet first, etcd
+// last), or the other way round for starting and restarting.
+func SortComponents(names []string, start bool) {
+	sortStopOrder(names)
+	if start {
+		slices.Reverse(names)
+	}
+}
+
+// sortStopOrder orders component names by stopRank.
+func sortStopOrder(names []string) {
+	sort.SliceStable(names, func(a, b int) bool {
+		return stopRank(names[a]) < stopRank(names[b])
+	})
+}
+
+// stopRank is the position of a component in daemonNames (kubelet first,
+// etcd last); unknown names go first. Members of a multi-member etcd
+// (etcd-<name>) rank as etcd.
+func stopRank(name string) int {
+	if strings.HasPrefix(name, "etcd-") {
+		name = "etcd"
+	}
+	for i, n := range daemonNames {
+		if n == name {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// findProcessesByExe returns the pids whose /proc/<pid>/exe resolves to path.
+func findProcessesByExe(path string) ([]int, error) {
+	want, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		exe, err := os.Readlink(filepath.Join("/proc", e.Name(), "exe"))
+		if err != nil {
+			continue
+		}
+		if exe == want || exe == want+" (deleted)" {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func stopProcess(pid int, timeout time.Duration) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := proc.Signal(syscall.SIGTERM); err != nil {
+		if err == os.ErrProcessDone {
+			return nil
+		}
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !supervisor.ProcessAlive(pid) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if err := proc.Signal(syscall.SIGKILL); err != nil && err != os.ErrProcessDone {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/supervisor"
+)
+
+// unitPrefix отделяет unit-файлы установщика от остальных в SystemdUnitDir.
+const unitPrefix = "k8s-"
+
+func unitName(name string) string {
+	return unitPrefix + name + ".service"
+}
+
+func (m *Manager) unitPath(name string) string {
+	return filepath.Join(m.cluster.Paths.SystemdUnitDir, unitName(name))
+}
+
+// startUnit записывает unit-файл компонента, включает его и (пере)запускает,
+// чтобы новые флаги применились и к уже работающему сервису.
+func (m *Manager) startUnit(c component) error {
+	if err := os.MkdirAll(m.cluster.Paths.LogDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log dir: %w", err)
+	}
+	if err := os.MkdirAll(m.cluster.Paths.SystemdUnitDir, 0755); err != nil {
+		return fmt.Errorf("failed to create unit dir: %w", err)
+	}
+	if err := os.WriteFile(m.unitPath(c.name), []byte(m.renderUnit(c)), 0644); err != nil {
+		return fmt.Errorf("failed to write unit: %w", err)
+	}
+
+	unit := unitName(c.name)
+	for _, args := range [][]string{
+		{"daemon-reload"},
+		{"enable", unit},
+		{"restart", unit},
+	} {
+		if err := systemctl(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderUnit собирает unit-файл из тех же флагов, что и при запуске под
+// супервизором. Порядок запуска задается через After=/Wants= по c.after.
+func (m *Manager) renderUnit(c component) string {
+	after := []string{"network-online.target"}
+	for _, dep := range c.after {
+		after = append(after, unitName(dep))
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by k8s-installer; manual changes are overwritten on the next install.\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", c.description)
+	fmt.Fprintf(&b, "After=%s\n", strings.Join(after, " "))
+	fmt.Fprintf(&b, "Wants=%s\n", strings.Join(after, " "))
+
+	b.WriteString("\n[Service]\n")
+	for _, env := range c.env {
+		fmt.Fprintf(&b, "Environment=%s\n", quoteUnitValue(env, true))
+	}
+	b.WriteString("ExecStart=" + quoteUnitValue(c.path, false))
+	for _, arg := range c.args {
+		b.WriteString(" \\\n  " + quoteUnitValue(arg, false))
+	}
+	b.WriteString("\n")
+	b.WriteString("Restart=always\n")
+	b.WriteString("RestartSec=5\n")
+	fmt.Fprintf(&b, "StandardOutput=append:%s\n", m.logPath(c.name))
+	b.WriteString("StandardError=inherit\n")
+	b.WriteString("LimitNOFILE=1048576\n")
+	for _, opt := range c.unitOptions {
+		b.WriteString(opt + "\n")
+	}
+
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// quoteUnitValue экранирует значение для unit-файла: спецификаторы (%) и
+// переменные ($) systemd раскрывает сам, поэтому они удваиваются.
+func quoteUnitValue(s string, always bool) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	if always || s == "" || strings.ContainsAny(s, " \t\"'\\;") {
+		s = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return s
+}
+
+// installedUnits возвращает имена компонентов, для которых есть unit-файлы.
+func (m *Manager) installedUnits() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(m.cluster.Paths.SystemdUnitDir, unitPrefix+"*.service"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, p := range matches {
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), unitPrefix), ".service"))
+	}
+	return names, nil
+}
+
+func (m *Manager) hasUnit(name string) bool {
+	_, err := os.Stat(m.unitPath(name))
+	return err == nil
+}
+
+// UnitFiles возвращает пути ко всем unit-файлам, установленным менеджером.
+func (m *Manager) UnitFiles() ([]string, error) {
+	names, err := m.installedUnits()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range names {
+		paths = append(paths, m.unitPath(name))
+	}
+	return paths, nil
+}
+
+func (m *Manager) unitStatus(name string) (supervisor.Status, error) {
+	out, err := exec.Command("systemctl", "show", unitName(name),
+		"--property=MainPID,ActiveState,NRestarts,ExecMainStartTimestamp").Output()
+	if err != nil {
+		return supervisor.Status{}, fmt.Errorf("systemctl show %s: %w", unitName(name), err)
+	}
+	return parseUnitStatus(name, string(out)), nil
+}
+
+// parseUnitStatus разбирает вывод "systemctl show" (строки Key=Value).
+func parseUnitStatus(name, out string) supervisor.Status {
+	st := supervisor.Status{Name: name}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "MainPID":
+			st.PID, _ = strconv.Atoi(value)
+		case "NRestarts":
+			st.Restarts, _ = strconv.Atoi(value)
+		case "ActiveState":
+			st.Running = value == "active"
+			// inactive — остановлен явно, failed/activating — упал.
+			st.Stopped = value == "inactive"
+		case "ExecMainStartTimestamp":
+			if t, err := time.Parse("Mon 2006-01-02 15:04:05 MST", value); err == nil {
+				st.StartedAt = t
+			}
+		}
+	}
+	return st
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
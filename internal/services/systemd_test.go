@@ -0,0 +1,120 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+func newTestManager() *Manager {
+	cluster := config.Default()
+	cluster.Network.HostIP = "192.168.1.10"
+	cluster.Runtime.Init = config.InitSystemd
+	cluster.Complete()
+	return NewManagerFromConfig(cluster, false)
+}
+
+func TestRenderUnitUsesComponentFlags(t *testing.T) {
+	mgr := newTestManager()
+	c := mgr.etcdComponent(mgr.cluster.EtcdMembers()[0])
+	unit := mgr.renderUnit(c)
+
+	if !strings.Contains(unit, "ExecStart=/var/lib/kubernetes/bin/etcd \\\n") {
+		t.Errorf("Expected ExecStart with etcd binary, got:\n%s", unit)
+	}
+	for _, arg := range c.args {
+		if !strings.Contains(unit, "  "+arg+"\n") && !strings.Contains(unit, "  "+arg+" \\\n") {
+			t.Errorf("Expected unit to contain flag %s, got:\n%s", arg, unit)
+		}
+	}
+	for _, line := range []string{
+		"Description=etcd key-value store\n",
+		"Restart=always\n",
+		"StandardOutput=append:/var/log/kubernetes/etcd.log\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, line) {
+			t.Errorf("Expected unit to contain %q, got:\n%s", line, unit)
+		}
+	}
+}
+
+func TestRenderUnitOrdering(t *testing.T) {
+	mgr := newTestManager()
+
+	tests := []struct {
+		name  string
+		c     component
+		after string
+	}{
+		{"etcd", mgr.etcdComponent(mgr.cluster.EtcdMembers()[0]), "After=network-online.target\n"},
+		{"apiserver", mgr.apiServerComponent([]string{"https://127.0.0.1:2379"}), "After=network-online.target k8s-etcd.service\n"},
+		{"kubelet", mgr.kubeletComponent("node1"), "After=network-online.target k8s-containerd.service k8s-apiserver.service\n"},
+		{"scheduler", mgr.schedulerComponent(), "After=network-online.target k8s-apiserver.service\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit := mgr.renderUnit(tt.c)
+			if !strings.Contains(unit, tt.after) {
+				t.Errorf("Expected %q, got:\n%s", tt.after, unit)
+			}
+			wants := "Wants=" + strings.TrimPrefix(tt.after, "After=")
+			if !strings.Contains(unit, wants) {
+				t.Errorf("Expected %q, got:\n%s", wants, unit)
+			}
+		})
+	}
+}
+
+func TestRenderUnitEnvironmentAndOptions(t *testing.T) {
+	mgr := newTestManager()
+	unit := mgr.renderUnit(mgr.containerdComponent())
+
+	for _, line := range []string{
+		`Environment="CONTAINERD_NAMESPACE=k8s.io"`,
+		"  -c \\\n  /etc/containerd/config.toml \\\n",
+		"Delegate=yes\n",
+		"KillMode=process\n",
+	} {
+		if !strings.Contains(unit, line) {
+			t.Errorf("Expected unit to contain %q, got:\n%s", line, unit)
+		}
+	}
+}
+
+func TestQuoteUnitValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"--v=2", "--v=2"},
+		{"--path=/a b", `"--path=/a b"`},
+		{"--fmt=%s", "--fmt=%%s"},
+		{"$HOME", "$$HOME"},
+		{`say "hi"`, `"say \"hi\""`},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := quoteUnitValue(tt.in, false); got != tt.want {
+			t.Errorf("quoteUnitValue(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnitStatus(t *testing.T) {
+	out := "MainPID=4242\nActiveState=active\nNRestarts=3\nExecMainStartTimestamp=Thu 2024-01-04 10:00:00 UTC\n"
+	st := parseUnitStatus("etcd", out)
+
+	if st.Name != "etcd" || st.PID != 4242 || !st.Running || st.Stopped || st.Restarts != 3 {
+		t.Errorf("Unexpected status: %+v", st)
+	}
+	if st.StartedAt.IsZero() {
+		t.Error("Expected start time to be parsed")
+	}
+
+	st = parseUnitStatus("etcd", "MainPID=0\nActiveState=inactive\n")
+	if st.Running || !st.Stopped {
+		t.Errorf("Expected stopped unit, got %+v", st)
+	}
+}
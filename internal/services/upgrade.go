@@ -0,0 +1,106 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dereban25/k8s-installer/internal/config"
+)
+
+// UpgradeOrder — порядок перезапуска компонентов при обновлении: сначала
+// control plane, kubelet последним, чтобы он никогда не был новее API
+// server. etcd обновлением не перезапускается.
+var UpgradeOrder = []string{"apiserver", "controller-manager", "scheduler", "kubelet"}
+
+// Relaunch перезапускает компонент с бинарником и флагами текущего spec и
+// ждет, пока он станет здоров, но не дольше timeout.
+func (m *Manager) Relaunch(name string, timeout time.Duration) error {
+	c, err := m.componentByName(name)
+	if err != nil {
+		return err
+	}
+
+	// Под супервизором Start не трогает работающий процесс, поэтому старый
+	// останавливаем сами; systemd и kubelet перезапускают компонент сами.
+	if !(c.image != "" && m.staticPods()) && m.cluster.Runtime.Init != config.InitSystemd {
+		if err := m.supervisor.Stop(c.name, stopTimeout); err != nil {
+			log.Printf("  ⚠ Failed to stop %s: %v", c.name, err)
+		}
+	}
+	if err := m.launch(c); err != nil {
+		return err
+	}
+	return m.waitHealthy(name, timeout)
+}
+
+// componentByName собирает компонент из UpgradeOrder по имени.
+func (m *Manager) componentByName(name string) (component, error) {
+	switch name {
+	case "apiserver":
+		etcdServers := m.EtcdServers()
+		if len(m.cluster.Etcd.Members) == 0 {
+			var err error
+			if etcdServers, err = m.localEtcdServers(); err != nil {
+				return component{}, err
+			}
+		}
+		return m.apiServerComponent(etcdServers), nil
+	case "controller-manager":
+		return m.controllerManagerComponent(), nil
+	case "scheduler":
+		return m.schedulerComponent(), nil
+	case "kubelet":
+		hostname, err := os.Hostname()
+		if err != nil {
+			return component{}, fmt.Errorf("failed to get hostname: %w", err)
+		}
+		return m.kubeletComponent(hostname), nil
+	}
+	return component{}, fmt.Errorf("unknown component %q", name)
+}
+
+// healthURL — адрес проверки здоровья компонента на этом хосте; порты —
+// значения по умолчанию самих компонентов.
+func (m *Manager) healthURL(name string) string {
+	switch name {
+	case "apiserver":
+		return fmt.Sprintf("https://127.0.0.1:%d/readyz", m.cluster.Network.APIServerPort)
+	case "controller-manager":
+		return "https://127.0.0.1:10257/healthz"
+	case "scheduler":
+		return "https://127.0.0.1:10259/healthz"
+	default:
+		return "http://127.0.0.1:10248/healthz"
+	}
+}
+
+// waitHealthy ждет трех успешных проверок подряд: только что запущенный
+// компонент может ответить и сразу упасть.
+func (m *Manager) waitHealthy(name string, timeout time.Duration) error {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	token := readBootstrapToken(filepath.Join(m.cluster.Paths.PKIDir, "token.csv"))
+	url := m.healthURL(name)
+
+	successCount, required := 0, 3
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(2 * time.Second) {
+		if !probeReadyz(client, url, token) {
+			successCount = 0
+			continue
+		}
+		if successCount++; successCount >= required {
+			log.Printf("  ✓ %s is healthy", name)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s did not become healthy in %s. Check: tail -100 %s", name, timeout, m.logPath(name))
+}
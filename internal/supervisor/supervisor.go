@@ -0,0 +1,419 @@
+// Package supervisor starts long-running component processes, records them
+// in PID files and restarts them with backoff when they exit unexpectedly.
+//
+// Every process is described by a record in runDir (<name>.json) next to its
+// PID file (<name>.pid). The record holds the command line and the desired
+// state, so any invocation of the installer can report status, stop or
+// restart a component, and a long-running "supervise" process can adopt
+// components started by an earlier installer run.
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Process describes how to start a component.
+type Process struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path"`
+	Args    []string `json:"args"`
+	Env     []string `json:"env,omitempty"`
+	LogFile string   `json:"logFile"`
+}
+
+// record is what gets persisted in runDir for every process.
+type record struct {
+	Process   Process   `json:"process"`
+	PID       int       `json:"pid"`
+	Restarts  int       `json:"restarts"`
+	StartedAt time.Time `json:"startedAt"`
+	// Stopped is the desired state: a stopped process is never restarted.
+	Stopped bool `json:"stopped"`
+}
+
+// Status is a point-in-time view of a supervised process.
+type Status struct {
+	Name      string
+	PID       int
+	Running   bool
+	Stopped   bool
+	Restarts  int
+	StartedAt time.Time
+}
+
+type Supervisor struct {
+	runDir string
+
+	// MinBackoff and MaxBackoff bound the delay between restarts; the delay
+	// doubles after every crash and resets once a process has stayed up for
+	// StableAfter.
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration
+
+	mu    sync.Mutex
+	procs map[string]*child
+}
+
+// child is a process started by this supervisor instance.
+type child struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+func New(runDir string) *Supervisor {
+	return &Supervisor{
+		runDir:      runDir,
+		MinBackoff:  time.Second,
+		MaxBackoff:  30 * time.Second,
+		StableAfter: time.Minute,
+		procs:       map[string]*child{},
+	}
+}
+
+// replaceTimeout bounds how long Start waits for a still running instance
+// of the process to exit before it starts the new one.
+const replaceTimeout = 10 * time.Second
+
+// Start launches the process, writes its PID file and keeps restarting it
+// for as long as this supervisor lives and the process is not stopped. A
+// recorded instance that is still running, e.g. from an earlier install
+// run, is stopped first, so there is never more than one.
+func (s *Supervisor) Start(p Process) error {
+	if err := os.MkdirAll(s.runDir, 0755); err != nil {
+		return fmt.Errorf("failed to create run dir: %w", err)
+	}
+
+	rec := &record{Process: p}
+	if old, err := s.readRecord(p.Name); err == nil {
+		rec.Restarts = old.Restarts
+		if s.alive(old) {
+			log.Printf("  %s is already running (pid %d); stopping it first", p.Name, old.PID)
+			if err := s.Stop(p.Name, replaceTimeout); err != nil {
+				return err
+			}
+		}
+	}
+
+	c, err := s.spawn(rec)
+	if err != nil {
+		return err
+	}
+	go s.monitor(p.Name, c, s.MinBackoff)
+	return nil
+}
+
+func (s *Supervisor) spawn(rec *record) (*child, error) {
+	p := rec.Process
+
+	logF, err := os.OpenFile(p.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log %s: %w", p.LogFile, err)
+	}
+
+	cmd := exec.Command(p.Path, p.Args...)
+	cmd.Env = p.Env
+	cmd.Stdout = logF
+	cmd.Stderr = logF
+	// Own process group: Ctrl-C on the installer must not take the
+	// components down with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		logF.Close()
+		return nil, fmt.Errorf("failed to start %s: %w", p.Path, err)
+	}
+
+	rec.PID = cmd.Process.Pid
+	rec.StartedAt = time.Now()
+	rec.Stopped = false
+	if err := s.writeRecord(rec); err != nil {
+		log.Printf("  Warning: failed to record %s: %v", p.Name, err)
+	}
+
+	c := &child{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		logF.Close()
+		close(c.done)
+	}()
+
+	s.mu.Lock()
+	s.procs[p.Name] = c
+	s.mu.Unlock()
+	return c, nil
+}
+
+// monitor waits for the child to exit and restarts it unless it was stopped.
+func (s *Supervisor) monitor(name string, c *child, backoff time.Duration) {
+	for {
+		<-c.done
+
+		s.mu.Lock()
+		current := s.procs[name] == c
+		s.mu.Unlock()
+		if !current {
+			return
+		}
+
+		rec, err := s.readRecord(name)
+		if err != nil || rec.Stopped || rec.PID != c.cmd.Process.Pid {
+			return
+		}
+
+		uptime := time.Since(rec.StartedAt)
+		if uptime >= s.StableAfter {
+			backoff = s.MinBackoff
+		}
+		log.Printf("  %s (pid %d) exited after %s: %v; restarting in %s",
+			name, rec.PID, uptime.Round(time.Second), c.cmd.ProcessState, backoff)
+		time.Sleep(backoff)
+
+		// Stop may have been requested while we were backing off.
+		if rec, err = s.readRecord(name); err != nil || rec.Stopped {
+			return
+		}
+		rec.Restarts++
+		if c, err = s.spawn(rec); err != nil {
+			log.Printf("  Warning: failed to restart %s: %v", name, err)
+			return
+		}
+		backoff = min(backoff*2, s.MaxBackoff)
+	}
+}
+
+// Stop marks the process as stopped and terminates it, escalating to
+// SIGKILL after timeout.
+func (s *Supervisor) Stop(name string, timeout time.Duration) error {
+	rec, err := s.readRecord(name)
+	if err != nil {
+		return err
+	}
+	rec.Stopped = true
+	if err := s.writeRecord(rec); err != nil {
+		return err
+	}
+	defer os.Remove(s.pidPath(name))
+
+	if !s.alive(rec) {
+		return nil
+	}
+
+	s.mu.Lock()
+	c := s.procs[name]
+	s.mu.Unlock()
+
+	if err := syscall.Kill(rec.PID, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to stop %s: %w", name, err)
+	}
+	if waitExit(rec.PID, c, timeout) {
+		return nil
+	}
+	if err := syscall.Kill(rec.PID, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to kill %s: %w", name, err)
+	}
+	waitExit(rec.PID, c, 5*time.Second)
+	return nil
+}
+
+// Restart stops the process and starts it again from its recorded command.
+func (s *Supervisor) Restart(name string, timeout time.Duration) error {
+	rec, err := s.readRecord(name)
+	if err != nil {
+		return err
+	}
+	if err := s.Stop(name, timeout); err != nil {
+		return err
+	}
+	return s.Start(rec.Process)
+}
+
+// Status returns every recorded process sorted by name.
+func (s *Supervisor) Status() ([]Status, error) {
+	names, err := s.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Status
+	for _, name := range names {
+		rec, err := s.readRecord(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Status{
+			Name:      name,
+			PID:       rec.PID,
+			Running:   s.alive(rec),
+			Stopped:   rec.Stopped,
+			Restarts:  rec.Restarts,
+			StartedAt: rec.StartedAt,
+		})
+	}
+	return result, nil
+}
+
+// Names lists the recorded processes.
+func (s *Supervisor) Names() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.runDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(m), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Watch adopts every recorded process and restarts the ones that are not
+// running and not stopped, until ctx is cancelled. It is meant for a
+// long-running "supervise" process that outlives the installer.
+func (s *Supervisor) Watch(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		names, err := s.Names()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			s.mu.Lock()
+			_, ours := s.procs[name]
+			s.mu.Unlock()
+			if ours {
+				// Children are handled by their monitor goroutine.
+				continue
+			}
+
+			rec, err := s.readRecord(name)
+			if err != nil || rec.Stopped || s.alive(rec) {
+				continue
+			}
+			log.Printf("  %s (pid %d) is not running; restarting", name, rec.PID)
+			rec.Restarts++
+			if err := s.writeRecord(rec); err != nil {
+				log.Printf("  Warning: failed to record %s: %v", name, err)
+			}
+			if err := s.Start(rec.Process); err != nil {
+				log.Printf("  Warning: failed to restart %s: %v", name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// alive reports whether the recorded PID still runs the recorded binary, so
+// a recycled PID is never mistaken for the component.
+func (s *Supervisor) alive(rec *record) bool {
+	if rec.PID <= 0 || !ProcessAlive(rec.PID) {
+		return false
+	}
+	exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(rec.PID), "exe"))
+	if err != nil {
+		// Not our process to inspect (e.g. running unprivileged); trust the PID.
+		return true
+	}
+	want, _ := filepath.Abs(rec.Process.Path)
+	if resolved, err := filepath.EvalSymlinks(want); err == nil {
+		want = resolved
+	}
+	return strings.TrimSuffix(exe, " (deleted)") == want
+}
+
+func (s *Supervisor) pidPath(name string) string {
+	return filepath.Join(s.runDir, name+".pid")
+}
+
+func (s *Supervisor) recordPath(name string) string {
+	return filepath.Join(s.runDir, name+".json")
+}
+
+func (s *Supervisor) readRecord(name string) (*record, error) {
+	data, err := os.ReadFile(s.recordPath(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("unknown component %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &record{}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.recordPath(name), err)
+	}
+	return rec, nil
+}
+
+func (s *Supervisor) writeRecord(rec *record) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.recordPath(rec.Process.Name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.recordPath(rec.Process.Name)); err != nil {
+		return err
+	}
+	if rec.Stopped {
+		return nil
+	}
+	return os.WriteFile(s.pidPath(rec.Process.Name), []byte(strconv.Itoa(rec.PID)+"\n"), 0644)
+}
+
+// waitExit waits until the process is gone. For our own children the exit
+// is observed through Wait, otherwise by polling.
+func waitExit(pid int, c *child, timeout time.Duration) bool {
+	if c != nil {
+		select {
+		case <-c.done:
+			return true
+		case <-time.After(timeout):
+			return false
+		}
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !ProcessAlive(pid) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+// ProcessAlive reports whether pid exists and is not a zombie.
+func ProcessAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+	// Format: pid (comm) state ...; comm may contain spaces.
+	if i := strings.LastIndexByte(string(stat), ')'); i >= 0 && i+2 < len(stat) {
+		return stat[i+2] != 'Z'
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sleepLoop keeps /bin/sh itself running (rather than exec'ing another
+// binary) so the recorded executable matches the live process.
+const sleepLoop = "trap 'exit 0' TERM; while :; do sleep 0.05; done"
+
+func newTestSupervisor(t *testing.T) *Supervisor {
+	t.Helper()
+	s := New(filepath.Join(t.TempDir(), "run"))
+	s.MinBackoff = 10 * time.Millisecond
+	s.MaxBackoff = 50 * time.Millisecond
+	return s
+}
+
+func shell(t *testing.T, name, script string) Process {
+	t.Helper()
+	return Process{
+		Name:    name,
+		Path:    "/bin/sh",
+		Args:    []string{"-c", script},
+		LogFile: filepath.Join(t.TempDir(), name+".log"),
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func status(t *testing.T, s *Supervisor, name string) Status {
+	t.Helper()
+	statuses, err := s.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, st := range statuses {
+		if st.Name == name {
+			return st
+		}
+	}
+	t.Fatalf("No status for %s", name)
+	return Status{}
+}
+
+func TestStartWritesPIDFile(t *testing.T) {
+	s := newTestSupervisor(t)
+	if err := s.Start(shell(t, "sleeper", sleepLoop)); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop("sleeper", time.Second)
+
+	data, err := os.ReadFile(filepath.Join(s.runDir, "sleeper.pid"))
+	if err != nil {
+		t.Fatalf("Failed to read PID file: %v", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+
+	st := status(t, s, "sleeper")
+	if !st.Running || st.PID != pid {
+		t.Errorf("Expected running process with pid %d, got %+v", pid, st)
+	}
+}
+
+func TestRestartsCrashedProcess(t *testing.T) {
+	s := newTestSupervisor(t)
+	if err := s.Start(shell(t, "crasher", "exit 1")); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	waitFor(t, "restarts", func() bool { return status(t, s, "crasher").Restarts >= 2 })
+
+	if err := s.Stop("crasher", time.Second); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	restarts := status(t, s, "crasher").Restarts
+	time.Sleep(100 * time.Millisecond)
+	if got := status(t, s, "crasher").Restarts; got != restarts {
+		t.Errorf("Process restarted after Stop: %d -> %d", restarts, got)
+	}
+}
+
+func TestStopAndRestart(t *testing.T) {
+	s := newTestSupervisor(t)
+	if err := s.Start(shell(t, "sleeper", sleepLoop)); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	first := status(t, s, "sleeper").PID
+
+	if err := s.Stop("sleeper", time.Second); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	st := status(t, s, "sleeper")
+	if st.Running || !st.Stopped {
+		t.Errorf("Expected stopped process, got %+v", st)
+	}
+	if _, err := os.Stat(filepath.Join(s.runDir, "sleeper.pid")); !os.IsNotExist(err) {
+		t.Errorf("Expected PID file to be removed, got %v", err)
+	}
+
+	if err := s.Restart("sleeper", time.Second); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	defer s.Stop("sleeper", time.Second)
+
+	st = status(t, s, "sleeper")
+	if !st.Running || st.PID == first {
+		t.Errorf("Expected a new running process, got %+v (old pid %d)", st, first)
+	}
+}
+
+func TestStartReplacesRunningProcess(t *testing.T) {
+	runDir := filepath.Join(t.TempDir(), "run")
+	p := shell(t, "sleeper", sleepLoop)
+
+	// An earlier installer run left the process running.
+	first := New(runDir)
+	if err := first.Start(p); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	old := status(t, first, "sleeper").PID
+
+	s := New(runDir)
+	if err := s.Start(p); err != nil {
+		t.Fatalf("second Start failed: %v", err)
+	}
+	defer s.Stop("sleeper", time.Second)
+
+	if ProcessAlive(old) {
+		t.Errorf("Expected the running process %d to be stopped before starting a new one", old)
+	}
+	if st := status(t, s, "sleeper"); !st.Running || st.PID == old {
+		t.Errorf("Expected a single new running process, got %+v (old pid %d)", st, old)
+	}
+}
+
+func TestWatchAdoptsRecordedProcesses(t *testing.T) {
+	runDir := filepath.Join(t.TempDir(), "run")
+
+	// An earlier installer run started the process and went away.
+	first := New(runDir)
+	if err := first.Start(shell(t, "sleeper", sleepLoop)); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	pid := status(t, first, "sleeper").PID
+	first.mu.Lock()
+	delete(first.procs, "sleeper")
+	first.mu.Unlock()
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		t.Fatalf("Failed to kill process: %v", err)
+	}
+
+	s := New(runDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx, 20*time.Millisecond)
+
+	waitFor(t, "restart by watcher", func() bool {
+		st := status(t, s, "sleeper")
+		return st.Running && st.PID != pid
+	})
+	if err := s.Stop("sleeper", time.Second); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+func TestUnknownComponent(t *testing.T) {
+	s := newTestSupervisor(t)
+	if err := s.Stop("nope", time.Second); err == nil {
+		t.Fatal("Expected error for unknown component")
+	}
+}
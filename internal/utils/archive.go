@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractOptions select what ExtractTarGz writes.
+type ExtractOptions struct {
+	// StripComponents drops that many leading path elements from every
+	// member name, like tar --strip-components. Members with no elements
+	// left are skipped.
+	StripComponents int
+	// Members are path.Match patterns for the stripped names to extract;
+	// empty extracts everything. Every pattern must match a member.
+	Members []string
+}
+
+// ExtractTarGz extracts the gzip-compressed tarball archivePath into
+// destDir and returns the paths written. Member names that are absolute or
+// leave destDir, members below a symlink, symlinks pointing outside
+// destDir and hard links are rejected. File permissions and modification times are preserved; files
+// are replaced through a rename, so a running binary can be upgraded.
+func ExtractTarGz(archivePath, destDir string, opts ExtractOptions) ([]string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", archivePath, err)
+	}
+	defer gz.Close()
+
+	matched := make([]bool, len(opts.Members))
+	var written []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, fmt.Errorf("%s: %w", archivePath, err)
+		}
+
+		name, err := memberName(hdr.Name, opts.StripComponents)
+		if err != nil {
+			return written, fmt.Errorf("%s: %w", archivePath, err)
+		}
+		if name == "" || !selectMember(name, opts.Members, matched) {
+			continue
+		}
+		if err := checkParents(destDir, name); err != nil {
+			return written, fmt.Errorf("%s: %w", archivePath, err)
+		}
+		target := filepath.Join(destDir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, dirMode(hdr)); err != nil {
+				return written, err
+			}
+			continue
+		case tar.TypeReg:
+			if err := writeMember(tr, target, hdr); err != nil {
+				return written, err
+			}
+		case tar.TypeSymlink:
+			if err := writeSymlink(destDir, name, target, hdr.Linkname); err != nil {
+				return written, fmt.Errorf("%s: %w", archivePath, err)
+			}
+		default:
+			return written, fmt.Errorf("%s: %s has unsupported type %q", archivePath, hdr.Name, hdr.Typeflag)
+		}
+		written = append(written, target)
+	}
+
+	var missing []string
+	for n, ok := range matched {
+		if !ok {
+			missing = append(missing, opts.Members[n])
+		}
+	}
+	if len(missing) > 0 {
+		return written, fmt.Errorf("%s has no %s", archivePath, strings.Join(missing, ", "))
+	}
+	return written, nil
+}
+
+// memberName cleans a member name and strips its leading elements. It
+// fails for names escaping the destination.
+func memberName(name string, strip int) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("unsafe path %q", name)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	parts := strings.Split(clean, "/")
+	if len(parts) <= strip {
+		return "", nil
+	}
+	return strings.Join(parts[strip:], "/"), nil
+}
+
+// selectMember reports whether name is wanted and marks the patterns it
+// matches.
+func selectMember(name string, patterns []string, matched []bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	selected := false
+	for n, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			matched[n] = true
+			selected = true
+		}
+	}
+	return selected
+}
+
+// checkParents fails when a parent directory of the member name is a
+// symlink: writing through it could leave destDir, e.g. after "d -> ."
+// and "d/e -> .." a member "d/e/file" lands next to destDir.
+func checkParents(destDir, name string) error {
+	dir := destDir
+	parts := strings.Split(name, "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is below symlink %s", name, dir)
+		}
+	}
+	return nil
+}
+
+func writeMember(r io.Reader, target string, hdr *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".extract"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Chmod after writing: the umask does not apply to it.
+		err = os.Chmod(tmp, hdr.FileInfo().Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, hdr.ModTime, hdr.ModTime)
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to extract %s: %w", target, err)
+	}
+	return nil
+}
+
+func writeSymlink(destDir, name, target, linkname string) error {
+	resolved := path.Join(path.Dir(name), linkname)
+	if path.IsAbs(linkname) || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return fmt.Errorf("symlink %s -> %s points outside %s", name, linkname, destDir)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(linkname, target)
+}
+
+func dirMode(hdr *tar.Header) os.FileMode {
+	if mode := hdr.FileInfo().Mode().Perm(); mode != 0 {
+		return mode
+	}
+	return 0755
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	content  string
+	linkname string
+}
+
+func writeTarGz(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "archive.tar.gz")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		if e.typeflag == 0 {
+			e.typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: e.mode, Size: int64(len(e.content)),
+			Linkname: e.linkname, ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.content))
+	}
+	tw.Close()
+	gz.Close()
+	return p
+}
+
+func TestExtractTarGzSelectsAndStripsMembers(t *testing.T) {
+	archive := writeTarGz(t, []tarEntry{
+		{name: "etcd-v3.5.12-linux-amd64/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "etcd-v3.5.12-linux-amd64/etcd", mode: 0755, content: "etcd"},
+		{name: "etcd-v3.5.12-linux-amd64/etcdutl", mode: 0750, content: "etcdutl"},
+		{name: "etcd-v3.5.12-linux-amd64/README.md", mode: 0644, content: "docs"},
+	})
+	dest := t.TempDir()
+
+	written, err := ExtractTarGz(archive, dest, ExtractOptions{StripComponents: 1, Members: []string{"etcdutl"}})
+	if err != nil {
+		t.Fatalf("ExtractTarGz failed: %v", err)
+	}
+	if len(written) != 1 || written[0] != filepath.Join(dest, "etcdutl") {
+		t.Fatalf("Expected only etcdutl, got %v", written)
+	}
+	info, err := os.Stat(written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("Expected mode 0750 to be preserved, got %v", info.Mode())
+	}
+	if !info.ModTime().Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Expected the modification time to be preserved, got %v", info.ModTime())
+	}
+	entries, _ := os.ReadDir(dest)
+	if len(entries) != 1 {
+		t.Errorf("Expected nothing else in %s, got %v", dest, entries)
+	}
+}
+
+func TestExtractTarGzAll(t *testing.T) {
+	archive := writeTarGz(t, []tarEntry{
+		{name: "./", typeflag: tar.TypeDir, mode: 0755},
+		{name: "./bridge", mode: 0755, content: "bridge"},
+		{name: "./loopback", mode: 0755, content: "loopback"},
+		{name: "./lo", typeflag: tar.TypeSymlink, linkname: "loopback"},
+	})
+	dest := t.TempDir()
+
+	written, err := ExtractTarGz(archive, dest, ExtractOptions{})
+	if err != nil {
+		t.Fatalf("ExtractTarGz failed: %v", err)
+	}
+	var names []string
+	for _, p := range written {
+		names = append(names, filepath.Base(p))
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "bridge,lo,loopback" {
+		t.Errorf("Unexpected members %v", names)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "lo")); err != nil || string(data) != "loopback" {
+		t.Errorf("Expected the symlink to resolve to loopback, got %q (%v)", data, err)
+	}
+}
+
+func TestExtractTarGzReplacesExistingFiles(t *testing.T) {
+	dest := t.TempDir()
+	old := filepath.Join(dest, "containerd")
+	if err := os.WriteFile(old, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A running binary keeps its inode; the new one is renamed over it.
+	running, err := os.Open(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer running.Close()
+
+	archive := writeTarGz(t, []tarEntry{{name: "bin/containerd", mode: 0755, content: "new"}})
+	if _, err := ExtractTarGz(archive, dest, ExtractOptions{StripComponents: 1}); err != nil {
+		t.Fatalf("ExtractTarGz failed: %v", err)
+	}
+	if data, _ := os.ReadFile(old); string(data) != "new" {
+		t.Errorf("Expected containerd to be replaced, got %q", data)
+	}
+}
+
+func TestExtractTarGzRejectsUnsafeArchives(t *testing.T) {
+	tests := map[string][]tarEntry{
+		"parent dir":       {{name: "../../etc/cron.d/evil", content: "x"}},
+		"absolute":         {{name: "/etc/cron.d/evil", content: "x"}},
+		"escaping symlink": {{name: "evil", typeflag: tar.TypeSymlink, linkname: "../../etc"}},
+		"absolute symlink": {{name: "evil", typeflag: tar.TypeSymlink, linkname: "/etc"}},
+		"hard link":        {{name: "evil", typeflag: tar.TypeLink, linkname: "bridge"}},
+		"symlink chain": {
+			{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "d/e", typeflag: tar.TypeSymlink, linkname: ".."},
+			{name: "d/e/pwned", content: "x"},
+		},
+	}
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			if _, err := ExtractTarGz(writeTarGz(t, entries), dest, ExtractOptions{}); err == nil {
+				t.Fatal("Expected the archive to be rejected")
+			}
+			if entries, _ := os.ReadDir(parent); len(entries) > 1 {
+				t.Errorf("Expected nothing to be written outside %s, got %v", dest, entries)
+			}
+		})
+	}
+}
+
+func TestExtractTarGzReportsMissingMembers(t *testing.T) {
+	archive := writeTarGz(t, []tarEntry{{name: "bin/containerd", mode: 0755, content: "containerd"}})
+
+	_, err := ExtractTarGz(archive, t.TempDir(), ExtractOptions{StripComponents: 1, Members: []string{"containerd", "ctr"}})
+	if err == nil || !strings.Contains(err.Error(), "has no ctr") {
+		t.Errorf("Expected the missing ctr to be reported, got %v", err)
+	}
+}
@@ -1,10 +1,20 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // DownloadFile downloads a file from a URL to a destination path
@@ -30,4 +40,380 @@ func DownloadFile(url, destPath string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// DownloadVerified downloads url to destPath with a default Downloader and
+// checks its SHA256 against the expected hex digest.
+func DownloadVerified(url, destPath, sha256Hex string) error {
+	return NewDownloader().Download(url, destPath, sha256Hex)
+}
+
+// Downloader fetches large artifacts over unreliable networks: an
+// interrupted download is resumed with an HTTP Range request, transient
+// failures are retried with exponential backoff, and progress is reported
+// as events.
+type Downloader struct {
+	Client *http.Client
+	// Attempts bounds the tries per file.
+	Attempts int
+	// Backoff is the wait before the second attempt; it doubles after every
+	// failure up to maxBackoff.
+	Backoff time.Duration
+	// StallTimeout aborts an attempt that receives no data for this long.
+	StallTimeout time.Duration
+	// Progress, when set, receives the events of every download. It is
+	// called from the downloading goroutines.
+	Progress func(ProgressEvent)
+	// Mirror, when set, maps an upstream URL to the URL it is actually
+	// fetched from. Events and errors keep naming the upstream URL.
+	Mirror func(url string) string
+}
+
+const (
+	maxBackoff = time.Minute
+	// progressInterval throttles "progress" events of a download.
+	progressInterval = 500 * time.Millisecond
+)
+
+// NewDownloader returns a Downloader with the default limits.
+func NewDownloader() *Downloader {
+	transport, _ := NewTransport("", "")
+	return &Downloader{
+		// No overall timeout: artifacts are hundreds of MB, stalls are
+		// caught by StallTimeout instead.
+		Client:       &http.Client{Transport: transport},
+		Attempts:     5,
+		Backoff:      2 * time.Second,
+		StallTimeout: time.Minute,
+	}
+}
+
+// Download fetches url to destPath and checks its SHA256 against the
+// expected hex digest. Data is written to destPath.part, which a later call
+// resumes, and only renamed into place when the digest matches, so a
+// corrupted or tampered download never ends up at destPath.
+func (d *Downloader) Download(url, destPath, sha256Hex string) error {
+	part := destPath + ".part"
+	start := time.Now()
+	var size int64
+	for attempt := 1; ; attempt++ {
+		resumed, err := d.attempt(url, part, attempt)
+		if err == nil {
+			var got string
+			got, size, err = hashFile(part)
+			if err == nil && !strings.EqualFold(got, sha256Hex) {
+				os.Remove(part)
+				err = fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", url, sha256Hex, got)
+				// Resumed data may belong to another build of the file;
+				// only a fresh download proves the mismatch.
+				if !resumed {
+					err = permanent(err)
+				}
+			}
+		}
+		if err == nil {
+			break
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) || attempt >= d.Attempts {
+			d.report(ProgressEvent{URL: url, Event: EventFailed, Attempt: attempt, Error: err.Error()})
+			if perm != nil {
+				return perm.err
+			}
+			return err
+		}
+		wait := d.backoff(attempt)
+		d.report(ProgressEvent{URL: url, Event: EventRetry, Attempt: attempt, Error: err.Error(), Wait: wait})
+		time.Sleep(wait)
+	}
+
+	if err := os.Rename(part, destPath); err != nil {
+		return err
+	}
+	d.report(ProgressEvent{URL: url, Event: EventDone, Bytes: size, Total: size, Elapsed: time.Since(start)})
+	return nil
+}
+
+// attempt downloads the rest of url into part. It reports whether earlier
+// data of part was kept.
+func (d *Downloader) attempt(url, part string, attempt int) (bool, error) {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.source(url), nil)
+	if err != nil {
+		return false, permanent(err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			os.Remove(part)
+			return false, fmt.Errorf("unexpected Content-Range %q when resuming at %d", resp.Header.Get("Content-Range"), offset)
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The part file is already complete; the checksum decides.
+		return true, nil
+	default:
+		err := fmt.Errorf("bad status: %s", resp.Status)
+		if !transientStatus(resp.StatusCode) {
+			err = permanent(err)
+		}
+		return false, err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return false, permanent(fmt.Errorf("failed to create file: %w", err))
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	event := ProgressEvent{URL: url, Event: EventStart, Bytes: offset, Total: total, Attempt: attempt}
+	d.report(event)
+
+	var stalled atomic.Bool
+	timer := time.AfterFunc(d.StallTimeout, func() {
+		stalled.Store(true)
+		cancel()
+	})
+	defer timer.Stop()
+
+	started, last := time.Now(), time.Now()
+	body := &progressReader{r: resp.Body, onRead: func(n int) {
+		timer.Reset(d.StallTimeout)
+		event.Bytes += int64(n)
+		if time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+		event.Event = EventProgress
+		event.Elapsed = time.Since(started)
+		event.Rate = float64(event.Bytes-offset) / event.Elapsed.Seconds()
+		d.report(event)
+	}}
+	_, err = io.Copy(out, body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if stalled.Load() {
+		err = fmt.Errorf("no data received for %s", d.StallTimeout)
+	}
+	if err != nil {
+		return offset > 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	return offset > 0, nil
+}
+
+// source returns the URL url is fetched from.
+func (d *Downloader) source(url string) string {
+	if d.Mirror == nil {
+		return url
+	}
+	return d.Mirror(url)
+}
+
+func (d *Downloader) backoff(attempt int) time.Duration {
+	wait := d.Backoff << (attempt - 1)
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+func (d *Downloader) report(e ProgressEvent) {
+	if d.Progress != nil {
+		e.Time = time.Now()
+		d.Progress(e)
+	}
+}
+
+// transientStatus reports whether a request failing with code may succeed
+// when retried.
+func transientStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// permanentError marks a failure that retrying does not fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type progressReader struct {
+	r      io.Reader
+	onRead func(int)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.onRead(n)
+	}
+	return n, err
+}
+
+// SHA256URL downloads url and returns the hex SHA256 of its content.
+func (d *Downloader) SHA256URL(url string) (string, error) {
+	resp, err := d.Client.Get(d.source(url))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SHA256File returns the hex SHA256 of a file.
+func SHA256File(name string) (string, error) {
+	sum, _, err := hashFile(name)
+	return sum, err
+}
+
+func hashFile(name string) (string, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// FetchChecksum downloads a published checksum file and returns the
+// digest listed for fileName (see ParseChecksum).
+func (d *Downloader) FetchChecksum(url, fileName string) (string, error) {
+	data, err := d.FetchSmall(url, 1<<20)
+	if err != nil {
+		return "", err
+	}
+	sum, err := ParseChecksum(data, fileName)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", url, err)
+	}
+	return sum, nil
+}
+
+// smallFileTimeout bounds a request for a checksum file or a release
+// marker; they are tiny, so a hard timeout is safe.
+const smallFileTimeout = 30 * time.Second
+
+// FetchSmall reads up to limit bytes of url, such as a checksum or a
+// release marker, retrying transient failures like Download does.
+func (d *Downloader) FetchSmall(url string, limit int64) ([]byte, error) {
+	get := func() ([]byte, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), smallFileTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.source(url), nil)
+		if err != nil {
+			return nil, permanent(err)
+		}
+		resp, err := d.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch URL: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("bad status: %s", resp.Status)
+			if !transientStatus(resp.StatusCode) {
+				err = permanent(err)
+			}
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", url, err)
+		}
+		return data, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		data, err := get()
+		if err == nil {
+			return data, nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return nil, perm.err
+		}
+		if attempt >= d.Attempts {
+			return nil, err
+		}
+		time.Sleep(d.backoff(attempt))
+	}
+}
+
+// ParseChecksum extracts the SHA256 of fileName from a checksum file: either
+// a bare digest (as in dl.k8s.io *.sha256) or sha256sum output with one
+// "<digest>  <file>" line per artifact.
+func ParseChecksum(data []byte, fileName string) (string, error) {
+	var lines [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+
+	for _, fields := range lines {
+		switch {
+		case len(fields) == 1 && len(lines) == 1:
+		case len(fields) == 2 && path.Base(strings.TrimPrefix(fields[1], "*")) == fileName:
+		default:
+			continue
+		}
+		if !IsSHA256(fields[0]) {
+			return "", fmt.Errorf("malformed sha256 %q for %s", fields[0], fileName)
+		}
+		return strings.ToLower(fields[0]), nil
+	}
+	return "", fmt.Errorf("no sha256 listed for %s", fileName)
+}
+
+// IsSHA256 reports whether s is a hex-encoded SHA256 digest.
+func IsSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
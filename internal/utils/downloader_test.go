@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadVerified(t *testing.T) {
+	artifact := []byte("#!/bin/sh\necho kubelet\n")
+	sum := sha256.Sum256(artifact)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(artifact)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "kubelet")
+	if err := DownloadVerified(srv.URL+"/kubelet", dest, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("DownloadVerified failed: %v", err)
+	}
+	if data, err := os.ReadFile(dest); err != nil || string(data) != string(artifact) {
+		t.Errorf("Unexpected download %q: %v", data, err)
+	}
+
+	tampered := filepath.Join(dir, "tampered")
+	err := DownloadVerified(srv.URL+"/kubelet", tampered, strings.Repeat("0", 64))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+	for _, p := range []string{tampered, tampered + ".part"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to exist after a mismatch", p)
+		}
+	}
+}
+
+// testDownloader retries quickly and records the events.
+func testDownloader(events *[]ProgressEvent) *Downloader {
+	d := NewDownloader()
+	d.Backoff = time.Millisecond
+	d.StallTimeout = 200 * time.Millisecond
+	var mu sync.Mutex
+	d.Progress = func(e ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		*events = append(*events, e)
+	}
+	return d
+}
+
+func countEvents(events []ProgressEvent, name string) int {
+	n := 0
+	for _, e := range events {
+		if e.Event == name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDownloadResumesInterruptedTransfer(t *testing.T) {
+	artifact := bytes.Repeat([]byte("kubelet "), 4096)
+	sum := sha256.Sum256(artifact)
+	var requests atomic.Int32
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if requests.Add(1) == 1 {
+			// Announce the whole file but drop the connection halfway.
+			w.Header().Set("Content-Length", strconv.Itoa(len(artifact)))
+			w.Write(artifact[:len(artifact)/2])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "kubelet", time.Time{}, bytes.NewReader(artifact))
+	}))
+	defer srv.Close()
+
+	var events []ProgressEvent
+	dest := filepath.Join(t.TempDir(), "kubelet")
+	if err := testDownloader(&events).Download(srv.URL+"/kubelet", dest, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); !bytes.Equal(data, artifact) {
+		t.Fatalf("Resumed download differs from the artifact (%d bytes)", len(data))
+	}
+	if len(ranges) != 2 || ranges[1] != fmt.Sprintf("bytes=%d-", len(artifact)/2) {
+		t.Errorf("Expected the second request to resume at %d, got ranges %q", len(artifact)/2, ranges)
+	}
+	if countEvents(events, EventRetry) != 1 || countEvents(events, EventDone) != 1 {
+		t.Errorf("Expected one retry and one done event, got %+v", events)
+	}
+}
+
+func TestDownloadRetriesTransientErrors(t *testing.T) {
+	artifact := []byte("containerd")
+	sum := sha256.Sum256(artifact)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(artifact)
+	}))
+	defer srv.Close()
+
+	var events []ProgressEvent
+	dest := filepath.Join(t.TempDir(), "containerd")
+	if err := testDownloader(&events).Download(srv.URL, dest, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+}
+
+func TestDownloadDoesNotRetryPermanentErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	var events []ProgressEvent
+	err := testDownloader(&events).Download(srv.URL, filepath.Join(t.TempDir(), "runc"), strings.Repeat("0", 64))
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected a 404 error, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single request, got %d", n)
+	}
+	if countEvents(events, EventFailed) != 1 {
+		t.Errorf("Expected a failed event, got %+v", events)
+	}
+}
+
+func TestDownloadAbortsStalledTransfer(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var events []ProgressEvent
+	d := testDownloader(&events)
+	d.Attempts = 2
+	err := d.Download(srv.URL, filepath.Join(t.TempDir(), "crictl"), strings.Repeat("0", 64))
+	if err == nil || !strings.Contains(err.Error(), "no data received") {
+		t.Fatalf("Expected a stall error, got %v", err)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	other := strings.Repeat("cd", 32)
+
+	tests := []struct {
+		name string
+		data string
+		file string
+	}{
+		{"bare digest", digest + "\n", "kubelet"},
+		{"sha256sum line", digest + "  containerd-2.0.5-linux-amd64.tar.gz\n", "containerd-2.0.5-linux-amd64.tar.gz"},
+		{"binary mode", digest + " *crictl-v1.30.0-linux-amd64.tar.gz", "crictl-v1.30.0-linux-amd64.tar.gz"},
+		{"several files", other + "  runc.arm64\n" + digest + "  runc.amd64\n", "runc.amd64"},
+		{"path in name", digest + "  ./release/cni-plugins-linux-amd64-v1.6.2.tgz\n", "cni-plugins-linux-amd64-v1.6.2.tgz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChecksum([]byte(tt.data), tt.file)
+			if err != nil || got != digest {
+				t.Errorf("Expected %s, got %s (%v)", digest, got, err)
+			}
+		})
+	}
+
+	if _, err := ParseChecksum([]byte(other+"  runc.arm64\n"), "runc.amd64"); err == nil {
+		t.Error("Expected an error when the file is not listed")
+	}
+	if _, err := ParseChecksum([]byte("not-a-digest\n"), "kubelet"); err == nil {
+		t.Error("Expected an error for a malformed digest")
+	}
+}
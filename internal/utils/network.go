@@ -21,4 +21,22 @@ func GetHostIP() (string, error) {
 	}
 
 	return "", fmt.Errorf("no valid IPv4 address found")
-}
\ No newline at end of file
+}
+// InterfaceIPs returns the addresses of all host interfaces except loopback
+// and link-local ones.
+func InterfaceIPs() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface addresses: %w", err)
+	}
+
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipnet.IP)
+	}
+	return ips, nil
+}
@@ -26,4 +26,15 @@ func TestGetHostIP(t *testing.T) {
 	if parsedIP.To4() == nil {
 		t.Errorf("GetHostIP returned non-IPv4 address: %s", ip)
 	}
-}
\ No newline at end of file
+}
+func TestInterfaceIPs(t *testing.T) {
+	ips, err := InterfaceIPs()
+	if err != nil {
+		t.Skipf("Skipping test: %v", err)
+	}
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			t.Errorf("InterfaceIPs returned %s", ip)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Download events reported by a Downloader.
+const (
+	EventStart    = "start"
+	EventProgress = "progress"
+	EventRetry    = "retry"
+	EventDone     = "done"
+	EventFailed   = "failed"
+)
+
+// Progress output modes accepted by NewProgressReporter.
+const (
+	ProgressAuto  = "auto"
+	ProgressTTY   = "tty"
+	ProgressPlain = "plain"
+	ProgressJSON  = "json"
+	ProgressNone  = "none"
+)
+
+// ProgressEvent describes the state of one download.
+type ProgressEvent struct {
+	Time  time.Time `json:"time"`
+	URL   string    `json:"url"`
+	Event string    `json:"event"`
+	// Bytes is how much of the file is on disk, Total its size or -1 when
+	// the server does not tell.
+	Bytes   int64 `json:"bytes"`
+	Total   int64 `json:"total"`
+	Attempt int   `json:"attempt,omitempty"`
+	// Rate is bytes per second of the current attempt.
+	Rate    float64       `json:"rate,omitempty"`
+	Elapsed time.Duration `json:"elapsed,omitempty"`
+	// Wait is the backoff before the next attempt.
+	Wait  time.Duration `json:"wait,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// ETA estimates the time left, or 0 when it is unknown.
+func (e ProgressEvent) ETA() time.Duration {
+	if e.Total < 0 || e.Rate <= 0 || e.Bytes >= e.Total {
+		return 0
+	}
+	return time.Duration(float64(e.Total-e.Bytes) / e.Rate * float64(time.Second)).Round(time.Second)
+}
+
+// NewProgressReporter returns the Downloader.Progress callback for mode:
+// tty redraws a status line on w, plain logs milestones, json writes one
+// event per line to w and none discards events. auto is tty when w is a
+// terminal and plain otherwise.
+func NewProgressReporter(mode string, w *os.File) (func(ProgressEvent), error) {
+	switch mode {
+	case "", ProgressAuto:
+		if info, err := w.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return newTTYProgress(w), nil
+		}
+		return newPlainProgress(), nil
+	case ProgressTTY:
+		return newTTYProgress(w), nil
+	case ProgressPlain:
+		return newPlainProgress(), nil
+	case ProgressJSON:
+		return newJSONProgress(w), nil
+	case ProgressNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown progress mode %q (want %s, %s, %s, %s or %s)",
+			mode, ProgressAuto, ProgressTTY, ProgressPlain, ProgressJSON, ProgressNone)
+	}
+}
+
+// newTTYProgress keeps one line with every running download up to date.
+// Finished and retried downloads are logged above it.
+func newTTYProgress(w io.Writer) func(ProgressEvent) {
+	var mu sync.Mutex
+	active := map[string]ProgressEvent{}
+	return func(e ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fmt.Fprint(w, "\r\033[K")
+		switch e.Event {
+		case EventStart, EventProgress:
+			active[e.URL] = e
+		case EventRetry, EventFailed, EventDone:
+			delete(active, e.URL)
+			log.Print(describeEvent(e))
+		}
+		if len(active) == 0 {
+			return
+		}
+
+		urls := make([]string, 0, len(active))
+		for url := range active {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+		var parts []string
+		var rate float64
+		var eta time.Duration
+		for _, url := range urls {
+			a := active[url]
+			part := path.Base(a.URL) + " " + FormatBytes(a.Bytes)
+			if a.Total > 0 {
+				part = fmt.Sprintf("%s %d%%", path.Base(a.URL), a.Bytes*100/a.Total)
+			}
+			parts = append(parts, part)
+			rate += a.Rate
+			eta = max(eta, a.ETA())
+		}
+		status := "  ⇣ " + strings.Join(parts, " · ") + " · " + FormatBytes(int64(rate)) + "/s"
+		if eta > 0 {
+			status += " · ETA " + eta.String()
+		}
+		// Back to the line start so that log output overwrites the status.
+		fmt.Fprint(w, status+"\r")
+	}
+}
+
+// plainProgressInterval is how often plain output logs a running download.
+const plainProgressInterval = 15 * time.Second
+
+// newPlainProgress logs resumes, retries, completion and, for long
+// downloads, the progress every plainProgressInterval.
+func newPlainProgress() func(ProgressEvent) {
+	var mu sync.Mutex
+	logged := map[string]time.Time{}
+	return func(e ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch e.Event {
+		case EventStart:
+			logged[e.URL] = e.Time
+			if e.Bytes > 0 {
+				log.Printf("  Resuming %s at %s", path.Base(e.URL), FormatBytes(e.Bytes))
+			}
+			return
+		case EventProgress:
+			if e.Time.Sub(logged[e.URL]) < plainProgressInterval {
+				return
+			}
+			logged[e.URL] = e.Time
+		default:
+			delete(logged, e.URL)
+		}
+		log.Print(describeEvent(e))
+	}
+}
+
+func newJSONProgress(w io.Writer) func(ProgressEvent) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(e)
+	}
+}
+
+func describeEvent(e ProgressEvent) string {
+	name := path.Base(e.URL)
+	switch e.Event {
+	case EventProgress:
+		s := fmt.Sprintf("  %s: %s", name, FormatBytes(e.Bytes))
+		if e.Total > 0 {
+			s += fmt.Sprintf(" of %s", FormatBytes(e.Total))
+		}
+		s += fmt.Sprintf(", %s/s", FormatBytes(int64(e.Rate)))
+		if eta := e.ETA(); eta > 0 {
+			s += ", ETA " + eta.String()
+		}
+		return s
+	case EventRetry:
+		return fmt.Sprintf("  ⚠ %s: attempt %d failed (%s), retrying in %s", name, e.Attempt, e.Error, e.Wait)
+	case EventFailed:
+		return fmt.Sprintf("  ✗ %s: attempt %d failed, giving up: %s", name, e.Attempt, e.Error)
+	case EventDone:
+		return fmt.Sprintf("  ✓ %s (%s in %s)", name, FormatBytes(e.Bytes), e.Elapsed.Round(100*time.Millisecond))
+	}
+	return fmt.Sprintf("  %s: %s", name, e.Event)
+}
+
+// FormatBytes renders n with a binary unit, e.g. 12.3 MiB.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
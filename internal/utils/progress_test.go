@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProgressEventETA(t *testing.T) {
+	e := ProgressEvent{Bytes: 10 << 20, Total: 40 << 20, Rate: 1 << 20}
+	if eta := e.ETA(); eta != 30*time.Second {
+		t.Errorf("Expected 30s, got %s", eta)
+	}
+	e.Total = -1
+	if eta := e.ETA(); eta != 0 {
+		t.Errorf("Expected no ETA for an unknown size, got %s", eta)
+	}
+}
+
+func TestJSONProgress(t *testing.T) {
+	var buf bytes.Buffer
+	report := newJSONProgress(&buf)
+	report(ProgressEvent{URL: "https://dl.k8s.io/v1.30.0/bin/linux/amd64/kubelet", Event: EventDone, Bytes: 42, Total: 42})
+
+	var e ProgressEvent
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("Expected one JSON event per line: %v (%q)", err, buf.String())
+	}
+	if e.Event != EventDone || e.Bytes != 42 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+}
+
+func TestNewProgressReporterRejectsUnknownMode(t *testing.T) {
+	if _, err := NewProgressReporter("fancy", os.Stderr); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 25 << 20: "25.0 MiB"} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
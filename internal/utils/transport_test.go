@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewTransportTrustsCABundle(t *testing.T) {
+	artifact := []byte("kubelet")
+	sum := sha256.Sum256(artifact)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(artifact)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, block, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDownloader()
+	d.Attempts = 1
+	if err := d.Download(srv.URL, filepath.Join(dir, "untrusted"), hex.EncodeToString(sum[:])); err == nil {
+		t.Fatal("Expected the test server to be untrusted without the CA bundle")
+	}
+
+	transport, err := NewTransport("", bundle)
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	d.Client.Transport = transport
+	if err := d.Download(srv.URL, filepath.Join(dir, "trusted"), hex.EncodeToString(sum[:])); err != nil {
+		t.Errorf("Expected the CA bundle to be trusted: %v", err)
+	}
+}
+
+func TestNewTransportUsesProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute upstream URL.
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte(strings.Repeat("ab", 32) + "\n"))
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(proxy.URL, "")
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	d := NewDownloader()
+	d.Client.Transport = transport
+	if _, err := d.FetchChecksum("http://dl.k8s.io.invalid/kubelet.sha256", "kubelet"); err != nil {
+		t.Fatalf("FetchChecksum through the proxy failed: %v", err)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://dl.k8s.io.invalid/kubelet.sha256" {
+		t.Errorf("Expected the request to go through the proxy, got %v", proxied)
+	}
+}
+
+func TestNewTransportRejectsInvalidSettings(t *testing.T) {
+	if _, err := NewTransport("proxy:3128", ""); err == nil {
+		t.Error("Expected a proxy without a scheme to be rejected")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, []byte("not a certificate"), 0644)
+	if _, err := NewTransport("", empty); err == nil {
+		t.Error("Expected a CA bundle without certificates to be rejected")
+	}
+}
+
+func TestDownloaderMirror(t *testing.T) {
+	artifact := []byte("runc")
+	sum := sha256.Sum256(artifact)
+	var paths []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write(artifact)
+	}))
+	defer mirror.Close()
+
+	var events []ProgressEvent
+	d := testDownloader(&events)
+	d.Mirror = func(url string) string {
+		return strings.Replace(url, "https://github.com", mirror.URL+"/github", 1)
+	}
+	upstream := "https://github.com/opencontainers/runc/releases/download/v1.2.6/runc.amd64"
+	if err := d.Download(upstream, filepath.Join(t.TempDir(), "runc"), hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("Download from the mirror failed: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/github/opencontainers/runc/releases/download/v1.2.6/runc.amd64" {
+		t.Errorf("Unexpected mirror requests %v", paths)
+	}
+	for _, e := range events {
+		if e.URL != upstream {
+			t.Errorf("Expected events to name the upstream URL, got %s", e.URL)
+		}
+	}
+}